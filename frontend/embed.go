@@ -0,0 +1,24 @@
+//go:build embedfrontend
+
+// Package frontend 提供嵌入到二进制文件中的前端，使用 -tags embedfrontend 构建时生效
+package frontend
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed all:dist
+var dist embed.FS
+
+// Embedded 二进制文件中是否包含前端
+const Embedded = true
+
+// Assets 返回嵌入的前端文件，根目录为dist
+func Assets() fs.FS {
+	assets, err := fs.Sub(dist, "dist")
+	if err != nil {
+		panic(err)
+	}
+	return assets
+}
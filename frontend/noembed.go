@@ -0,0 +1,14 @@
+//go:build !embedfrontend
+
+// Package frontend 提供嵌入到二进制文件中的前端，使用 -tags embedfrontend 构建时生效
+package frontend
+
+import "io/fs"
+
+// Embedded 二进制文件中是否包含前端
+const Embedded = false
+
+// Assets 返回嵌入的前端文件，未嵌入时返回nil
+func Assets() fs.FS {
+	return nil
+}
@@ -37,7 +37,7 @@ export const clashApi = {
     if (response.data.success) {
       return {
         port: response.data.port,
-        secret: response.data.secret
+        proxy: response.data.proxy
       }
     }
     throw new Error('获取控制信息失败')
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"clash-center/internal/audit"
+	"clash-center/internal/utils"
+)
+
+// 每页记录数的上限
+const maxAuditPageSize = 500
+
+// 处理获取审计日志的请求，最新的记录在前，支持 page 和 size 参数分页
+func HandleGetAuditLog(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	size, err := strconv.Atoi(query.Get("size"))
+	if err != nil || size < 1 {
+		size = 50
+	}
+	size = min(size, maxAuditPageSize)
+
+	entries, total, err := audit.Read(page, size)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, fmt.Sprintf("读取审计日志失败: %v", err))
+		return
+	}
+
+	utils.SendSuccessResponse(w, "", map[string]any{
+		"entries": entries,
+		"total":   total,
+		"page":    page,
+		"size":    size,
+	})
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"clash-center/internal/audit"
+)
+
+func TestAuditLogsRejectedRequests(t *testing.T) {
+	savedPath, savedToken := audit.LogPath, AuthToken
+	t.Cleanup(func() { audit.LogPath, AuthToken = savedPath, savedToken })
+	audit.LogPath = filepath.Join(t.TempDir(), "audit.jsonl")
+	AuthToken = "secret-token"
+	useTempAppConfig(t)
+
+	handler := SetupRoutes(false)
+	req := httptest.NewRequest(http.MethodPost, "/api/stop", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("状态码为 %d", rec.Code)
+	}
+
+	entries, _, err := audit.Read(1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Status != http.StatusUnauthorized || entries[0].Action != "stop" || entries[0].Success {
+		t.Fatalf("审计记录为 %+v", entries)
+	}
+}
+
+func TestAuditLogsOnlyMarkedOperations(t *testing.T) {
+	useTempConfigDir(t)
+
+	// 测速等不修改状态的POST请求不记录
+	serveAPI(t, http.MethodPost, "/api/groups/delay", "{")
+	// 处理函数标记的操作无论成功与否都记录
+	serveAPI(t, http.MethodPost, "/api/autostart", `{"autoStart": true}`)
+	serveAPI(t, http.MethodPost, "/api/delete-config", `{"configPath": "missing.yaml"}`)
+	// 未启用认证时远程访问内核代理被拒绝，同样记录
+	serveAPI(t, http.MethodPost, "/api/core/configs", "{}")
+
+	entries, total, err := audit.Read(1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 3 {
+		t.Fatalf("记录了 %d 条，应为3条: %+v", total, entries)
+	}
+	// 最新的记录在前
+	want := []struct {
+		action string
+		target string
+		status int
+	}{
+		{"core/configs", "", http.StatusForbidden},
+		{"delete-config", "missing.yaml", http.StatusNotFound},
+		{"autostart", "", http.StatusOK},
+	}
+	for i, w := range want {
+		e := entries[i]
+		if e.Action != w.action || e.Target != w.target || e.Status != w.status {
+			t.Errorf("第%d条记录为 %+v，应为 %+v", i+1, e, w)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"strings"
+
+	"clash-center/internal/audit"
+	"clash-center/internal/models"
+	"clash-center/internal/utils"
+)
+
+var (
+	// 允许访问的用户，用户名 -> 密码，使用HTTP Basic认证
+	AuthUsers = map[string]string{}
+	// API访问令牌，使用 Authorization: Bearer <token> 或 ?token= 参数传递
+	AuthToken string
+)
+
+// 使用令牌认证时记录的用户名
+const tokenUserName = "token"
+
+type contextKey string
+
+const userContextKey contextKey = "user"
+
+// AuthEnabled 是否启用了访问认证
+func AuthEnabled() bool {
+	return len(AuthUsers) > 0 || AuthToken != ""
+}
+
+// CurrentUser 获取当前请求的认证用户，未启用认证时返回空字符串
+func CurrentUser(r *http.Request) string {
+	user, _ := r.Context().Value(userContextKey).(string)
+	return user
+}
+
+// 认证中间件，未配置用户和令牌时不做任何限制
+func authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !AuthEnabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user, ok := authenticate(r)
+		if !ok {
+			// 浏览器收到Basic质询后会弹出登录框，之后的请求（包括WebSocket）会自动携带凭据
+			if len(AuthUsers) > 0 {
+				w.Header().Set("WWW-Authenticate", `Basic realm="clash-center", charset="UTF-8"`)
+			}
+			utils.SendCodedErrorResponse(w, http.StatusUnauthorized, models.ErrCodeUnauthorized, "未授权的访问")
+			return
+		}
+
+		audit.SetUser(r, user)
+		ctx := context.WithValue(r.Context(), userContextKey, user)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// 限制危险操作的中间件，未启用认证时只允许通过回环地址或Unix套接字访问
+func localOrAuthenticated(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !AuthEnabled() && !isLocalRequest(r) {
+			utils.SendCodedErrorResponse(w, http.StatusForbidden, models.ErrCodeForbidden, "未启用访问认证时只允许本机访问该接口")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// 请求是否来自本机，Unix套接字的请求没有来源地址，按监听地址判断
+func isLocalRequest(r *http.Request) bool {
+	if addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok && addr.Network() == "unix" {
+		return true
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// 校验请求中的认证信息，返回用户名
+func authenticate(r *http.Request) (string, bool) {
+	if username, password, ok := r.BasicAuth(); ok {
+		expected, exists := AuthUsers[username]
+		if exists && subtle.ConstantTimeCompare([]byte(password), []byte(expected)) == 1 {
+			return username, true
+		}
+		return "", false
+	}
+
+	if AuthToken == "" {
+		return "", false
+	}
+
+	token := ""
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimPrefix(auth, "Bearer ")
+	} else {
+		// EventSource和WebSocket无法自定义请求头，允许通过参数传递
+		token = r.URL.Query().Get("token")
+	}
+
+	if token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(AuthToken)) == 1 {
+		return tokenUserName, true
+	}
+	return "", false
+}
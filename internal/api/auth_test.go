@@ -0,0 +1,68 @@
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"clash-center/internal/clash"
+)
+
+func TestLocalOrAuthenticated(t *testing.T) {
+	useTempConfigDir(t)
+	savedToken, savedCore := AuthToken, clash.ClashPath
+	t.Cleanup(func() { AuthToken, clash.ClashPath = savedToken, savedCore })
+	// 没有可以回滚的内核，通过检查的请求返回404
+	clash.ClashPath = filepath.Join(t.TempDir(), "mihomo")
+
+	unixAddr := &net.UnixAddr{Name: "/run/clash-center.sock", Net: "unix"}
+	tests := []struct {
+		name       string
+		token      string
+		remoteAddr string
+		localAddr  net.Addr
+		header     string
+		want       int
+	}{
+		{name: "未启用认证的远程请求", remoteAddr: "192.0.2.1:1234", want: http.StatusForbidden},
+		{name: "IPv4回环地址", remoteAddr: "127.0.0.1:1234", want: http.StatusNotFound},
+		{name: "IPv6回环地址", remoteAddr: "[::1]:1234", want: http.StatusNotFound},
+		{name: "Unix套接字", remoteAddr: "@", localAddr: unixAddr, want: http.StatusNotFound},
+		{name: "启用认证的远程请求", token: "secret", remoteAddr: "192.0.2.1:1234", header: "Bearer secret", want: http.StatusNotFound},
+		{name: "启用认证但未认证", token: "secret", remoteAddr: "127.0.0.1:1234", want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			AuthToken = tt.token
+			req := httptest.NewRequest("POST", "/api/v2/core/binary:rollback", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.localAddr != nil {
+				req = req.WithContext(context.WithValue(req.Context(), http.LocalAddrContextKey, tt.localAddr))
+			}
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			SetupRoutes(false).ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("状态码 = %d，应为 %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestCoreProxyRequiresLocalWithoutAuth(t *testing.T) {
+	useTempConfigDir(t)
+	savedToken := AuthToken
+	t.Cleanup(func() { AuthToken = savedToken })
+	AuthToken = ""
+
+	// 控制器密钥由代理注入，远程请求不能经由代理访问控制器
+	code, result := serveAPI(t, "GET", "/api/core/configs", "")
+	if code != http.StatusForbidden || result["code"] != "forbidden" {
+		t.Errorf("未启用认证的远程请求返回 %d %v，应为403", code, result)
+	}
+}
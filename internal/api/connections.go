@@ -0,0 +1,292 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"clash-center/internal/clash"
+	"clash-center/internal/mihomo"
+	"clash-center/internal/models"
+	"clash-center/internal/utils"
+)
+
+// 处理获取活动连接的请求，支持 host、process、rule、chain 参数过滤
+func HandleGetConnections(w http.ResponseWriter, r *http.Request) {
+	if !clash.IsRunning() {
+		utils.SendErrorResponse(w, http.StatusConflict, "Clash未运行")
+		return
+	}
+
+	snapshot, err := clash.NewControllerClient().Connections(r.Context())
+	if err != nil {
+		sendControllerError(w, "获取连接失败", err)
+		return
+	}
+
+	filtered := filterConnections(snapshot.Connections, connectionFilterFromQuery(r))
+
+	// 最新的连接排在前面
+	sort.SliceStable(filtered, func(i, j int) bool {
+		return filtered[i].Start.After(filtered[j].Start)
+	})
+
+	connections := make([]models.ConnectionInfo, 0, len(filtered))
+	for _, connection := range filtered {
+		connections = append(connections, toConnectionInfo(connection))
+	}
+
+	utils.SendSuccessResponse(w, "", map[string]any{
+		"connections":   connections,
+		"uploadTotal":   snapshot.UploadTotal,
+		"downloadTotal": snapshot.DownloadTotal,
+	})
+}
+
+// 处理连接统计请求，by=rule 按规则汇总，by=chain 按出站节点汇总
+func HandleGetConnectionStats(w http.ResponseWriter, r *http.Request) {
+	by := r.URL.Query().Get("by")
+	if by == "" {
+		by = "rule"
+	}
+	if by != "rule" && by != "chain" {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "by参数只能是rule或chain")
+		return
+	}
+
+	if !clash.IsRunning() {
+		utils.SendErrorResponse(w, http.StatusConflict, "Clash未运行")
+		return
+	}
+
+	snapshot, err := clash.NewControllerClient().Connections(r.Context())
+	if err != nil {
+		sendControllerError(w, "获取连接失败", err)
+		return
+	}
+
+	stats := make(map[string]*models.ConnectionStat)
+	for _, connection := range filterConnections(snapshot.Connections, connectionFilterFromQuery(r)) {
+		key := connectionStatKey(connection, by)
+		stat, ok := stats[key]
+		if !ok {
+			stat = &models.ConnectionStat{Key: key}
+			stats[key] = stat
+		}
+		stat.Connections++
+		stat.Upload += connection.Upload
+		stat.Download += connection.Download
+	}
+
+	// 按流量从大到小排序
+	result := make([]models.ConnectionStat, 0, len(stats))
+	for _, stat := range stats {
+		result = append(result, *stat)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		ti := result[i].Upload + result[i].Download
+		tj := result[j].Upload + result[j].Download
+		if ti != tj {
+			return ti > tj
+		}
+		return result[i].Key < result[j].Key
+	})
+
+	utils.SendSuccessResponse(w, "", map[string]any{
+		"by":    by,
+		"stats": result,
+	})
+}
+
+// 处理关闭连接的请求，可以指定连接ID、过滤条件或关闭全部连接
+func HandleCloseConnections(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.SendErrorResponse(w, http.StatusMethodNotAllowed, "仅支持POST请求")
+		return
+	}
+
+	// 解析请求体
+	var requestBody struct {
+		IDs    []string                `json:"ids"`
+		Filter models.ConnectionFilter `json:"filter"`
+		All    bool                    `json:"all"`
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&requestBody)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("解析请求失败: %v", err))
+		return
+	}
+
+	if !requestBody.All && len(requestBody.IDs) == 0 && requestBody.Filter.IsEmpty() {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "请指定要关闭的连接ID、过滤条件或关闭全部连接")
+		return
+	}
+
+	if !clash.IsRunning() {
+		utils.SendErrorResponse(w, http.StatusConflict, "Clash未运行")
+		return
+	}
+
+	client := clash.NewControllerClient()
+	if requestBody.All {
+		if err := client.CloseAllConnections(r.Context()); err != nil {
+			sendControllerError(w, "关闭连接失败", err)
+			return
+		}
+		log.Println("已关闭全部连接")
+		utils.SendSuccessResponse(w, "已关闭全部连接")
+		return
+	}
+
+	ids := requestBody.IDs
+	if len(ids) == 0 {
+		snapshot, err := client.Connections(r.Context())
+		if err != nil {
+			sendControllerError(w, "获取连接失败", err)
+			return
+		}
+		for _, connection := range filterConnections(snapshot.Connections, requestBody.Filter) {
+			ids = append(ids, connection.ID)
+		}
+	}
+
+	closed := 0
+	failed := []string{}
+	for _, id := range ids {
+		if err := client.CloseConnection(r.Context(), id); err != nil {
+			log.Printf("关闭连接 %s 失败: %v", id, err)
+			failed = append(failed, id)
+			continue
+		}
+		closed++
+	}
+
+	log.Printf("已关闭 %d 个连接\n", closed)
+	// 部分连接关闭失败时返回失败的连接ID
+	if len(failed) > 0 {
+		utils.SendJSONResponse(w, http.StatusBadGateway, map[string]any{
+			"success": false,
+			"error":   fmt.Sprintf("%d 个连接关闭失败，已关闭 %d 个连接", len(failed), closed),
+			"closed":  closed,
+			"failed":  failed,
+		})
+		return
+	}
+	utils.SendSuccessResponse(w, fmt.Sprintf("已关闭 %d 个连接", closed), map[string]any{
+		"closed": closed,
+		"failed": failed,
+	})
+}
+
+// 从查询参数读取连接过滤条件
+func connectionFilterFromQuery(r *http.Request) models.ConnectionFilter {
+	query := r.URL.Query()
+	return models.ConnectionFilter{
+		Host:    query.Get("host"),
+		Process: query.Get("process"),
+		Rule:    query.Get("rule"),
+		Chain:   query.Get("chain"),
+	}
+}
+
+// 筛选符合条件的连接
+func filterConnections(connections []mihomo.Connection, filter models.ConnectionFilter) []mihomo.Connection {
+	if filter.IsEmpty() {
+		return connections
+	}
+
+	var result []mihomo.Connection
+	for _, connection := range connections {
+		if matchConnection(connection, filter) {
+			result = append(result, connection)
+		}
+	}
+	return result
+}
+
+// 判断连接是否符合过滤条件
+func matchConnection(connection mihomo.Connection, filter models.ConnectionFilter) bool {
+	metadata := connection.Metadata
+	if filter.Host != "" && !containsFold(filter.Host, metadata.Host, metadata.SniffHost, metadata.DestinationIP) {
+		return false
+	}
+	if filter.Process != "" && !containsFold(filter.Process, metadata.Process, metadata.ProcessPath) {
+		return false
+	}
+	if filter.Rule != "" && !containsFold(filter.Rule, connection.Rule, connection.RulePayload) {
+		return false
+	}
+	if filter.Chain != "" && !containsFold(filter.Chain, connection.Chains...) {
+		return false
+	}
+	return true
+}
+
+// 判断任意一个值是否包含子串，不区分大小写
+func containsFold(substr string, values ...string) bool {
+	substr = strings.ToLower(substr)
+	return slices.ContainsFunc(values, func(value string) bool {
+		return strings.Contains(strings.ToLower(value), substr)
+	})
+}
+
+// 连接统计的分组键
+func connectionStatKey(connection mihomo.Connection, by string) string {
+	if by == "chain" {
+		// 控制器返回的代理链从出站节点开始
+		if len(connection.Chains) > 0 {
+			return connection.Chains[0]
+		}
+		return "DIRECT"
+	}
+
+	if connection.RulePayload != "" {
+		return connection.Rule + "," + connection.RulePayload
+	}
+	return connection.Rule
+}
+
+// 转换为API返回的连接信息
+func toConnectionInfo(connection mihomo.Connection) models.ConnectionInfo {
+	metadata := connection.Metadata
+	host := metadata.Host
+	if host == "" {
+		host = metadata.SniffHost
+	}
+
+	chains := connection.Chains
+	if chains == nil {
+		chains = []string{}
+	}
+
+	return models.ConnectionInfo{
+		ID:          connection.ID,
+		Network:     metadata.Network,
+		Type:        metadata.Type,
+		Source:      joinHostPort(metadata.SourceIP, metadata.SourcePort),
+		Destination: joinHostPort(metadata.DestinationIP, metadata.DestinationPort),
+		Host:        host,
+		Process:     metadata.Process,
+		Rule:        connection.Rule,
+		RulePayload: connection.RulePayload,
+		Chains:      chains,
+		Upload:      connection.Upload,
+		Download:    connection.Download,
+		Start:       connection.Start.Format(time.RFC3339),
+	}
+}
+
+// 组合地址和端口，地址为空时返回空字符串
+func joinHostPort(host, port string) string {
+	if host == "" {
+		return ""
+	}
+	return net.JoinHostPort(host, port)
+}
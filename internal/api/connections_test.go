@@ -0,0 +1,221 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"clash-center/internal/clash"
+	"clash-center/internal/config"
+)
+
+// 模拟控制器的连接接口，返回关闭过的连接ID
+func startFakeConnections(t *testing.T, failing ...string) func() []string {
+	t.Helper()
+	var mu sync.Mutex
+	var closed []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/connections":
+			fmt.Fprint(w, `{"downloadTotal":300,"uploadTotal":30,"connections":[
+				{"id":"a","metadata":{"network":"tcp","host":"www.google.com","destinationIP":"1.1.1.1","destinationPort":"443","process":"curl"},
+				 "upload":10,"download":100,"start":"2024-01-01T00:00:00Z","chains":["HK","Proxy"],"rule":"DOMAIN-SUFFIX","rulePayload":"google.com"},
+				{"id":"b","metadata":{"network":"tcp","host":"mail.google.com","destinationPort":"443"},
+				 "upload":15,"download":150,"start":"2024-01-01T00:00:02Z","chains":["JP","Proxy"],"rule":"DOMAIN-SUFFIX","rulePayload":"google.com"},
+				{"id":"c","metadata":{"network":"udp","host":"example.com","destinationPort":"53"},
+				 "upload":5,"download":50,"start":"2024-01-01T00:00:01Z","chains":["DIRECT"],"rule":"MATCH"}]}`)
+		case r.Method == http.MethodDelete && r.URL.Path == "/connections":
+			mu.Lock()
+			closed = append(closed, "*")
+			mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/connections/"):
+			id := strings.TrimPrefix(r.URL.Path, "/connections/")
+			if slices.Contains(failing, id) {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			mu.Lock()
+			closed = append(closed, id)
+			mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	startFakeCore(t)
+	content := fmt.Sprintf("external-controller: %s\nsecret: secret\n", server.Listener.Addr())
+	if err := os.WriteFile(config.MergedConfigPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(closed)
+	}
+}
+
+// 启动一个只等待退出的脚本作为内核，测试结束时停止
+// 启动时控制器地址指向未被占用的端口，以免被当作冲突，启动后由调用方写入实际的控制器地址
+func startFakeCore(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("测试内核使用shell脚本")
+	}
+
+	dir := t.TempDir()
+	savedPath, savedHome, savedCore := config.MergedConfigPath, clash.ClashHome, clash.ClashPath
+	t.Cleanup(func() {
+		clash.StopClash()
+		config.MergedConfigPath, clash.ClashHome, clash.ClashPath = savedPath, savedHome, savedCore
+	})
+	clash.ClashHome = dir
+	clash.ClashPath = filepath.Join(dir, "fake-core")
+	if err := os.WriteFile(clash.ClashPath, []byte("#!/bin/sh\nexec sleep 60\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unused := ln.Addr().String()
+	ln.Close()
+	config.MergedConfigPath = filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(config.MergedConfigPath, []byte("external-controller: "+unused+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := clash.StartClash(); err != nil {
+		t.Fatalf("启动测试内核失败: %v", err)
+	}
+}
+
+// 调用处理函数并解析JSON响应
+func callHandler(t *testing.T, handler http.HandlerFunc, method, target, body string) (int, map[string]any) {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+
+	var result map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("解析响应失败: %v: %s", err, rec.Body.String())
+	}
+	return rec.Code, result
+}
+
+func TestGetConnections(t *testing.T) {
+	startFakeConnections(t)
+
+	status, result := callHandler(t, HandleGetConnections, http.MethodGet, "/api/connections", "")
+	if status != http.StatusOK {
+		t.Fatalf("状态码为 %d: %v", status, result)
+	}
+	var ids []string
+	for _, item := range result["connections"].([]any) {
+		ids = append(ids, item.(map[string]any)["id"].(string))
+	}
+	// 最新的连接排在前面
+	if !slices.Equal(ids, []string{"b", "c", "a"}) {
+		t.Errorf("连接顺序为 %v", ids)
+	}
+	if result["downloadTotal"] != float64(300) {
+		t.Errorf("downloadTotal为 %v", result["downloadTotal"])
+	}
+
+	_, result = callHandler(t, HandleGetConnections, http.MethodGet, "/api/connections?host=MAIL&chain=jp", "")
+	connections := result["connections"].([]any)
+	if len(connections) != 1 || connections[0].(map[string]any)["id"] != "b" {
+		t.Errorf("过滤结果为 %v", connections)
+	}
+}
+
+func TestConnectionStats(t *testing.T) {
+	startFakeConnections(t)
+
+	_, result := callHandler(t, HandleGetConnectionStats, http.MethodGet, "/api/connections/stats", "")
+	stats := result["stats"].([]any)
+	first := stats[0].(map[string]any)
+	if len(stats) != 2 || first["key"] != "DOMAIN-SUFFIX,google.com" || first["connections"] != float64(2) || first["download"] != float64(250) {
+		t.Errorf("按规则统计为 %v", stats)
+	}
+
+	_, result = callHandler(t, HandleGetConnectionStats, http.MethodGet, "/api/connections/stats?by=chain", "")
+	if stats := result["stats"].([]any); len(stats) != 3 || stats[0].(map[string]any)["key"] != "JP" {
+		t.Errorf("按节点统计为 %v", stats)
+	}
+
+	status, _ := callHandler(t, HandleGetConnectionStats, http.MethodGet, "/api/connections/stats?by=host", "")
+	if status != http.StatusBadRequest {
+		t.Errorf("无效的by参数返回 %d", status)
+	}
+}
+
+func TestCloseConnections(t *testing.T) {
+	closed := startFakeConnections(t)
+
+	status, result := callHandler(t, HandleCloseConnections, http.MethodPost, "/api/connections/close", `{"ids":["a"]}`)
+	if status != http.StatusOK || result["closed"] != float64(1) {
+		t.Fatalf("按ID关闭返回 %d: %v", status, result)
+	}
+
+	status, result = callHandler(t, HandleCloseConnections, http.MethodPost, "/api/connections/close", `{"filter":{"rule":"google"}}`)
+	if status != http.StatusOK || result["closed"] != float64(2) {
+		t.Fatalf("按过滤条件关闭返回 %d: %v", status, result)
+	}
+
+	status, _ = callHandler(t, HandleCloseConnections, http.MethodPost, "/api/connections/close", `{"all":true}`)
+	if status != http.StatusOK {
+		t.Fatalf("关闭全部连接返回 %d", status)
+	}
+	if got := closed(); !slices.Equal(got, []string{"a", "a", "b", "*"}) {
+		t.Errorf("关闭的连接为 %v", got)
+	}
+
+	status, _ = callHandler(t, HandleCloseConnections, http.MethodPost, "/api/connections/close", `{}`)
+	if status != http.StatusBadRequest {
+		t.Errorf("没有指定连接时返回 %d", status)
+	}
+}
+
+func TestCloseConnectionsPartialFailure(t *testing.T) {
+	startFakeConnections(t, "b")
+
+	status, result := callHandler(t, HandleCloseConnections, http.MethodPost, "/api/connections/close", `{"filter":{"host":"google"}}`)
+	if status != http.StatusBadGateway || result["success"] != false {
+		t.Fatalf("部分关闭失败时返回 %d: %v", status, result)
+	}
+	if result["closed"] != float64(1) || fmt.Sprint(result["failed"]) != "[b]" {
+		t.Errorf("结果为 %v", result)
+	}
+}
+
+func TestConnectionsCoreNotRunning(t *testing.T) {
+	startFakeConnections(t)
+	if err := clash.StopClash(); err != nil {
+		t.Fatal(err)
+	}
+
+	status, _ := callHandler(t, HandleGetConnections, http.MethodGet, "/api/connections", "")
+	if status != http.StatusConflict {
+		t.Errorf("内核未运行时返回 %d", status)
+	}
+}
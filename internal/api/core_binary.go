@@ -0,0 +1,144 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"clash-center/internal/audit"
+	"clash-center/internal/clash"
+	"clash-center/internal/events"
+	"clash-center/internal/models"
+	"clash-center/internal/utils"
+)
+
+// 上传内核的最大请求大小
+const maxBinaryUpload = 256 << 20
+
+// 转换内核文件信息，无法使用时记录原因
+func toCoreBinary(info clash.BinaryInfo, err error) models.CoreBinary {
+	binary := models.CoreBinary{
+		Installed: !info.ModTime.IsZero(),
+		Path:      info.Path,
+		Version:   info.Version,
+		Build:     info.Build,
+		OS:        info.OS,
+		Arch:      info.Arch,
+		Size:      info.Size,
+	}
+	if binary.Installed {
+		binary.Modified = info.ModTime.Unix()
+	}
+	if err != nil {
+		binary.Error = err.Error()
+	}
+	return binary
+}
+
+// 获取当前内核和上一个版本的信息
+func coreBinaries() models.CoreBinaries {
+	binaries := models.CoreBinaries{Current: toCoreBinary(clash.InstalledBinary())}
+	previous := clash.PreviousBinaryPath()
+	if _, err := os.Stat(previous); err == nil {
+		binary := toCoreBinary(clash.InspectBinary(previous))
+		binaries.Previous = &binary
+	}
+	return binaries
+}
+
+// 转换替换内核时的错误
+func binaryAPIError(err error) error {
+	switch {
+	case errors.Is(err, clash.ErrInvalidBinary):
+		return newAPIError(http.StatusBadRequest, models.ErrCodeInvalidBinary, "%v", err)
+	case errors.Is(err, clash.ErrConfigTestFailed):
+		return newAPIError(http.StatusUnprocessableEntity, models.ErrCodeConfigTestFailed, "%v", err)
+	case errors.Is(err, clash.ErrNoPreviousBinary):
+		return newAPIError(http.StatusNotFound, models.ErrCodeNoPreviousBinary, "%v", err)
+	}
+	return err
+}
+
+// 替换内核后，内核正在运行时使用新内核重启，启动失败时换回原来的内核
+func restartOnNewBinary() (bool, error) {
+	if !clash.IsRunning() {
+		return false, nil
+	}
+
+	if err := restartCore(); err != nil {
+		log.Printf("新内核启动失败，换回原来的内核: %v", err)
+		if _, rollbackErr := clash.RollbackBinary(); rollbackErr != nil {
+			log.Printf("换回原来的内核失败: %v", rollbackErr)
+		} else if restartErr := restartCore(); restartErr != nil {
+			log.Printf("使用原来的内核启动失败: %v", restartErr)
+		}
+		return false, newAPIError(http.StatusInternalServerError, models.ErrCodeCoreStartFailed, "新内核启动失败，已换回原来的内核: %v", err)
+	}
+	return true, nil
+}
+
+// 发送替换内核的事件并返回结果
+func sendBinaryChangeResult(w http.ResponseWriter, info clash.BinaryInfo, rollback bool) {
+	events.Publish(events.CoreReplaced, map[string]any{
+		"version":  info.Version,
+		"rollback": rollback,
+	})
+
+	restarted, err := restartOnNewBinary()
+	if err != nil {
+		sendV2Error(w, err)
+		return
+	}
+
+	utils.SendDataResponse(w, http.StatusOK, models.CoreBinaryChangeResult{
+		CoreBinaries: coreBinaries(),
+		Restarted:    restarted,
+	})
+}
+
+// 处理获取内核文件信息请求
+func HandleV2GetCoreBinary(w http.ResponseWriter, r *http.Request) {
+	utils.SendDataResponse(w, http.StatusOK, coreBinaries())
+}
+
+// 处理上传内核请求，file字段为内核可执行文件或gzip压缩的文件
+// 新内核检查通过后替换当前内核，内核正在运行时重启
+func HandleV2UploadCoreBinary(w http.ResponseWriter, r *http.Request) {
+	audit.Record(r)
+	r.Body = http.MaxBytesReader(w, r.Body, maxBinaryUpload)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		sendV2Error(w, newAPIError(http.StatusBadRequest, models.ErrCodeInvalidRequest, "解析表单失败: %v", err))
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, handler, err := r.FormFile("file")
+	if err != nil {
+		sendV2Error(w, newAPIError(http.StatusBadRequest, models.ErrCodeInvalidRequest, "获取文件失败: %v", err))
+		return
+	}
+	defer file.Close()
+	audit.SetTarget(r, filepath.Base(handler.Filename))
+
+	info, err := clash.InstallBinary(file)
+	if err != nil {
+		sendV2Error(w, binaryAPIError(err))
+		return
+	}
+
+	sendBinaryChangeResult(w, info, false)
+}
+
+// 处理回滚内核请求，换回上一个版本，内核正在运行时重启
+func HandleV2RollbackCoreBinary(w http.ResponseWriter, r *http.Request) {
+	audit.Record(r)
+	info, err := clash.RollbackBinary()
+	if err != nil {
+		sendV2Error(w, binaryAPIError(err))
+		return
+	}
+
+	sendBinaryChangeResult(w, info, true)
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"net/http/httputil"
+
+	"clash-center/internal/config"
+	"clash-center/internal/utils"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// 处理内核控制器的反向代理请求，包括 /traffic、/logs、/connections 等WebSocket接口
+// 密钥由服务端注入，控制器只需监听本机地址
+func HandleCoreProxy(w http.ResponseWriter, r *http.Request) {
+	controller := config.GetControllerInfo()
+	path := "/" + chi.URLParam(r, "*")
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.Out.URL.Scheme = "http"
+			pr.Out.URL.Host = controller.Address
+			pr.Out.URL.Path = path
+			pr.Out.URL.RawPath = ""
+			pr.Out.Host = controller.Address
+
+			// 去掉访问clash-center使用的令牌，改为内核的密钥
+			query := pr.Out.URL.Query()
+			query.Del("token")
+			pr.Out.URL.RawQuery = query.Encode()
+
+			pr.Out.Header.Del("Authorization")
+			pr.Out.Header.Del("Cookie")
+			pr.Out.Header.Del("Origin")
+			if controller.Secret != "" {
+				pr.Out.Header.Set("Authorization", "Bearer "+controller.Secret)
+			}
+		},
+		// 流量和日志接口是持续输出的流，需要立即转发
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			log.Printf("代理Clash控制器请求失败: %v", err)
+			utils.SendErrorResponse(w, http.StatusBadGateway, "无法连接Clash控制器")
+		},
+	}
+
+	// 服务器关闭时断开持续输出的连接
+	ctx, cancel := withShutdown(r.Context())
+	defer cancel()
+	proxy.ServeHTTP(w, r.WithContext(ctx))
+}
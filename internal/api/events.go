@@ -0,0 +1,93 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"clash-center/internal/events"
+)
+
+// SSE心跳间隔，避免代理服务器关闭空闲连接
+const eventsHeartbeatInterval = 30 * time.Second
+
+// 处理事件流请求，以Server-Sent Events格式推送状态变化
+// types参数可以按前缀筛选事件类型，如 types=core,subscription
+func HandleEvents(w http.ResponseWriter, r *http.Request) {
+	controller := http.NewResponseController(w)
+
+	// 断线重连时浏览器会带上最后收到的事件ID
+	lastID, _ := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64)
+
+	var prefixes []string
+	if types := r.URL.Query().Get("types"); types != "" {
+		for _, prefix := range strings.Split(types, ",") {
+			if prefix = strings.TrimSpace(prefix); prefix != "" {
+				prefixes = append(prefixes, prefix)
+			}
+		}
+	}
+
+	ch, unsubscribe := events.Subscribe(32, lastID)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// 关闭nginx等反向代理的缓冲
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if err := controller.Flush(); err != nil {
+		log.Printf("当前连接不支持事件流: %v", err)
+		return
+	}
+
+	heartbeat := time.NewTicker(eventsHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-shuttingDown:
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case event := <-ch:
+			if !matchEventType(event.Type, prefixes) {
+				continue
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data); err != nil {
+				return
+			}
+		}
+
+		if err := controller.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// 判断事件类型是否匹配筛选的前缀，未指定前缀时全部匹配
+func matchEventType(eventType string, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range prefixes {
+		if eventType == prefix || strings.HasPrefix(eventType, strings.TrimSuffix(prefix, ".")+".") {
+			return true
+		}
+	}
+	return false
+}
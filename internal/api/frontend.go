@@ -0,0 +1,88 @@
+package api
+
+import (
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"clash-center/frontend"
+)
+
+// 不回退到index.html的路径前缀，这些路径下不存在的地址返回404
+var nonSPAPrefixes = []string{"/api/", "/sub/", "/provider/", "/ruleset/"}
+
+// 前端文件服务，找不到的页面路由返回index.html，由前端路由处理
+type spaHandler struct {
+	fsys  fs.FS
+	files http.Handler
+}
+
+// 根据FrontendDir选择前端文件，未设置时使用嵌入的前端
+func newFrontendHandler() http.Handler {
+	var fsys fs.FS
+	if FrontendDir != "" {
+		fsys = os.DirFS(FrontendDir)
+		log.Printf("使用前端目录: %s", FrontendDir)
+	} else if frontend.Embedded {
+		fsys = frontend.Assets()
+	}
+
+	if fsys == nil {
+		return missingFrontend("未嵌入前端，请使用 --frontend-dir 指定前端目录")
+	}
+	if _, err := fs.Stat(fsys, "index.html"); err != nil {
+		return missingFrontend("前端目录中没有 index.html: " + FrontendDir)
+	}
+
+	return &spaHandler{fsys: fsys, files: http.FileServerFS(fsys)}
+}
+
+// 前端不可用时返回说明，而不是空白的404页面
+func missingFrontend(message string) http.Handler {
+	log.Printf("警告: %s", message)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "前端不可用: "+message, http.StatusNotFound)
+	})
+}
+
+func (h *spaHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+	if name == "" {
+		name = "index.html"
+	}
+
+	if info, err := fs.Stat(h.fsys, name); err == nil && !info.IsDir() {
+		w.Header().Set("Cache-Control", cacheControl(name))
+		h.files.ServeHTTP(w, r)
+		return
+	}
+
+	// 带扩展名的文件和服务端路径不回退，避免缺失的资源返回HTML
+	if path.Ext(name) != "" || hasNonSPAPrefix(r.URL.Path) {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeFileFS(w, r, h.fsys, "index.html")
+}
+
+// 构建生成的assets目录下的文件名包含内容哈希，可以长期缓存，其他文件每次验证
+func cacheControl(name string) string {
+	if strings.HasPrefix(name, "assets/") {
+		return "public, max-age=31536000, immutable"
+	}
+	return "no-cache"
+}
+
+func hasNonSPAPrefix(urlPath string) bool {
+	for _, prefix := range nonSPAPrefixes {
+		if strings.HasPrefix(urlPath, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"clash-center/internal/clash"
+	"clash-center/internal/config"
+	"clash-center/internal/metrics"
+	"clash-center/pkg/converter"
+)
+
+var registerMetricsOnce sync.Once
+
+// 注册抓取时动态生成的指标
+func registerMetrics() {
+	registerMetricsOnce.Do(func() {
+		metrics.RegisterCollector(collectCoreMetrics)
+		metrics.RegisterCollector(collectConfigMetrics)
+		metrics.RegisterCollector(collectControllerMetrics)
+	})
+}
+
+// 内核进程状态
+func collectCoreMetrics(e *metrics.Encoder) {
+	running := 0.0
+	if clash.IsRunning() {
+		running = 1
+	}
+	e.Gauge("clash_center_core_running", "Clash内核是否正在运行", running)
+	e.Gauge("clash_center_core_uptime_seconds", "Clash内核本次运行的时间", clash.Uptime().Seconds())
+
+	e.Header("clash_center_core_config_info", "Clash内核当前使用的配置文件", "gauge")
+	if config.OriginalConfigName != "" {
+		e.Sample("clash_center_core_config_info", 1, "config", configFileLabel(config.OriginalConfigName))
+	}
+}
+
+// 配置文件标签使用带扩展名的文件名，与 clash_center_config_nodes 一致
+func configFileLabel(name string) string {
+	if fileName, ok := config.ResolveConfigFile(name); ok {
+		return fileName
+	}
+	return filepath.Base(name)
+}
+
+// 配置文件的节点数量缓存，文件的修改时间和大小不变时不重新解析
+type nodeCountEntry struct {
+	modTime time.Time
+	size    int64
+	count   int
+}
+
+var nodeCountCache = struct {
+	sync.Mutex
+	entries map[string]nodeCountEntry
+}{entries: make(map[string]nodeCountEntry)}
+
+// 每个配置文件中的节点数量
+func collectConfigMetrics(e *metrics.Encoder) {
+	files, err := os.ReadDir(config.ConfigDir)
+	if err != nil {
+		return
+	}
+
+	nodeCountCache.Lock()
+	defer nodeCountCache.Unlock()
+
+	e.Header("clash_center_config_nodes", "配置文件中的节点数量", "gauge")
+	seen := make(map[string]bool)
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || (filepath.Ext(name) != ".yaml" && filepath.Ext(name) != ".yml") {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+		path := filepath.Join(config.ConfigDir, name)
+		seen[path] = true
+
+		entry, ok := nodeCountCache.entries[path]
+		if !ok || !entry.modTime.Equal(info.ModTime()) || entry.size != info.Size() {
+			configData, err := config.GetConfigInfo(name)
+			if err != nil {
+				delete(nodeCountCache.entries, path)
+				continue
+			}
+			entry = nodeCountEntry{modTime: info.ModTime(), size: info.Size(), count: len(converter.GetProxyList(configData))}
+			nodeCountCache.entries[path] = entry
+		}
+		e.Sample("clash_center_config_nodes", float64(entry.count), "config", name)
+	}
+
+	// 删除已不存在的配置文件
+	for path := range nodeCountCache.entries {
+		if !seen[path] {
+			delete(nodeCountCache.entries, path)
+		}
+	}
+}
+
+// 从控制器抓取流量、连接数和代理组延迟
+func collectControllerMetrics(e *metrics.Encoder) {
+	up := 0.0
+	defer func() {
+		e.Gauge("clash_center_controller_up", "Clash控制器是否可以访问", up)
+	}()
+
+	if !clash.IsRunning() {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	client := clash.NewControllerClient()
+	connections, err := client.Connections(ctx)
+	if err != nil {
+		return
+	}
+	proxies, err := client.Proxies(ctx)
+	if err != nil {
+		return
+	}
+	up = 1
+
+	e.Header("clash_center_upload_bytes_total", "内核本次运行的上传总流量", "counter")
+	e.Sample("clash_center_upload_bytes_total", float64(connections.UploadTotal))
+	e.Header("clash_center_download_bytes_total", "内核本次运行的下载总流量", "counter")
+	e.Sample("clash_center_download_bytes_total", float64(connections.DownloadTotal))
+	e.Gauge("clash_center_active_connections", "当前活动连接数", float64(len(connections.Connections)))
+
+	// 代理组当前选择节点的最近一次延迟，0表示超时，未测试的代理组不输出
+	names := make([]string, 0, len(proxies))
+	for name, proxy := range proxies {
+		if proxy.IsGroup() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	e.Header("clash_center_group_delay_milliseconds", "代理组当前选择节点的最近一次延迟", "gauge")
+	for _, name := range names {
+		group := proxies[name]
+		selected, ok := proxies[group.Now]
+		if !ok {
+			continue
+		}
+		if delay := selected.LastDelay(); delay >= 0 {
+			e.Sample("clash_center_group_delay_milliseconds", float64(delay), "group", name, "node", group.Now)
+		}
+	}
+}
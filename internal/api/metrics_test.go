@@ -0,0 +1,69 @@
+package api
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"clash-center/internal/config"
+	"clash-center/internal/metrics"
+)
+
+// 抓取一次全部指标
+func scrapeConfigMetrics(t *testing.T) string {
+	t.Helper()
+	registerMetrics()
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	return rec.Body.String()
+}
+
+func TestConfigMetrics(t *testing.T) {
+	useTempConfigDir(t)
+	path := filepath.Join(config.ConfigDir, "sub.yaml")
+	write := func(content string, modTime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	modTime := time.Now().Add(-time.Hour)
+	write("proxies:\n  - {name: a, type: ss}\n  - {name: b, type: ss}\n", modTime)
+
+	// 当前配置可以省略扩展名，两个指标使用相同的文件名标签
+	config.OriginalConfigName = "sub"
+	output := scrapeConfigMetrics(t)
+	for _, want := range []string{
+		`clash_center_config_nodes{config="sub.yaml"} 2`,
+		`clash_center_config_nodes{config="x.yaml"} 0`,
+		`clash_center_core_config_info{config="sub.yaml"} 1`,
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("输出中缺少 %s:\n%s", want, output)
+		}
+	}
+
+	// 修改时间和大小不变时使用缓存的结果
+	write("proxies:\n  - {name: c, type: ss}\n  - {name: d, type: ss}\n", modTime)
+	if output := scrapeConfigMetrics(t); !strings.Contains(output, `clash_center_config_nodes{config="sub.yaml"} 2`) {
+		t.Errorf("文件未变化时应使用缓存:\n%s", output)
+	}
+	write("proxies:\n  - {name: c, type: ss}\n", modTime)
+	if output := scrapeConfigMetrics(t); !strings.Contains(output, `clash_center_config_nodes{config="sub.yaml"} 1`) {
+		t.Errorf("文件变化后没有重新解析:\n%s", output)
+	}
+
+	// 删除的配置文件不再输出
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if output := scrapeConfigMetrics(t); strings.Contains(output, `clash_center_config_nodes{config="sub.yaml"}`) {
+		t.Errorf("删除的配置文件仍然输出:\n%s", output)
+	}
+}
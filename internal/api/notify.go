@@ -0,0 +1,143 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"clash-center/internal/config"
+	"clash-center/internal/models"
+	"clash-center/internal/notify"
+	"clash-center/internal/utils"
+)
+
+// 返回给客户端的通知设置中代替密码和令牌的值
+const maskedSecret = "********"
+
+// 隐藏通知设置中的SMTP密码和Telegram机器人令牌
+func maskNotifySecrets(settings models.NotifySettings) models.NotifySettings {
+	if settings.Telegram != nil && settings.Telegram.BotToken != "" {
+		telegram := *settings.Telegram
+		telegram.BotToken = maskedSecret
+		settings.Telegram = &telegram
+	}
+	if settings.SMTP != nil && settings.SMTP.Password != "" {
+		smtp := *settings.SMTP
+		smtp.Password = maskedSecret
+		settings.SMTP = &smtp
+	}
+	return settings
+}
+
+// 客户端提交隐藏后的值时，使用已保存的密码和令牌
+func restoreNotifySecrets(settings *models.NotifySettings, saved models.NotifySettings) {
+	if settings.Telegram != nil && settings.Telegram.BotToken == maskedSecret {
+		settings.Telegram.BotToken = ""
+		if saved.Telegram != nil {
+			settings.Telegram.BotToken = saved.Telegram.BotToken
+		}
+	}
+	if settings.SMTP != nil && settings.SMTP.Password == maskedSecret {
+		settings.SMTP.Password = ""
+		if saved.SMTP != nil {
+			settings.SMTP.Password = saved.SMTP.Password
+		}
+	}
+}
+
+// 处理获取通知设置的请求，密码和令牌以隐藏后的值返回
+func HandleGetNotifySettings(w http.ResponseWriter, r *http.Request) {
+	utils.SendSuccessResponse(w, "", map[string]any{
+		"settings": maskNotifySecrets(config.LoadAppConfig().Notify),
+	})
+}
+
+// 处理保存通知设置的请求
+func HandleSaveNotifySettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.SendErrorResponse(w, http.StatusMethodNotAllowed, "仅支持POST请求")
+		return
+	}
+
+	var settings models.NotifySettings
+	err := json.NewDecoder(r.Body).Decode(&settings)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("解析请求失败: %v", err))
+		return
+	}
+
+	if err := notify.ValidateSettings(settings); err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = config.UpdateAppConfig(func(appConfig *models.AppConfig) {
+		restoreNotifySecrets(&settings, appConfig.Notify)
+		appConfig.Notify = settings
+	})
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, fmt.Sprintf("保存通知设置失败: %v", err))
+		return
+	}
+
+	log.Printf("通知设置已更新，共 %d 个通知渠道\n", len(notify.Notifiers(settings)))
+	utils.SendSuccessResponse(w, "通知设置已更新")
+}
+
+// 处理发送测试通知的请求，请求体中提供设置时使用该设置，否则使用已保存的设置
+func HandleTestNotify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.SendErrorResponse(w, http.StatusMethodNotAllowed, "仅支持POST请求")
+		return
+	}
+
+	var requestBody struct {
+		Settings *models.NotifySettings `json:"settings"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			utils.SendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("解析请求失败: %v", err))
+			return
+		}
+	}
+
+	settings := config.LoadAppConfig().Notify
+	if requestBody.Settings != nil {
+		saved := settings
+		settings = *requestBody.Settings
+		restoreNotifySecrets(&settings, saved)
+	}
+	if err := notify.ValidateSettings(settings); err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if len(notify.Notifiers(settings)) == 0 {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "没有配置任何通知渠道")
+		return
+	}
+
+	results := notify.Send(settings, notify.Notification{
+		Type:    notify.TypeTest,
+		Title:   "测试通知",
+		Message: "这是一条来自clash-center的测试通知",
+		Time:    time.Now(),
+	})
+
+	failed := 0
+	for _, result := range results {
+		if result.Error != "" {
+			failed++
+		}
+	}
+
+	message := "测试通知已发送"
+	if failed > 0 {
+		message = fmt.Sprintf("%d 个通知渠道发送失败", failed)
+	}
+	utils.SendSuccessResponse(w, message, map[string]any{
+		"results": results,
+	})
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"clash-center/internal/config"
+	"clash-center/internal/models"
+)
+
+// 使用临时的应用配置文件
+func useTempAppConfig(t *testing.T) {
+	t.Helper()
+	saved := config.AppConfigPath
+	t.Cleanup(func() { config.AppConfigPath = saved })
+	config.AppConfigPath = filepath.Join(t.TempDir(), "app_config.json")
+}
+
+func TestNotifySettingsMasksSecrets(t *testing.T) {
+	useTempAppConfig(t)
+
+	status, result := callHandler(t, HandleSaveNotifySettings, http.MethodPost, "/api/notify", `{
+		"telegram": {"bot_token": "123:abc", "chat_id": "42"},
+		"smtp": {"host": "smtp.example.test", "port": 465, "username": "u", "password": "p@ss", "from": "a@example.test", "to": ["b@example.test"]}
+	}`)
+	if status != http.StatusOK {
+		t.Fatalf("保存设置返回 %d: %v", status, result)
+	}
+
+	_, result = callHandler(t, HandleGetNotifySettings, http.MethodGet, "/api/notify", "")
+	settings := result["settings"].(map[string]any)
+	telegram := settings["telegram"].(map[string]any)
+	smtp := settings["smtp"].(map[string]any)
+	if telegram["bot_token"] != maskedSecret || smtp["password"] != maskedSecret {
+		t.Fatalf("返回的设置中包含密码或令牌: %v", settings)
+	}
+	if telegram["chat_id"] != "42" || smtp["username"] != "u" {
+		t.Errorf("其他设置被修改: %v", settings)
+	}
+
+	// 原样提交隐藏后的值时保留已保存的密码和令牌
+	callHandler(t, HandleSaveNotifySettings, http.MethodPost, "/api/notify", `{
+		"telegram": {"bot_token": "********", "chat_id": "43"},
+		"smtp": {"host": "smtp.example.test", "port": 465, "username": "u", "password": "********", "from": "a@example.test", "to": ["b@example.test"]}
+	}`)
+	saved := config.LoadAppConfig().Notify
+	if saved.Telegram.BotToken != "123:abc" || saved.Telegram.ChatID != "43" || saved.SMTP.Password != "p@ss" {
+		t.Fatalf("保存的设置为 %+v %+v", saved.Telegram, saved.SMTP)
+	}
+
+	// 提交新值时替换
+	callHandler(t, HandleSaveNotifySettings, http.MethodPost, "/api/notify", `{
+		"telegram": {"bot_token": "456:def", "chat_id": "43"},
+		"smtp": {"host": "smtp.example.test", "port": 465, "from": "a@example.test", "to": ["b@example.test"]}
+	}`)
+	saved = config.LoadAppConfig().Notify
+	if saved.Telegram.BotToken != "456:def" || saved.SMTP.Password != "" {
+		t.Fatalf("保存的设置为 %+v %+v", saved.Telegram, saved.SMTP)
+	}
+}
+
+func TestTestNotifyUsesSavedSecret(t *testing.T) {
+	useTempAppConfig(t)
+
+	var paths []string
+	telegram := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+	}))
+	defer telegram.Close()
+
+	config.UpdateAppConfig(func(appConfig *models.AppConfig) {
+		appConfig.Notify.Telegram = &models.TelegramSettings{BotToken: "123:abc", ChatID: "42", APIURL: telegram.URL}
+	})
+
+	// 使用隐藏后的令牌测试修改过的设置
+	body := `{"settings": {"telegram": {"bot_token": "********", "chat_id": "43", "api_url": "` + telegram.URL + `"}}}`
+	status, result := callHandler(t, HandleTestNotify, http.MethodPost, "/api/notify/test", body)
+	if status != http.StatusOK || !strings.Contains(result["message"].(string), "已发送") {
+		t.Fatalf("测试通知返回 %d: %v", status, result)
+	}
+	if len(paths) != 1 || paths[0] != "/bot123:abc/sendMessage" {
+		t.Fatalf("Telegram收到的请求为 %v", paths)
+	}
+
+	// 没有配置渠道时返回错误
+	config.UpdateAppConfig(func(appConfig *models.AppConfig) { appConfig.Notify = models.NotifySettings{} })
+	status, _ = callHandler(t, HandleTestNotify, http.MethodPost, "/api/notify/test", "")
+	if status != http.StatusBadRequest {
+		t.Errorf("没有通知渠道时返回 %d", status)
+	}
+}
@@ -0,0 +1,370 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"clash-center/internal/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// 接口文档中的一个操作
+type apiOperation struct {
+	Method  string
+	Path    string // chi路由格式，末尾的*记为{path}参数
+	Tag     string
+	Summary string
+	Params  []apiParam // 查询参数
+	// JSON请求体类型的零值，nil表示没有JSON请求体
+	Request any
+	// 以multipart/form-data上传file字段，列出额外的文本字段
+	Upload []string
+	// 响应数据类型的零值：v1接口合并到响应顶层，v2接口放在data字段
+	Response any
+	// 非JSON响应的内容类型，如 text/yaml
+	Raw string
+	// 响应状态码，默认为200
+	Status int
+	// 不需要认证的接口
+	Public bool
+}
+
+// 查询参数
+type apiParam struct {
+	Name        string
+	Description string
+	Required    bool
+}
+
+// v2接口的所有错误码
+var apiErrorCodes = []string{
+	models.ErrCodeInvalidRequest,
+	models.ErrCodeUnauthorized,
+	models.ErrCodeForbidden,
+	models.ErrCodeNotFound,
+	models.ErrCodeMethodNotAllowed,
+	models.ErrCodeConfigNotFound,
+	models.ErrCodeConfigExists,
+	models.ErrCodeConfigInUse,
+	models.ErrCodeInvalidConfig,
+	models.ErrCodeNoSource,
+	models.ErrCodeFetchFailed,
+	models.ErrCodeCoreStartFailed,
+	models.ErrCodeCoreStopFailed,
+	models.ErrCodeInvalidBinary,
+	models.ErrCodeConfigTestFailed,
+	models.ErrCodeNoPreviousBinary,
+	models.ErrCodeInternal,
+}
+
+var openAPIDocument = sync.OnceValues(func() ([]byte, error) {
+	return json.MarshalIndent(buildOpenAPI(apiOperations), "", "  ")
+})
+
+// 处理获取OpenAPI文档请求
+func HandleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	document, err := openAPIDocument()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(document)
+}
+
+// 生成OpenAPI 3文档
+func buildOpenAPI(operations []apiOperation) map[string]any {
+	schemas := newSchemaRegistry()
+	schemas.add(reflect.TypeOf(models.APIResponse{}))
+	schemas.components["APIResponse"]["properties"].(map[string]any)["code"] = map[string]any{
+		"type": "string",
+		"enum": apiErrorCodes,
+	}
+
+	paths := map[string]map[string]any{}
+	for _, op := range operations {
+		docPath := openAPIPath(op.Path)
+		if paths[docPath] == nil {
+			paths[docPath] = map[string]any{}
+		}
+		paths[docPath][strings.ToLower(op.Method)] = buildOperation(op, schemas)
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "clash-center API",
+			"version":     "2",
+			"description": "未配置用户和令牌时所有接口都不需要认证。/api/v2 以外的接口为兼容旧版前端保留，成功响应的数据字段直接合并在顶层。",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas.components,
+			"securitySchemes": map[string]any{
+				"basicAuth":  map[string]any{"type": "http", "scheme": "basic"},
+				"bearerAuth": map[string]any{"type": "http", "scheme": "bearer"},
+				"tokenQuery": map[string]any{"type": "apiKey", "in": "query", "name": "token"},
+			},
+		},
+		"security": []any{
+			map[string]any{"basicAuth": []string{}},
+			map[string]any{"bearerAuth": []string{}},
+			map[string]any{"tokenQuery": []string{}},
+		},
+	}
+}
+
+// 生成单个操作的文档
+func buildOperation(op apiOperation, schemas *schemaRegistry) map[string]any {
+	v2 := strings.HasPrefix(op.Path, "/api/v2/")
+	operation := map[string]any{
+		"tags":        []string{op.Tag},
+		"summary":     op.Summary,
+		"operationId": operationID(op),
+	}
+	if op.Public {
+		operation["security"] = []any{}
+	}
+
+	var parameters []any
+	for _, name := range pathParams(op.Path) {
+		parameters = append(parameters, map[string]any{
+			"name": name, "in": "path", "required": true, "schema": map[string]any{"type": "string"},
+		})
+	}
+	for _, param := range op.Params {
+		parameters = append(parameters, map[string]any{
+			"name": param.Name, "in": "query", "required": param.Required, "description": param.Description,
+			"schema": map[string]any{"type": "string"},
+		})
+	}
+	if len(parameters) > 0 {
+		operation["parameters"] = parameters
+	}
+
+	content := map[string]any{}
+	if op.Request != nil {
+		content["application/json"] = map[string]any{"schema": schemas.schema(reflect.TypeOf(op.Request))}
+	}
+	if op.Upload != nil {
+		properties := map[string]any{"file": map[string]any{"type": "string", "format": "binary"}}
+		for _, field := range op.Upload {
+			properties[field] = map[string]any{"type": "string"}
+		}
+		content["multipart/form-data"] = map[string]any{"schema": map[string]any{
+			"type": "object", "properties": properties, "required": []string{"file"},
+		}}
+	}
+	if len(content) > 0 {
+		operation["requestBody"] = map[string]any{"required": true, "content": content}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := map[string]any{"description": http.StatusText(status)}
+	switch {
+	case op.Raw != "":
+		success["content"] = map[string]any{op.Raw: map[string]any{"schema": map[string]any{"type": "string"}}}
+	case status == http.StatusNoContent:
+	default:
+		success["content"] = map[string]any{"application/json": map[string]any{"schema": responseSchema(op, v2, schemas)}}
+	}
+
+	operation["responses"] = map[string]any{
+		strconv.Itoa(status): success,
+		"default": map[string]any{
+			"description": "错误响应，v2接口包含错误码",
+			"content": map[string]any{"application/json": map[string]any{
+				"schema": map[string]any{"$ref": "#/components/schemas/APIResponse"},
+			}},
+		},
+	}
+	return operation
+}
+
+// 成功响应的结构
+func responseSchema(op apiOperation, v2 bool, schemas *schemaRegistry) map[string]any {
+	envelope := map[string]any{"$ref": "#/components/schemas/APIResponse"}
+	if op.Response == nil {
+		return envelope
+	}
+
+	data := schemas.schema(reflect.TypeOf(op.Response))
+	if v2 {
+		data = map[string]any{"type": "object", "properties": map[string]any{"data": data}}
+	}
+	return map[string]any{"allOf": []any{envelope, data}}
+}
+
+var pathParamPattern = regexp.MustCompile(`\{([^}]+)\}`)
+
+// 将chi路由转换为文档路径
+func openAPIPath(pattern string) string {
+	if strings.HasSuffix(pattern, "/*") {
+		return strings.TrimSuffix(pattern, "*") + "{path}"
+	}
+	return pattern
+}
+
+// 路径中的参数名
+func pathParams(pattern string) []string {
+	var names []string
+	for _, match := range pathParamPattern.FindAllStringSubmatch(openAPIPath(pattern), -1) {
+		names = append(names, match[1])
+	}
+	return names
+}
+
+// 根据请求方法和路径生成操作ID，如 post_api_v2_core_start
+func operationID(op apiOperation) string {
+	words := strings.FieldsFunc(strings.ToLower(op.Method)+" "+openAPIPath(op.Path), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	})
+	return strings.Join(words, "_")
+}
+
+// 根据Go类型生成JSON Schema，具名结构体放在components中
+type schemaRegistry struct {
+	components map[string]map[string]any
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{components: map[string]map[string]any{}}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// 返回类型对应的结构，具名结构体返回引用
+func (s *schemaRegistry) schema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		return map[string]any{"$ref": "#/components/schemas/" + s.add(t)}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		return s.object(t)
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": s.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": s.schema(t.Elem())}
+	}
+	// any类型可以是任意值
+	return map[string]any{}
+}
+
+// 将具名结构体加入components，返回名称
+// 其他包中的类型加上包名前缀，如 AuditEntry
+func (s *schemaRegistry) add(t reflect.Type) string {
+	name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+	if pkg := path.Base(t.PkgPath()); pkg != "models" && pkg != "api" {
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	if _, ok := s.components[name]; !ok {
+		// 先占位，避免结构体引用自身时无限递归
+		s.components[name] = map[string]any{}
+		s.components[name] = s.object(t)
+	}
+	return name
+}
+
+// 按json标签生成结构体的属性，匿名嵌入的结构体展开到当前层级
+func (s *schemaRegistry) object(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			for key, value := range s.object(field.Type)["properties"].(map[string]any) {
+				properties[key] = value
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = s.schema(field.Type)
+	}
+	return map[string]any{"type": "object", "properties": properties}
+}
+
+// 检查已注册的路由是否都出现在接口文档中，返回缺少的路由
+func missingOpenAPIRoutes(routes chi.Routes, operations []apiOperation) []string {
+	documented := map[string]bool{}
+	for _, op := range operations {
+		documented[op.Method+" "+openAPIPath(op.Path)] = true
+		documented["* "+openAPIPath(op.Path)] = true
+	}
+
+	// 不限制请求方法的路由（如静态文件）在chi中注册为所有方法，只要求文档中至少有一个方法
+	methods := map[string][]string{}
+	chi.Walk(routes, func(method, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		route = strings.ReplaceAll(route, "/*/", "/")
+		methods[route] = append(methods[route], method)
+		return nil
+	})
+
+	var missing []string
+	for route, list := range methods {
+		docPath := openAPIPath(route)
+		if len(list) >= len(allRouteMethods) {
+			if !documented["* "+docPath] {
+				missing = append(missing, "* "+route)
+			}
+			continue
+		}
+		for _, method := range list {
+			if !documented[method+" "+docPath] {
+				missing = append(missing, method+" "+route)
+			}
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// chi为不限制方法的路由注册的所有方法
+var allRouteMethods = []string{
+	http.MethodConnect, http.MethodDelete, http.MethodGet, http.MethodHead, http.MethodOptions,
+	http.MethodPatch, http.MethodPost, http.MethodPut, http.MethodTrace,
+}
+
+// 启动时检查接口文档是否完整
+func checkOpenAPIRoutes(routes chi.Routes) {
+	for _, route := range missingOpenAPIRoutes(routes, apiOperations) {
+		log.Printf("警告: 接口文档中缺少路由 %s\n", route)
+	}
+}
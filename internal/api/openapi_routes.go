@@ -0,0 +1,276 @@
+package api
+
+import (
+	"clash-center/internal/audit"
+	"clash-center/internal/models"
+	"clash-center/internal/notify"
+)
+
+// v1接口的请求和响应大多没有对应的类型，这里用匿名结构体描述，多个接口共用的结构单独定义
+
+type configPathRequest struct {
+	ConfigPath string `json:"configPath"`
+}
+
+type needRestartResponse struct {
+	NeedRestart bool `json:"needRestart"`
+}
+
+type addedConfigResponse struct {
+	Path string `json:"path"`
+	Name string `json:"name"`
+}
+
+type autoStartBody struct {
+	AutoStart bool `json:"autoStart"`
+}
+
+type subTokenResponse struct {
+	Token string `json:"token"`
+}
+
+// 连接过滤条件的查询参数
+var connectionFilterParams = []apiParam{
+	{Name: "host", Description: "按目标主机筛选（包含匹配）"},
+	{Name: "process", Description: "按进程名筛选（包含匹配）"},
+	{Name: "rule", Description: "按规则筛选（包含匹配）"},
+	{Name: "chain", Description: "按出站节点筛选（包含匹配）"},
+}
+
+// 内核控制器代理支持的请求方法
+var coreProxyOperations = func() []apiOperation {
+	var operations []apiOperation
+	for _, method := range []string{"GET", "POST", "PUT", "PATCH", "DELETE"} {
+		operations = append(operations, apiOperation{
+			Method: method, Path: "/api/core/*", Tag: "core",
+			Summary: "转发请求到内核控制器，自动附加控制器密钥。未启用认证时只允许本机访问", Raw: "application/json",
+		})
+	}
+	return operations
+}()
+
+// 所有接口，新增路由时需要同步添加，启动时会检查是否有遗漏
+var apiOperations = append([]apiOperation{
+	// 配置文件相关
+	{Method: "GET", Path: "/api/configs", Tag: "configs", Summary: "获取配置文件列表",
+		Response: struct {
+			Data    []models.ConfigFile `json:"data"`
+			Current string              `json:"current"`
+			Status  bool                `json:"status"`
+		}{}},
+	{Method: "POST", Path: "/api/switch", Tag: "configs", Summary: "切换配置文件并重启内核",
+		Request: configPathRequest{}},
+	{Method: "POST", Path: "/api/updateconfigname", Tag: "configs", Summary: "修改配置文件显示名称",
+		Request: struct {
+			ConfigPath string `json:"configPath"`
+			ConfigName string `json:"configName"`
+		}{}},
+	{Method: "POST", Path: "/api/upload", Tag: "configs", Summary: "上传配置文件", Upload: []string{}},
+	{Method: "POST", Path: "/api/save-config", Tag: "configs", Summary: "保存编辑后的配置内容",
+		Request: struct {
+			Path    string `json:"path"`
+			Content string `json:"content"`
+		}{},
+		Response: struct {
+			Restarted bool `json:"restarted"`
+		}{}},
+	{Method: "GET", Path: "/api/config-content", Tag: "configs", Summary: "获取去掉元数据的配置内容",
+		Params: []apiParam{{Name: "path", Description: "配置文件名", Required: true}},
+		Response: struct {
+			Content string `json:"content"`
+		}{}},
+	{Method: "POST", Path: "/api/add-from-url", Tag: "configs", Summary: "从订阅URL添加配置",
+		Request: struct {
+			URL        string                  `json:"url"`
+			ConfigName string                  `json:"configName"`
+			FileName   string                  `json:"fileName"`
+			RawConfig  string                  `json:"rawConfig"`
+			Filter     models.NodeFilter       `json:"filter"`
+			Fetch      models.FetchOptions     `json:"fetch"`
+			Provider   *models.ProviderOptions `json:"provider"`
+		}{},
+		Response: addedConfigResponse{}},
+	{Method: "POST", Path: "/api/update-from-url", Tag: "configs", Summary: "从订阅源更新配置",
+		Request: struct {
+			ConfigPath string               `json:"configPath"`
+			RawConfig  string               `json:"rawConfig"`
+			Filter     *models.NodeFilter   `json:"filter"`
+			Fetch      *models.FetchOptions `json:"fetch"`
+		}{},
+		Response: needRestartResponse{}},
+	{Method: "POST", Path: "/api/add-aggregate", Tag: "configs", Summary: "添加聚合配置",
+		Request: struct {
+			ConfigName string                   `json:"configName"`
+			FileName   string                   `json:"fileName"`
+			Sources    []models.AggregateSource `json:"sources"`
+		}{},
+		Response: addedConfigResponse{}},
+	{Method: "POST", Path: "/api/provider-mode", Tag: "configs", Summary: "设置provider模式",
+		Request: struct {
+			ConfigPath string                 `json:"configPath"`
+			Provider   models.ProviderOptions `json:"provider"`
+		}{},
+		Response: needRestartResponse{}},
+	{Method: "POST", Path: "/api/delete-config", Tag: "configs", Summary: "删除配置文件",
+		Request: configPathRequest{}},
+
+	// Clash控制相关
+	{Method: "GET", Path: "/api/status", Tag: "core", Summary: "获取内核运行状态",
+		Response: struct {
+			Running  bool   `json:"running"`
+			Current  string `json:"current"`
+			PID      int    `json:"pid"`
+			Adopted  bool   `json:"adopted"`  // 内核是启动时接管的已在运行的进程
+			Conflict string `json:"conflict"` // 控制器地址被其他程序占用时的说明
+		}{}},
+	{Method: "POST", Path: "/api/start", Tag: "core", Summary: "启动内核"},
+	{Method: "POST", Path: "/api/stop", Tag: "core", Summary: "停止内核"},
+	{Method: "POST", Path: "/api/restart", Tag: "core", Summary: "重启内核"},
+	{Method: "GET", Path: "/api/controlinfo", Tag: "core", Summary: "获取内核控制器信息",
+		Response: struct {
+			Port  string `json:"port"`
+			Proxy string `json:"proxy"`
+		}{}},
+	{Method: "GET", Path: "/api/events", Tag: "core", Summary: "以Server-Sent Events推送事件，支持Last-Event-ID续传",
+		Params: []apiParam{{Name: "types", Description: "按前缀筛选事件类型，逗号分隔，如 core,subscription"}},
+		Raw:    "text/event-stream"},
+
+	// 代理组和节点相关
+	{Method: "GET", Path: "/api/groups", Tag: "proxies", Summary: "获取代理组和节点",
+		Response: struct {
+			Groups []models.ProxyGroup `json:"groups"`
+		}{}},
+	{Method: "POST", Path: "/api/groups/select", Tag: "proxies", Summary: "切换代理组选择的节点",
+		Request: struct {
+			Group string `json:"group"`
+			Name  string `json:"name"`
+		}{}},
+	{Method: "POST", Path: "/api/groups/delay", Tag: "proxies", Summary: "测试代理组内所有节点的延迟",
+		Request: delayRequest{},
+		Response: struct {
+			Delays map[string]int `json:"delays"`
+		}{}},
+	{Method: "POST", Path: "/api/proxies/delay", Tag: "proxies", Summary: "测试单个节点的延迟，超时返回0",
+		Request: delayRequest{},
+		Response: struct {
+			Delay int `json:"delay"`
+		}{}},
+
+	// 连接相关
+	{Method: "GET", Path: "/api/connections", Tag: "connections", Summary: "获取当前连接，最新的在前",
+		Params: connectionFilterParams,
+		Response: struct {
+			Connections   []models.ConnectionInfo `json:"connections"`
+			UploadTotal   int64                   `json:"uploadTotal"`
+			DownloadTotal int64                   `json:"downloadTotal"`
+		}{}},
+	{Method: "GET", Path: "/api/connections/stats", Tag: "connections", Summary: "按规则或出站节点汇总连接",
+		Params: append([]apiParam{{Name: "by", Description: "rule（默认）或 chain"}}, connectionFilterParams...),
+		Response: struct {
+			By    string                  `json:"by"`
+			Stats []models.ConnectionStat `json:"stats"`
+		}{}},
+	{Method: "POST", Path: "/api/connections/close", Tag: "connections", Summary: "按ID、过滤条件或全部关闭连接",
+		Request: struct {
+			IDs    []string                `json:"ids"`
+			Filter models.ConnectionFilter `json:"filter"`
+			All    bool                    `json:"all"`
+		}{},
+		Response: struct {
+			Closed int      `json:"closed"`
+			Failed []string `json:"failed"` // 关闭失败的连接ID，不为空时返回502
+		}{}},
+
+	// 应用设置相关
+	{Method: "POST", Path: "/api/autostart", Tag: "settings", Summary: "修改自动启动设置",
+		Request: autoStartBody{}, Response: autoStartBody{}},
+	{Method: "GET", Path: "/api/getautostart", Tag: "settings", Summary: "获取自动启动设置",
+		Response: autoStartBody{}},
+
+	// 通知相关
+	{Method: "GET", Path: "/api/notify", Tag: "notify", Summary: "获取通知设置，SMTP密码和Telegram令牌以 ******** 代替，原样提交时保留已保存的值",
+		Response: struct {
+			Settings models.NotifySettings `json:"settings"`
+		}{}},
+	{Method: "POST", Path: "/api/notify", Tag: "notify", Summary: "保存通知设置",
+		Request: models.NotifySettings{}},
+	{Method: "POST", Path: "/api/notify/test", Tag: "notify", Summary: "发送测试通知，未提供设置时使用已保存的设置",
+		Request: struct {
+			Settings *models.NotifySettings `json:"settings"`
+		}{},
+		Response: struct {
+			Results []notify.Result `json:"results"`
+		}{}},
+
+	// 审计日志
+	{Method: "GET", Path: "/api/audit", Tag: "audit", Summary: "分页获取审计日志，最新的在前",
+		Params: []apiParam{{Name: "page", Description: "页码，从1开始"}, {Name: "size", Description: "每页条数，默认50，最大500"}},
+		Response: struct {
+			Entries []audit.Entry `json:"entries"`
+			Total   int           `json:"total"`
+			Page    int           `json:"page"`
+			Size    int           `json:"size"`
+		}{}},
+
+	// 本地订阅相关
+	{Method: "GET", Path: "/api/sub-token", Tag: "subscription", Summary: "获取本地订阅令牌",
+		Response: subTokenResponse{}},
+	{Method: "POST", Path: "/api/sub-token/reset", Tag: "subscription", Summary: "重新生成本地订阅令牌",
+		Response: subTokenResponse{}},
+
+	{Method: "GET", Path: "/api/openapi.json", Tag: "docs", Summary: "获取OpenAPI文档", Raw: "application/json"},
+
+	// v2接口
+	{Method: "GET", Path: "/api/v2/configs", Tag: "v2", Summary: "获取配置文件列表",
+		Response: models.ConfigList{}},
+	{Method: "POST", Path: "/api/v2/configs", Tag: "v2", Summary: "添加配置，JSON请求从订阅或聚合来源生成，multipart请求上传文件",
+		Request: models.CreateConfigRequest{}, Upload: []string{"name"},
+		Response: models.ConfigChangeResult{}, Status: 201},
+	{Method: "GET", Path: "/api/v2/configs/{name}", Tag: "v2", Summary: "获取配置详情，名称可以省略扩展名",
+		Response: models.ConfigDetail{}},
+	{Method: "PUT", Path: "/api/v2/configs/{name}", Tag: "v2", Summary: "替换配置内容，当前配置正在运行时重启内核",
+		Request: models.ReplaceConfigRequest{}, Response: models.ConfigChangeResult{}},
+	{Method: "PATCH", Path: "/api/v2/configs/{name}", Tag: "v2", Summary: "修改配置元数据",
+		Request: models.PatchConfigRequest{}, Response: models.ConfigChangeResult{}},
+	{Method: "DELETE", Path: "/api/v2/configs/{name}", Tag: "v2", Summary: "删除配置，不能删除正在使用的配置",
+		Status: 204},
+	{Method: "POST", Path: "/api/v2/configs/{name}:refresh", Tag: "v2", Summary: "从订阅源更新配置",
+		Request: models.RefreshConfigRequest{}, Response: models.ConfigChangeResult{}},
+	{Method: "POST", Path: "/api/v2/configs/{name}:activate", Tag: "v2", Summary: "切换到该配置并重启内核，启动失败时保持原来的配置",
+		Response: models.ConfigChangeResult{}},
+	{Method: "GET", Path: "/api/v2/core", Tag: "v2", Summary: "获取内核运行状态",
+		Response: models.CoreStatus{}},
+	{Method: "POST", Path: "/api/v2/core:start", Tag: "v2", Summary: "启动内核",
+		Response: models.CoreStatus{}},
+	{Method: "POST", Path: "/api/v2/core:stop", Tag: "v2", Summary: "停止内核",
+		Response: models.CoreStatus{}},
+	{Method: "POST", Path: "/api/v2/core:restart", Tag: "v2", Summary: "重启内核",
+		Response: models.CoreStatus{}},
+	{Method: "GET", Path: "/api/v2/core/logs", Tag: "v2", Summary: "获取内核最近的输出",
+		Params:   []apiParam{{Name: "lines", Description: "返回的行数，默认返回全部保留的输出"}},
+		Response: models.CoreLogs{}},
+	{Method: "GET", Path: "/api/v2/core/binary", Tag: "v2", Summary: "获取当前内核和可回滚的上一个版本的信息",
+		Response: models.CoreBinaries{}},
+	{Method: "POST", Path: "/api/v2/core/binary", Tag: "v2", Summary: "上传内核可执行文件或gzip压缩的文件，检查架构和当前配置后替换，正在运行时重启。未启用认证时只允许本机访问",
+		Upload: []string{}, Response: models.CoreBinaryChangeResult{}},
+	{Method: "POST", Path: "/api/v2/core/binary:rollback", Tag: "v2", Summary: "换回上一个版本的内核，正在运行时重启。未启用认证时只允许本机访问",
+		Response: models.CoreBinaryChangeResult{}},
+	{Method: "GET", Path: "/api/v2/settings", Tag: "v2", Summary: "获取应用设置",
+		Response: models.Settings{}},
+	{Method: "PATCH", Path: "/api/v2/settings", Tag: "v2", Summary: "修改应用设置",
+		Request: models.PatchSettingsRequest{}, Response: models.Settings{}},
+
+	// 不在/api下的接口
+	{Method: "GET", Path: "/metrics", Tag: "metrics", Summary: "Prometheus指标", Raw: "text/plain"},
+	{Method: "GET", Path: "/sub/{token}/{config}", Tag: "subscription", Summary: "本地订阅，供局域网内的其他设备使用",
+		Params: []apiParam{
+			{Name: "format", Description: "clash（默认）、base64 或 singbox"},
+			{Name: "default", Description: "为1或true时应用default.yaml"},
+		},
+		Raw: "text/yaml", Public: true},
+	{Method: "GET", Path: "/provider/{token}/{config}", Tag: "subscription", Summary: "provider模式下内核拉取的节点列表",
+		Raw: "text/yaml", Public: true},
+	{Method: "GET", Path: "/ruleset/{token}/{name}", Tag: "subscription", Summary: "共享的规则集文件",
+		Raw: "text/plain", Public: true},
+	{Method: "GET", Path: "/*", Tag: "frontend", Summary: "前端静态文件", Raw: "text/html"},
+}, coreProxyOperations...)
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestOpenAPICoversAllRoutes(t *testing.T) {
+	routes, ok := SetupRoutes(false).(chi.Routes)
+	if !ok {
+		t.Fatal("SetupRoutes 没有返回chi路由")
+	}
+	if missing := missingOpenAPIRoutes(routes, apiOperations); len(missing) > 0 {
+		t.Fatalf("接口文档中缺少路由: %v", missing)
+	}
+
+	// 从文档中去掉一个接口后应该能发现
+	var operations []apiOperation
+	for _, op := range apiOperations {
+		if op.Method != "GET" || op.Path != "/api/status" {
+			operations = append(operations, op)
+		}
+	}
+	missing := missingOpenAPIRoutes(routes, operations)
+	if len(missing) != 1 || missing[0] != "GET /api/status" {
+		t.Fatalf("缺少 GET /api/status 时返回 %v", missing)
+	}
+}
+
+// 错误码没有对应的类型，从models的源码中找出所有 ErrCode 开头的常量
+func TestOpenAPIErrorCodesComplete(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "../models/models.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := 0
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			value := spec.(*ast.ValueSpec)
+			for i, name := range value.Names {
+				if !strings.HasPrefix(name.Name, "ErrCode") || i >= len(value.Values) {
+					continue
+				}
+				lit, ok := value.Values[i].(*ast.BasicLit)
+				if !ok {
+					continue
+				}
+				code, _ := strconv.Unquote(lit.Value)
+				found++
+				if !slices.Contains(apiErrorCodes, code) {
+					t.Errorf("接口文档的错误码中缺少 models.%s (%s)", name.Name, code)
+				}
+			}
+		}
+	}
+	if found != len(apiErrorCodes) {
+		t.Errorf("models中有 %d 个错误码，接口文档中有 %d 个", found, len(apiErrorCodes))
+	}
+}
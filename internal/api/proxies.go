@@ -0,0 +1,271 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"clash-center/internal/audit"
+	"clash-center/internal/clash"
+	"clash-center/internal/mihomo"
+	"clash-center/internal/models"
+	"clash-center/internal/utils"
+)
+
+const (
+	// 默认的延迟测试地址和超时
+	defaultDelayTestURL = "https://www.gstatic.com/generate_204"
+	defaultDelayTimeout = 5000
+)
+
+// 延迟测试请求
+type delayRequest struct {
+	Group   string `json:"group"`
+	Name    string `json:"name"`
+	URL     string `json:"url"`
+	Timeout int    `json:"timeout"` // 毫秒
+}
+
+// 处理获取代理组列表的请求
+func HandleGetProxyGroups(w http.ResponseWriter, r *http.Request) {
+	if !clash.IsRunning() {
+		utils.SendErrorResponse(w, http.StatusConflict, "Clash未运行")
+		return
+	}
+
+	client := clash.NewControllerClient()
+	proxies, err := client.Proxies(r.Context())
+	if err != nil {
+		sendControllerError(w, "获取代理组失败", err)
+		return
+	}
+
+	// 记录每个节点所属的provider，获取失败时不影响列表本身
+	nodeProviders := make(map[string]string)
+	if providers, err := client.Providers(r.Context()); err == nil {
+		for name, provider := range providers {
+			// 配置中直接定义的节点位于内核内置的default provider（Compatible类型），不作为provider展示
+			if provider.VehicleType == "Compatible" {
+				continue
+			}
+			for _, proxy := range provider.Proxies {
+				nodeProviders[proxy.Name] = name
+			}
+		}
+	}
+
+	utils.SendSuccessResponse(w, "", map[string]any{
+		"groups": buildProxyGroups(proxies, nodeProviders),
+	})
+}
+
+// 按配置中的顺序整理代理组，GLOBAL的成员列表即为代理组的原始顺序
+func buildProxyGroups(proxies map[string]mihomo.Proxy, nodeProviders map[string]string) []models.ProxyGroup {
+	var names []string
+	seen := make(map[string]bool)
+	if global, ok := proxies["GLOBAL"]; ok {
+		for _, name := range global.All {
+			if proxy, ok := proxies[name]; ok && proxy.IsGroup() && !seen[name] {
+				names = append(names, name)
+				seen[name] = true
+			}
+		}
+	}
+	// 不在GLOBAL中的代理组（如隐藏的代理组）按名称排序，保证每次返回的顺序相同
+	var rest []string
+	for name, proxy := range proxies {
+		if proxy.IsGroup() && !seen[name] && name != "GLOBAL" {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	names = append(names, rest...)
+	if _, ok := proxies["GLOBAL"]; ok {
+		names = append(names, "GLOBAL")
+	}
+
+	groups := make([]models.ProxyGroup, 0, len(names))
+	for _, name := range names {
+		proxy := proxies[name]
+		group := models.ProxyGroup{
+			Name:    proxy.Name,
+			Type:    proxy.Type,
+			Now:     proxy.Now,
+			Hidden:  proxy.Hidden,
+			Members: make([]models.ProxyNode, 0, len(proxy.All)),
+		}
+
+		for _, memberName := range proxy.All {
+			member, ok := proxies[memberName]
+			if !ok {
+				member = mihomo.Proxy{Name: memberName}
+			}
+			group.Members = append(group.Members, toProxyNode(member, nodeProviders[memberName]))
+		}
+		groups = append(groups, group)
+	}
+
+	return groups
+}
+
+// 转换为API返回的节点信息
+func toProxyNode(proxy mihomo.Proxy, provider string) models.ProxyNode {
+	history := make([]models.DelayHistory, 0, len(proxy.History))
+	for _, item := range proxy.History {
+		history = append(history, models.DelayHistory{
+			Time:  item.Time.Format(time.RFC3339),
+			Delay: item.Delay,
+		})
+	}
+
+	return models.ProxyNode{
+		Name:     proxy.Name,
+		Type:     proxy.Type,
+		Provider: provider,
+		Alive:    proxy.Alive,
+		Delay:    proxy.LastDelay(),
+		History:  history,
+	}
+}
+
+// 处理在代理组中选择节点的请求
+func HandleSelectProxy(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.SendErrorResponse(w, http.StatusMethodNotAllowed, "仅支持POST请求")
+		return
+	}
+
+	// 解析请求体
+	var requestBody struct {
+		Group string `json:"group"`
+		Name  string `json:"name"`
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&requestBody)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("解析请求失败: %v", err))
+		return
+	}
+
+	audit.SetTarget(r, requestBody.Group)
+
+	if requestBody.Group == "" || requestBody.Name == "" {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "代理组和节点名称不能为空")
+		return
+	}
+
+	if !clash.IsRunning() {
+		utils.SendErrorResponse(w, http.StatusConflict, "Clash未运行")
+		return
+	}
+
+	client := clash.NewControllerClient()
+	if err := client.SelectProxy(r.Context(), requestBody.Group, requestBody.Name); err != nil {
+		sendControllerError(w, "选择节点失败", err)
+		return
+	}
+
+	log.Printf("代理组 %s 已切换到节点 %s\n", requestBody.Group, requestBody.Name)
+	clash.RememberSelection(requestBody.Group, requestBody.Name)
+
+	utils.SendSuccessResponse(w, "节点已切换")
+}
+
+// 处理代理组延迟测试请求，测试结果同时会更新内核中的延迟记录
+func HandleGroupDelay(w http.ResponseWriter, r *http.Request) {
+	request, ok := parseDelayRequest(w, r)
+	if !ok {
+		return
+	}
+	if request.Group == "" {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "代理组名称不能为空")
+		return
+	}
+
+	client := clash.NewControllerClient()
+	client.HTTPClient.Timeout = time.Duration(request.Timeout)*time.Millisecond + 10*time.Second
+
+	delays, err := client.GroupDelay(r.Context(), request.Group, request.URL, time.Duration(request.Timeout)*time.Millisecond)
+	if err != nil {
+		sendControllerError(w, "测试代理组延迟失败", err)
+		return
+	}
+
+	utils.SendSuccessResponse(w, "", map[string]any{
+		"delays": delays,
+	})
+}
+
+// 处理单个节点延迟测试请求
+func HandleProxyDelay(w http.ResponseWriter, r *http.Request) {
+	request, ok := parseDelayRequest(w, r)
+	if !ok {
+		return
+	}
+	if request.Name == "" {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "节点名称不能为空")
+		return
+	}
+
+	client := clash.NewControllerClient()
+	client.HTTPClient.Timeout = time.Duration(request.Timeout)*time.Millisecond + 10*time.Second
+
+	delay, err := client.ProxyDelay(r.Context(), request.Name, request.URL, time.Duration(request.Timeout)*time.Millisecond)
+	if err != nil {
+		// 内核在节点超时时返回504，作为测试结果而不是请求错误
+		var controllerErr *mihomo.Error
+		if errors.As(err, &controllerErr) && controllerErr.StatusCode == http.StatusGatewayTimeout {
+			utils.SendSuccessResponse(w, "节点超时", map[string]any{"delay": 0})
+			return
+		}
+		sendControllerError(w, "测试节点延迟失败", err)
+		return
+	}
+
+	utils.SendSuccessResponse(w, "", map[string]any{
+		"delay": delay,
+	})
+}
+
+// 解析延迟测试请求并填充默认值
+func parseDelayRequest(w http.ResponseWriter, r *http.Request) (delayRequest, bool) {
+	var request delayRequest
+	if r.Method != http.MethodPost {
+		utils.SendErrorResponse(w, http.StatusMethodNotAllowed, "仅支持POST请求")
+		return request, false
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("解析请求失败: %v", err))
+		return request, false
+	}
+
+	if !clash.IsRunning() {
+		utils.SendErrorResponse(w, http.StatusConflict, "Clash未运行")
+		return request, false
+	}
+
+	if request.URL == "" {
+		request.URL = defaultDelayTestURL
+	}
+	if request.Timeout <= 0 {
+		request.Timeout = defaultDelayTimeout
+	}
+	return request, true
+}
+
+// 返回控制器请求的错误，保留控制器的4xx状态码，认证失败和其余错误视为网关错误
+func sendControllerError(w http.ResponseWriter, message string, err error) {
+	status := http.StatusBadGateway
+	var controllerErr *mihomo.Error
+	if errors.As(err, &controllerErr) && controllerErr.StatusCode >= 400 && controllerErr.StatusCode < 500 &&
+		controllerErr.StatusCode != http.StatusUnauthorized && controllerErr.StatusCode != http.StatusForbidden {
+		status = controllerErr.StatusCode
+	}
+	utils.SendErrorResponse(w, status, fmt.Sprintf("%s: %v", message, err))
+}
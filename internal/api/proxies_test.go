@@ -0,0 +1,33 @@
+package api
+
+import (
+	"slices"
+	"testing"
+
+	"clash-center/internal/mihomo"
+)
+
+func TestBuildProxyGroupsOrder(t *testing.T) {
+	proxies := map[string]mihomo.Proxy{
+		"GLOBAL":   {Name: "GLOBAL", Type: "Selector", All: []string{"Proxy", "Auto", "DIRECT"}},
+		"Proxy":    {Name: "Proxy", Type: "Selector", All: []string{"Auto", "HK"}},
+		"Auto":     {Name: "Auto", Type: "URLTest", All: []string{"HK"}},
+		"Hidden B": {Name: "Hidden B", Type: "Selector", All: []string{"HK"}, Hidden: true},
+		"Hidden A": {Name: "Hidden A", Type: "Selector", All: []string{"HK"}, Hidden: true},
+		"Hidden C": {Name: "Hidden C", Type: "Fallback", All: []string{"HK"}, Hidden: true},
+		"HK":       {Name: "HK", Type: "Shadowsocks"},
+		"DIRECT":   {Name: "DIRECT", Type: "Direct"},
+	}
+	want := []string{"Proxy", "Auto", "Hidden A", "Hidden B", "Hidden C", "GLOBAL"}
+
+	// map的遍历顺序是随机的，多次调用检查顺序是否稳定
+	for range 20 {
+		var names []string
+		for _, group := range buildProxyGroups(proxies, nil) {
+			names = append(names, group.Name)
+		}
+		if !slices.Equal(names, want) {
+			t.Fatalf("代理组顺序为 %v，应为 %v", names, want)
+		}
+	}
+}
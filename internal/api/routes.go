@@ -3,11 +3,27 @@ package api
 import (
 	"net/http"
 
+	"clash-center/internal/audit"
+	"clash-center/internal/metrics"
+
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 )
 
+// 前端文件目录，为空时使用嵌入的前端
+var FrontendDir = "./frontend/dist"
+
+// 跨域设置
+var (
+	// 允许的来源，为空时只允许同源访问
+	CORSAllowedOrigins []string
+	// 是否允许携带凭据
+	CORSAllowCredentials = false
+	// 预检请求的缓存时间（秒）
+	CORSMaxAge = 300
+)
+
 // 设置API路由
 func SetupRoutes(verbose bool) http.Handler {
 	r := chi.NewRouter()
@@ -19,19 +35,28 @@ func SetupRoutes(verbose bool) http.Handler {
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.AllowContentType("application/json", "multipart/form-data"))
 
-	// CORS配置
-	corsMiddleware := cors.New(cors.Options{
-		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
-		AllowCredentials: true,
-		MaxAge:           300,
-	})
-	r.Use(corsMiddleware.Handler)
+	// CORS配置，没有设置来源时不添加跨域响应头，cors包在来源为空时会允许所有来源
+	if len(CORSAllowedOrigins) > 0 {
+		corsMiddleware := cors.New(cors.Options{
+			AllowedOrigins:   CORSAllowedOrigins,
+			AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+			AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+			ExposedHeaders:   []string{"Link"},
+			AllowCredentials: CORSAllowCredentials,
+			MaxAge:           CORSMaxAge,
+		})
+		r.Use(corsMiddleware.Handler)
+	}
 
 	// API路由
 	r.Route("/api", func(r chi.Router) {
+		// 审计放在认证之前，未通过认证的修改请求也会被记录
+		r.Use(audit.Middleware())
+		r.Use(authMiddleware)
+
+		// v2接口，v1接口保留以兼容旧版本前端
+		r.Route("/v2", setupV2Routes)
+
 		// 配置文件相关
 		r.Get("/configs", HandleGetConfigs)
 		r.Post("/switch", HandleSwitchConfig)
@@ -41,6 +66,8 @@ func SetupRoutes(verbose bool) http.Handler {
 		r.Get("/config-content", HandleGetConfigContent)
 		r.Post("/add-from-url", HandleAddConfigFromURL)
 		r.Post("/update-from-url", HandleUpdateConfigFromURL)
+		r.Post("/add-aggregate", HandleAddAggregateConfig)
+		r.Post("/provider-mode", HandleSetProviderMode)
 		r.Post("/delete-config", HandleDeleteConfig)
 
 		// Clash控制相关
@@ -49,15 +76,64 @@ func SetupRoutes(verbose bool) http.Handler {
 		r.Post("/stop", HandleStopClash)
 		r.Post("/restart", HandleRestartClash)
 		r.Get("/controlinfo", HandleGetControlInfo)
+		// 代理会注入控制器密钥，未启用认证时只允许本机访问
+		r.With(localOrAuthenticated).HandleFunc("/core/*", HandleCoreProxy)
+		r.Get("/events", HandleEvents)
+
+		// 代理组和节点相关
+		r.Get("/groups", HandleGetProxyGroups)
+		r.Post("/groups/select", HandleSelectProxy)
+		r.Post("/groups/delay", HandleGroupDelay)
+		r.Post("/proxies/delay", HandleProxyDelay)
+
+		// 连接相关
+		r.Get("/connections", HandleGetConnections)
+		r.Get("/connections/stats", HandleGetConnectionStats)
+		r.Post("/connections/close", HandleCloseConnections)
 
 		// 应用设置相关
 		r.Post("/autostart", HandleToggleAutoStart)
 		r.Get("/getautostart", HandleGetAutoStart)
+
+		// 通知相关
+		r.Get("/notify", HandleGetNotifySettings)
+		r.Post("/notify", HandleSaveNotifySettings)
+		r.Post("/notify/test", HandleTestNotify)
+
+		// 审计日志
+		r.Get("/audit", HandleGetAuditLog)
+
+		// 本地订阅相关
+		r.Get("/sub-token", HandleGetSubToken)
+		r.Post("/sub-token/reset", HandleResetSubToken)
+
+		// 接口文档
+		r.Get("/openapi.json", HandleOpenAPI)
 	})
 
-	// 静态文件服务
-	fileServer := http.FileServer(http.Dir("./frontend/dist"))
-	r.Handle("/*", fileServer)
+	// Prometheus指标，启用认证时同样需要认证
+	registerMetrics()
+	r.With(authMiddleware).Get("/metrics", metrics.Handler())
+
+	// 本地订阅，使用令牌鉴权
+	r.Get("/sub/{token}/{config}", HandleSubscription)
+	r.Get("/provider/{token}/{config}", HandleProviderFile)
+	r.Get("/ruleset/{token}/{name}", HandleRuleSetFile)
+
+	// 前端页面
+	r.With(authMiddleware).Handle("/*", newFrontendHandler())
+
+	// 新增路由时需要同步更新接口文档
+	checkOpenAPIRoutes(r)
 
 	return r
 }
+
+// 设置供内核访问的路由，启用HTTPS时通过本机HTTP地址提供，只包含使用令牌鉴权的provider和规则集
+func SetupCoreRoutes() http.Handler {
+	r := chi.NewRouter()
+	r.Use(middleware.Recoverer)
+	r.Get("/provider/{token}/{config}", HandleProviderFile)
+	r.Get("/ruleset/{token}/{name}", HandleRuleSetFile)
+	return r
+}
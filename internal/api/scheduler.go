@@ -0,0 +1,73 @@
+package api
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"clash-center/internal/config"
+	"clash-center/internal/converter"
+)
+
+// 自动更新订阅的间隔，0表示不自动更新
+var UpdateInterval time.Duration
+
+var schedulerOnce sync.Once
+
+// StartScheduler 按UpdateInterval定时更新所有订阅和聚合配置
+func StartScheduler() {
+	if UpdateInterval <= 0 {
+		return
+	}
+
+	schedulerOnce.Do(func() {
+		log.Printf("已启用订阅自动更新，间隔 %s", UpdateInterval)
+		go func() {
+			ticker := time.NewTicker(UpdateInterval)
+			defer ticker.Stop()
+			for range ticker.C {
+				updateAllSubscriptions()
+			}
+		}()
+	})
+}
+
+// 更新所有订阅，先更新订阅配置再重新生成聚合配置，当前配置有变化时重启内核
+func updateAllSubscriptions() {
+	files, err := config.GetConfigFiles()
+	if err != nil {
+		log.Printf("自动更新订阅失败: %v", err)
+		return
+	}
+
+	var subscriptions, aggregates []string
+	for _, file := range files {
+		switch {
+		case file.ConfigType == converter.AggregateConfigType:
+			aggregates = append(aggregates, file.Path)
+		case file.ConfigSrc != "":
+			subscriptions = append(subscriptions, file.Path)
+		}
+	}
+
+	needRestart := false
+	updated := 0
+	// 先更新所有订阅，聚合配置最后各生成一次，不随每个来源的更新重复生成
+	for _, fileName := range append(subscriptions, aggregates...) {
+		restart, err := refreshSingleConfig(fileName, "", nil, nil)
+		if err != nil {
+			log.Printf("自动更新 %s 失败: %v", fileName, err)
+			continue
+		}
+		needRestart = needRestart || restart
+		updated++
+	}
+	log.Printf("自动更新订阅完成，成功 %d 个，共 %d 个", updated, len(subscriptions)+len(aggregates))
+
+	if needRestart {
+		log.Printf("当前配置已更新，重启Clash")
+		if err := restartCore(); err != nil {
+			log.Printf("重启Clash失败: %v", err)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"clash-center/internal/config"
+	"clash-center/internal/converter"
+	"clash-center/internal/events"
+	"clash-center/internal/models"
+)
+
+func TestUpdateAllSubscriptionsBuildsAggregatesOnce(t *testing.T) {
+	useTempConfigDir(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "proxies:\n  - {name: "+r.URL.Path[1:]+", type: ss, server: "+r.URL.Path[1:]+".example.com, port: 443, cipher: aes-128-gcm, password: p}\n")
+	}))
+	defer server.Close()
+
+	for _, name := range []string{"a", "b"} {
+		content := "config_src: " + server.URL + "/" + name + "\nproxies:\n  - {name: old, type: ss, server: old-" + name + ".example.com, port: 443, cipher: aes-128-gcm, password: p}\n"
+		if err := os.WriteFile(filepath.Join(config.ConfigDir, name+".yaml"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sources := []models.AggregateSource{{File: "a.yaml"}, {File: "b.yaml"}}
+	if err := converter.SaveAggregateConfig("all.yaml", "聚合", sources); err != nil {
+		t.Fatal(err)
+	}
+
+	ch, cancel := events.Subscribe(100, 0)
+	defer cancel()
+	updateAllSubscriptions()
+
+	updated := map[string]int{}
+	for len(ch) > 0 {
+		event := <-ch
+		if event.Type == events.ConfigUpdated {
+			name, _ := event.Data["config"].(string)
+			updated[name]++
+		}
+	}
+	if updated["a.yaml"] != 1 || updated["b.yaml"] != 1 {
+		t.Errorf("订阅更新次数 = %v", updated)
+	}
+	// 两个来源都更新后聚合配置只生成一次
+	if updated["all.yaml"] != 1 {
+		t.Errorf("聚合配置生成了 %d 次，应为1次", updated["all.yaml"])
+	}
+
+	aggregate, err := config.GetConfigInfo("all.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, proxy := range aggregate["proxies"].([]any) {
+		if name := proxy.(map[string]any)["name"]; name != "[a] a" && name != "[b] b" {
+			t.Errorf("聚合配置中有未更新的节点 %v", name)
+		}
+	}
+}
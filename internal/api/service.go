@@ -0,0 +1,499 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"clash-center/internal/clash"
+	"clash-center/internal/config"
+	"clash-center/internal/converter"
+	"clash-center/internal/events"
+	"clash-center/internal/models"
+	"clash-center/internal/utils"
+	conv "clash-center/pkg/converter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// v1和v2接口共用的操作，错误使用apiError携带状态码和错误码
+
+// 带有HTTP状态码和错误码的错误
+type apiError struct {
+	Status   int
+	Code     string
+	Message  string
+	V1Status int // v1接口原有的状态码，为0时与Status相同
+}
+
+func (e *apiError) Error() string {
+	return e.Message
+}
+
+// 设置v1接口使用的状态码，v1接口保持原有的状态码不变
+func (e *apiError) withV1Status(status int) *apiError {
+	e.V1Status = status
+	return e
+}
+
+func newAPIError(status int, code, format string, args ...any) *apiError {
+	return &apiError{Status: status, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// 将错误转换为apiError，普通错误视为内部错误
+func toAPIError(err error) *apiError {
+	var e *apiError
+	if errors.As(err, &e) {
+		return e
+	}
+	return newAPIError(http.StatusInternalServerError, models.ErrCodeInternal, "%v", err)
+}
+
+// 按v1格式返回错误
+func sendServiceError(w http.ResponseWriter, err error) {
+	e := toAPIError(err)
+	status := e.Status
+	if e.V1Status != 0 {
+		status = e.V1Status
+	}
+	utils.SendErrorResponse(w, status, e.Message)
+}
+
+// 检查配置文件是否存在，fileName需要已经去掉目录部分
+func checkConfigExists(fileName string) error {
+	if _, err := os.Stat(filepath.Join(config.ConfigDir, fileName)); os.IsNotExist(err) {
+		return newAPIError(http.StatusNotFound, models.ErrCodeConfigNotFound, "配置文件不存在")
+	}
+	return nil
+}
+
+// 补全配置文件扩展名
+func normalizeConfigFileName(fileName, prefix string) string {
+	if fileName == "" {
+		// 生成文件名
+		return fmt.Sprintf("%s_%d.yaml", prefix, utils.GetTimestamp())
+	}
+	if !strings.HasSuffix(fileName, ".yaml") && !strings.HasSuffix(fileName, ".yml") {
+		// 确保文件名有正确的扩展名
+		fileName = fileName + ".yaml"
+	}
+	return filepath.Base(fileName)
+}
+
+// 切换配置文件并重启Clash
+func switchConfig(fileName string) error {
+	if err := checkConfigExists(fileName); err != nil {
+		return err
+	}
+
+	log.Printf("切换到配置文件: %s\n", fileName)
+
+	if _, err := stopCore(); err != nil {
+		return err
+	}
+
+	// 启动失败时恢复原来的配置，只有成功启动后才保存为当前配置
+	previous := config.OriginalConfigName
+	config.OriginalConfigName = fileName
+	if err := clash.StartClashWithCurrentConfig(); err != nil {
+		config.OriginalConfigName = previous
+		return newAPIError(http.StatusInternalServerError, models.ErrCodeCoreStartFailed, "启动Clash失败: %v", err)
+	}
+
+	config.UpdateLastConfig(fileName)
+	events.Publish(events.ConfigSwitched, map[string]any{"config": fileName})
+	return nil
+}
+
+// 启动Clash，已经在运行时返回false
+func startCore() (bool, error) {
+	if clash.IsRunning() {
+		return false, nil
+	}
+
+	if err := clash.StartClashWithCurrentConfig(); err != nil {
+		return false, newAPIError(http.StatusInternalServerError, models.ErrCodeCoreStartFailed, "启动Clash失败: %v", err)
+	}
+	return true, nil
+}
+
+// 停止Clash，没有运行时返回false
+func stopCore() (bool, error) {
+	if !clash.IsRunning() {
+		return false, nil
+	}
+
+	if err := clash.StopClash(); err != nil {
+		return false, newAPIError(http.StatusInternalServerError, models.ErrCodeCoreStopFailed, "停止Clash失败: %v", err)
+	}
+	return true, nil
+}
+
+// 重启Clash，没有运行时直接启动
+func restartCore() error {
+	if _, err := stopCore(); err != nil {
+		return err
+	}
+
+	if err := clash.StartClashWithCurrentConfig(); err != nil {
+		return newAPIError(http.StatusInternalServerError, models.ErrCodeCoreStartFailed, "启动Clash失败: %v", err)
+	}
+	return nil
+}
+
+// 修改配置文件的显示名称
+func renameConfig(fileName, configName string) error {
+	if err := checkConfigExists(fileName); err != nil {
+		return err
+	}
+
+	log.Printf("更新配置文件名称: %s -> %s\n", fileName, configName)
+
+	// 更新配置文件名称
+	if err := config.UpdateConfigName(fileName, configName); err != nil {
+		return fmt.Errorf("更新配置文件名称失败: %v", err)
+	}
+	events.Publish(events.ConfigUpdated, map[string]any{"config": fileName, "name": configName})
+	return nil
+}
+
+// 读取配置文件内容，去掉config_开头的元数据
+func readConfigContent(fileName string) (string, error) {
+	if err := checkConfigExists(fileName); err != nil {
+		return "", err
+	}
+
+	log.Printf("获取配置文件内容: %s\n", fileName)
+
+	// 读取配置文件内容
+	content, err := os.ReadFile(filepath.Join(config.ConfigDir, fileName))
+	if err != nil {
+		return "", fmt.Errorf("读取配置文件失败: %v", err)
+	}
+
+	// 解析YAML文件，删除以config_开头的配置项
+	var yamlConfig map[string]any
+	if err := yaml.Unmarshal(content, &yamlConfig); err != nil {
+		return "", fmt.Errorf("解析配置文件失败: %v", err)
+	}
+	config.StripMetadata(yamlConfig)
+
+	// 转换回YAML字符串
+	filteredContent, err := yaml.Marshal(yamlConfig)
+	if err != nil {
+		return "", fmt.Errorf("处理配置文件失败: %v", err)
+	}
+	return string(filteredContent), nil
+}
+
+// 保存编辑后的配置内容，保留原有的元数据，正在使用时重启Clash
+func saveConfigContent(fileName, content string) (bool, error) {
+	if err := checkConfigExists(fileName); err != nil {
+		return false, err
+	}
+	configPath := filepath.Join(config.ConfigDir, fileName)
+
+	log.Printf("开始编辑配置文件: %s\n", fileName)
+
+	// 读取原始配置文件，提取config_开头的字段
+	originalContent, err := os.ReadFile(configPath)
+	if err != nil {
+		return false, fmt.Errorf("读取原配置文件失败: %v", err)
+	}
+
+	// 解析原始YAML
+	var originalYamlConfig map[string]any
+	if err := yaml.Unmarshal(originalContent, &originalYamlConfig); err != nil {
+		return false, fmt.Errorf("解析原配置文件失败: %v", err)
+	}
+
+	// 提取所有config_开头的配置项
+	configPrefixItems := make(map[string]any)
+	for key, value := range originalYamlConfig {
+		if strings.HasPrefix(key, "config_") {
+			configPrefixItems[key] = value
+		}
+	}
+
+	// 解析新的配置内容
+	var newYamlConfig map[string]any
+	if content == "" {
+		// 如果内容为空，初始化一个空的map
+		newYamlConfig = make(map[string]any)
+	} else if err := yaml.Unmarshal([]byte(content), &newYamlConfig); err != nil {
+		return false, newAPIError(http.StatusBadRequest, models.ErrCodeInvalidConfig, "解析新配置内容失败: %v", err).withV1Status(http.StatusInternalServerError)
+	}
+
+	// 添加原来config_开头的配置项
+	maps.Copy(newYamlConfig, configPrefixItems)
+
+	// 转换回YAML字符串
+	mergedContent, err := yaml.Marshal(newYamlConfig)
+	if err != nil {
+		return false, fmt.Errorf("处理配置内容失败: %v", err)
+	}
+
+	// 写入合并后的内容
+	if err := os.WriteFile(configPath, mergedContent, 0644); err != nil {
+		return false, fmt.Errorf("写入配置失败: %v", err)
+	}
+	events.Publish(events.ConfigUpdated, map[string]any{"config": fileName})
+
+	// 重新生成引用此配置的聚合配置
+	converter.RefreshDependentAggregates(fileName)
+
+	// 如果正在使用此配置，需要重新加载Clash
+	if config.OriginalConfigName != fileName || !clash.IsRunning() {
+		log.Printf("配置已更新")
+		return false, nil
+	}
+
+	if _, err := stopCore(); err != nil {
+		return false, err
+	}
+	if err := clash.StartClashWithCurrentConfig(); err != nil {
+		return false, newAPIError(http.StatusInternalServerError, models.ErrCodeCoreStartFailed, "重启Clash失败: %v", err)
+	}
+	log.Printf("配置已更新并重启Clash")
+	return true, nil
+}
+
+// 删除配置文件，不能删除正在使用的配置
+func deleteConfig(fileName string) error {
+	if err := checkConfigExists(fileName); err != nil {
+		return err
+	}
+
+	// 检查是否为当前使用的配置文件
+	if fileName == config.OriginalConfigName {
+		return newAPIError(http.StatusBadRequest, models.ErrCodeConfigInUse, "无法删除正在使用的配置文件")
+	}
+
+	// 删除文件
+	configPath := filepath.Join(config.ConfigDir, fileName)
+	if err := os.Remove(configPath); err != nil {
+		return fmt.Errorf("删除配置文件失败: %v", err)
+	}
+
+	// 同时删除记住的代理组选择
+	config.SaveSelections(fileName, nil)
+
+	log.Printf("配置文件已删除: %s\n", configPath)
+	events.Publish(events.ConfigDeleted, map[string]any{"config": fileName})
+	return nil
+}
+
+// 保存上传的配置文件
+func saveUploadedConfig(fileName string, content io.Reader) error {
+	// 检查文件扩展名
+	ext := filepath.Ext(fileName)
+	if ext != ".yaml" && ext != ".yml" {
+		return newAPIError(http.StatusBadRequest, models.ErrCodeInvalidRequest, "仅支持yaml或yml格式的配置文件")
+	}
+
+	// 创建目标文件
+	dst, err := os.Create(filepath.Join(config.ConfigDir, fileName))
+	if err != nil {
+		return fmt.Errorf("创建文件失败: %v", err)
+	}
+	defer dst.Close()
+
+	// 复制上传的文件内容到目标文件
+	if _, err := io.Copy(dst, content); err != nil {
+		return fmt.Errorf("保存文件失败: %v", err)
+	}
+
+	log.Printf("上传配置文件成功: %s\n", fileName)
+	events.Publish(events.ConfigAdded, map[string]any{"config": fileName})
+
+	// 重新生成引用此配置的聚合配置
+	converter.RefreshDependentAggregates(fileName)
+	return nil
+}
+
+// ProcessConfigUpdate 处理配置更新的通用逻辑
+func ProcessConfigUpdate(fileName, rawConfig, configSrc, configName string, filter models.NodeFilter, fetch models.FetchOptions) error {
+	// 检查过滤规则
+	if err := conv.ValidateNodeFilter(filter); err != nil {
+		return newAPIError(http.StatusBadRequest, models.ErrCodeInvalidRequest, "%v", err).withV1Status(http.StatusInternalServerError)
+	}
+
+	// 如果有原始配置内容
+	if rawConfig != "" {
+		// 使用converter直接处理并保存前端提供的配置
+		if err := converter.SaveRawConfig([]byte(rawConfig), configSrc, configName, fileName, filter); err != nil {
+			return newAPIError(http.StatusInternalServerError, models.ErrCodeInvalidConfig, "%v", err)
+		}
+	} else {
+		// 从URL获取并更新配置
+		if err := converter.FetchAndSaveConfig(configSrc, fileName, configName, filter, fetch); err != nil {
+			return newAPIError(http.StatusInternalServerError, models.ErrCodeFetchFailed, "获取配置失败: %v", err)
+		}
+	}
+
+	// 保存订阅请求设置，以便后续更新时沿用
+	var fetchValue any
+	if !fetch.IsEmpty() {
+		fetchValue = fetch
+	}
+	if err := config.UpdateConfigField(fileName, "config_fetch", fetchValue); err != nil {
+		return fmt.Errorf("保存订阅请求设置失败: %v", err)
+	}
+
+	return nil
+}
+
+// 从订阅添加配置文件
+func addSubscriptionConfig(fileName string, request models.CreateConfigRequest) error {
+	if request.URL == "" {
+		return newAPIError(http.StatusBadRequest, models.ErrCodeInvalidRequest, "URL不能为空")
+	}
+
+	// 处理配置更新
+	err := ProcessConfigUpdate(fileName, request.RawConfig, request.URL, request.DisplayName, request.Filter, request.Fetch)
+	if err != nil {
+		return err
+	}
+
+	// 保存provider模式设置
+	if request.Provider != nil && request.Provider.Enable {
+		if err := config.UpdateConfigField(fileName, "config_provider", *request.Provider); err != nil {
+			return fmt.Errorf("保存provider设置失败: %v", err)
+		}
+	}
+
+	events.Publish(events.ConfigAdded, map[string]any{"config": fileName, "name": request.DisplayName})
+	return nil
+}
+
+// 添加聚合配置
+func addAggregateConfig(fileName, configName string, sources []models.AggregateSource) error {
+	if len(sources) == 0 {
+		return newAPIError(http.StatusBadRequest, models.ErrCodeInvalidRequest, "聚合来源不能为空")
+	}
+
+	for _, source := range sources {
+		if source.File == "" && source.URL == "" {
+			return newAPIError(http.StatusBadRequest, models.ErrCodeInvalidRequest, "每个来源都需要指定配置文件或URL")
+		}
+		if err := conv.ValidateNodeFilter(source.Filter); err != nil {
+			return newAPIError(http.StatusBadRequest, models.ErrCodeInvalidRequest, "%v", err)
+		}
+	}
+
+	if err := converter.SaveAggregateConfig(fileName, configName, sources); err != nil {
+		return newAPIError(http.StatusInternalServerError, models.ErrCodeFetchFailed, "生成聚合配置失败: %v", err)
+	}
+
+	log.Printf("已生成聚合配置: %s\n", fileName)
+	events.Publish(events.ConfigAdded, map[string]any{"config": fileName, "name": configName})
+	return nil
+}
+
+// 从订阅源（或聚合来源）重新生成配置，并重新生成引用它的聚合配置，返回是否需要重启Clash
+// filter和fetch为空时沿用配置中保存的设置
+func refreshConfig(fileName, rawConfig string, filter *models.NodeFilter, fetch *models.FetchOptions) (bool, error) {
+	needRestart, err := refreshSingleConfig(fileName, rawConfig, filter, fetch)
+	if err != nil {
+		return false, err
+	}
+
+	for _, refreshed := range converter.RefreshDependentAggregates(fileName) {
+		if refreshed == config.OriginalConfigName && clash.IsRunning() {
+			needRestart = true
+		}
+	}
+	return needRestart, nil
+}
+
+// 只重新生成指定的配置，不更新引用它的聚合配置，返回是否需要重启Clash
+func refreshSingleConfig(fileName, rawConfig string, filter *models.NodeFilter, fetch *models.FetchOptions) (bool, error) {
+	if err := checkConfigExists(fileName); err != nil {
+		return false, toAPIError(err).withV1Status(http.StatusInternalServerError)
+	}
+
+	// 从文件中读取配置
+	yamlConfig, err := config.GetConfigInfo(fileName)
+	if err != nil {
+		return false, fmt.Errorf("读取配置文件失败: %v", err)
+	}
+
+	// 获取当前配置名称
+	configName, _ := yamlConfig["config_name"].(string)
+
+	// 聚合配置从各个来源重新生成
+	if converter.IsAggregateConfig(yamlConfig) {
+		err = converter.SaveAggregateConfig(fileName, configName, config.GetAggregateSources(yamlConfig))
+		if err != nil {
+			return false, newAPIError(http.StatusInternalServerError, models.ErrCodeFetchFailed, "更新聚合配置失败: %v", err)
+		}
+		events.Publish(events.ConfigUpdated, map[string]any{"config": fileName, "name": configName})
+		return fileName == config.OriginalConfigName && clash.IsRunning(), nil
+	}
+
+	// 检查是否有config_src字段
+	configSrc, ok := yamlConfig["config_src"].(string)
+	if !ok || configSrc == "" {
+		return false, newAPIError(http.StatusBadRequest, models.ErrCodeNoSource, "该配置文件没有订阅URL源")
+	}
+
+	// 获取节点过滤规则，请求中提供时覆盖原有规则
+	nodeFilter := config.GetNodeFilter(yamlConfig)
+	if filter != nil {
+		nodeFilter = *filter
+	}
+
+	// 获取订阅请求设置，请求中提供时覆盖原有设置
+	fetchOptions := config.GetFetchOptions(yamlConfig)
+	if fetch != nil {
+		fetchOptions = *fetch
+	}
+
+	// 处理配置更新
+	if err := ProcessConfigUpdate(fileName, rawConfig, configSrc, configName, nodeFilter, fetchOptions); err != nil {
+		return false, err
+	}
+	events.Publish(events.ConfigUpdated, map[string]any{"config": fileName, "name": configName})
+
+	// 如果这是当前使用的配置，并且Clash正在运行，提示需要重启
+	// provider模式下由内核定时拉取节点，无需重启
+	return fileName == config.OriginalConfigName && clash.IsRunning() && !config.GetProviderOptions(yamlConfig).Enable, nil
+}
+
+// 修改是否自动启动
+func setAutoStart(autoStart bool) error {
+	err := config.UpdateAppConfig(func(appConfig *models.AppConfig) {
+		appConfig.AutoStart = autoStart
+	})
+	if err != nil {
+		return fmt.Errorf("保存配置失败: %v", err)
+	}
+	return nil
+}
+
+// 设置配置文件的provider模式，返回是否需要重启Clash
+func setProviderMode(fileName string, provider models.ProviderOptions) (bool, error) {
+	if err := checkConfigExists(fileName); err != nil {
+		return false, err
+	}
+
+	var value any
+	if provider.Enable {
+		value = provider
+	}
+	if err := config.UpdateConfigField(fileName, "config_provider", value); err != nil {
+		return false, fmt.Errorf("保存provider设置失败: %v", err)
+	}
+
+	log.Printf("配置文件 %s 的provider模式已设置为: %v\n", fileName, provider.Enable)
+	events.Publish(events.ConfigUpdated, map[string]any{"config": fileName})
+
+	// 切换模式会改变生成的内核配置，当前配置需要重启生效
+	return fileName == config.OriginalConfigName && clash.IsRunning(), nil
+}
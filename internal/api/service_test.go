@@ -0,0 +1,94 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"clash-center/internal/audit"
+	"clash-center/internal/clash"
+	"clash-center/internal/config"
+)
+
+// 使用临时的配置目录和审计日志，配置目录中包含一个普通配置文件 x.yaml
+func useTempConfigDir(t *testing.T) {
+	t.Helper()
+	useTempAppConfig(t)
+	dir := t.TempDir()
+	savedDir, savedName, savedLog := config.ConfigDir, config.OriginalConfigName, audit.LogPath
+	t.Cleanup(func() { config.ConfigDir, config.OriginalConfigName, audit.LogPath = savedDir, savedName, savedLog })
+	config.ConfigDir = dir
+	audit.LogPath = filepath.Join(t.TempDir(), "audit.jsonl")
+	config.OriginalConfigName = ""
+	if err := os.WriteFile(filepath.Join(dir, "x.yaml"), []byte("mixed-port: 7890\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 通过完整的路由发送请求
+func serveAPI(t *testing.T, method, target, body string) (int, map[string]any) {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	SetupRoutes(false).ServeHTTP(rec, req)
+
+	var result map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("解析响应失败: %v: %s", err, rec.Body.String())
+	}
+	return rec.Code, result
+}
+
+func TestV1StatusCodesUnchanged(t *testing.T) {
+	useTempConfigDir(t)
+
+	tests := []struct {
+		name           string
+		method, target string
+		body           string
+		status         int
+	}{
+		{"v1保存无效YAML", http.MethodPost, "/api/save-config", `{"path":"x.yaml","content":"a: [1"}`, http.StatusInternalServerError},
+		{"v2保存无效YAML", http.MethodPut, "/api/v2/configs/x.yaml", `{"content":"a: [1"}`, http.StatusBadRequest},
+		{"v1更新不存在的配置", http.MethodPost, "/api/update-from-url", `{"configPath":"missing.yaml"}`, http.StatusInternalServerError},
+		{"v2更新不存在的配置", http.MethodPost, "/api/v2/configs/missing.yaml:refresh", `{}`, http.StatusNotFound},
+		{"v1无效的过滤规则", http.MethodPost, "/api/add-from-url", `{"url":"http://127.0.0.1:1/sub","filter":{"include":"("}}`, http.StatusInternalServerError},
+		{"v1缺少URL", http.MethodPost, "/api/add-from-url", `{}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		status, result := serveAPI(t, tt.method, tt.target, tt.body)
+		if status != tt.status {
+			t.Errorf("%s: 状态码为 %d，应为 %d: %v", tt.name, status, tt.status, result)
+		}
+	}
+}
+
+func TestActivateKeepsConfigWhenStartFails(t *testing.T) {
+	useTempConfigDir(t)
+	savedCore := clash.ClashPath
+	t.Cleanup(func() { clash.ClashPath = savedCore })
+	clash.ClashPath = filepath.Join(t.TempDir(), "missing-core")
+
+	status, result := serveAPI(t, http.MethodPost, "/api/v2/configs/x.yaml:activate", "")
+	if status != http.StatusInternalServerError || result["code"] != "core_start_failed" {
+		t.Fatalf("没有内核时切换配置返回 %d: %v", status, result)
+	}
+
+	_, result = serveAPI(t, http.MethodGet, "/api/v2/core", "")
+	if data := result["data"].(map[string]any); data["config"] != nil && data["config"] != "" {
+		t.Errorf("启动失败后当前配置为 %v", data["config"])
+	}
+	if last := config.LoadAppConfig().LastConfig; last != "" {
+		t.Errorf("启动失败后保存的上次配置为 %s", last)
+	}
+
+	status, _ = serveAPI(t, http.MethodPost, "/api/switch", `{"configPath":"x.yaml"}`)
+	if status != http.StatusInternalServerError || config.OriginalConfigName != "" {
+		t.Errorf("v1切换返回 %d，当前配置为 %q", status, config.OriginalConfigName)
+	}
+}
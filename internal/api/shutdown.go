@@ -0,0 +1,30 @@
+package api
+
+import (
+	"context"
+	"sync"
+)
+
+var (
+	// 服务器开始关闭时关闭，通知事件流等长连接结束
+	shuttingDown = make(chan struct{})
+	shutdownOnce sync.Once
+)
+
+// BeginShutdown 结束事件流和内核日志等持续输出的请求，以便服务器关闭时不必等待超时
+func BeginShutdown() {
+	shutdownOnce.Do(func() { close(shuttingDown) })
+}
+
+// 返回在服务器关闭时取消的上下文
+func withShutdown(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	go func() {
+		select {
+		case <-shuttingDown:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
@@ -0,0 +1,204 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"clash-center/internal/audit"
+	"clash-center/internal/config"
+	"clash-center/internal/models"
+	"clash-center/internal/utils"
+	"clash-center/pkg/converter"
+
+	"github.com/go-chi/chi/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// 处理本地订阅请求，供局域网内的其他设备订阅
+func HandleSubscription(w http.ResponseWriter, r *http.Request) {
+	if !checkSubToken(chi.URLParam(r, "token")) {
+		http.Error(w, "invalid token", http.StatusForbidden)
+		return
+	}
+
+	fileName, ok := config.ResolveConfigFile(chi.URLParam(r, "config"))
+	if !ok {
+		http.Error(w, "config not found", http.StatusNotFound)
+		return
+	}
+
+	// 默认不应用default.yaml，其中的监听地址和控制器设置通常只适用于本机
+	query := r.URL.Query()
+	applyDefault := query.Get("default") == "1" || query.Get("default") == "true"
+
+	mergedConfig, err := config.BuildMergedConfig(fileName, applyDefault)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("生成配置失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// 透传上游订阅的流量信息
+	userInfo, _ := mergedConfig["config_userinfo"].(string)
+	displayName, _ := mergedConfig["config_name"].(string)
+	if displayName == "" {
+		displayName = strings.TrimSuffix(fileName, filepath.Ext(fileName))
+	}
+	config.StripMetadata(mergedConfig)
+	// 控制器地址和密钥只属于本机，无论是否应用default.yaml都不下发
+	config.StripControllerSettings(mergedConfig)
+
+	output, err := converter.EncodeConfig(mergedConfig, query.Get("format"))
+	if errors.Is(err, converter.ErrUnsupportedFormat) {
+		http.Error(w, "unsupported format: "+query.Get("format"), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("转换配置失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if len(output.Skipped) > 0 {
+		log.Printf("本地订阅 %s 中有 %d 个节点无法转换: %v", fileName, len(output.Skipped), output.Skipped)
+	}
+
+	w.Header().Set("Content-Type", output.ContentType)
+	w.Header().Set("Content-Disposition", "attachment; filename*=UTF-8''"+url.PathEscape(displayName+output.Extension))
+	w.Header().Set("profile-update-interval", "24")
+	if userInfo != "" {
+		w.Header().Set("subscription-userinfo", userInfo)
+	}
+	w.Write(output.Content)
+}
+
+// 处理proxy-providers请求，返回配置中的节点列表供内核定时拉取
+func HandleProviderFile(w http.ResponseWriter, r *http.Request) {
+	if !checkSubToken(chi.URLParam(r, "token")) {
+		http.Error(w, "invalid token", http.StatusForbidden)
+		return
+	}
+
+	fileName, ok := config.ResolveConfigFile(chi.URLParam(r, "config"))
+	if !ok {
+		http.Error(w, "config not found", http.StatusNotFound)
+		return
+	}
+
+	configData, err := config.GetConfigInfo(fileName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	content, err := yaml.Marshal(map[string]any{
+		"proxies": converter.GetProxyList(configData),
+	})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("编码节点列表失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/yaml; charset=utf-8")
+	if userInfo, ok := configData["config_userinfo"].(string); ok && userInfo != "" {
+		w.Header().Set("subscription-userinfo", userInfo)
+	}
+	w.Write(content)
+}
+
+// 处理rule-providers请求，返回共享规则集文件
+func HandleRuleSetFile(w http.ResponseWriter, r *http.Request) {
+	if !checkSubToken(chi.URLParam(r, "token")) {
+		http.Error(w, "invalid token", http.StatusForbidden)
+		return
+	}
+
+	fileName, ok := config.FindRuleSetFile(chi.URLParam(r, "name"))
+	if !ok {
+		http.Error(w, "rule set not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	http.ServeFile(w, r, filepath.Join(config.RuleSetDir, fileName))
+}
+
+// 处理设置provider模式请求
+func HandleSetProviderMode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.SendErrorResponse(w, http.StatusMethodNotAllowed, "仅支持POST请求")
+		return
+	}
+
+	// 解析请求体
+	var requestBody struct {
+		ConfigPath string                 `json:"configPath"`
+		Provider   models.ProviderOptions `json:"provider"`
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&requestBody)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("解析请求失败: %v", err))
+		return
+	}
+
+	// 只获取文件名部分，避免任何路径遍历攻击
+	fileName := filepath.Base(requestBody.ConfigPath)
+	audit.SetTarget(r, fileName)
+
+	needRestart, err := setProviderMode(fileName, requestBody.Provider)
+	if err != nil {
+		sendServiceError(w, err)
+		return
+	}
+
+	utils.SendSuccessResponse(w, "provider设置已更新", map[string]any{
+		"needRestart": needRestart,
+	})
+}
+
+// 获取本地订阅令牌
+func HandleGetSubToken(w http.ResponseWriter, r *http.Request) {
+	token, err := config.GetSubToken()
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.SendSuccessResponse(w, "", map[string]any{
+		"token": token,
+	})
+}
+
+// 重新生成本地订阅令牌，旧的订阅链接将失效
+func HandleResetSubToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.SendErrorResponse(w, http.StatusMethodNotAllowed, "仅支持POST请求")
+		return
+	}
+
+	token, err := config.ResetSubToken()
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("本地订阅令牌已重新生成")
+
+	utils.SendSuccessResponse(w, "订阅令牌已重新生成", map[string]any{
+		"token": token,
+	})
+}
+
+// 校验本地订阅令牌
+func checkSubToken(token string) bool {
+	expected := config.LoadAppConfig().SubToken
+	if expected == "" || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
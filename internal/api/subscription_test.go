@@ -0,0 +1,94 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"clash-center/internal/config"
+
+	"github.com/go-chi/chi/v5"
+	"gopkg.in/yaml.v3"
+)
+
+func TestSubscriptionStripsControllerSettings(t *testing.T) {
+	useTempAppConfig(t)
+	dir := t.TempDir()
+	savedDir, savedDefault := config.ConfigDir, config.DefaultConfigPath
+	t.Cleanup(func() { config.ConfigDir, config.DefaultConfigPath = savedDir, savedDefault })
+	config.ConfigDir = dir
+	config.DefaultConfigPath = filepath.Join(dir, "default.yaml")
+
+	writeFile := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("default.yaml", "external-controller: 0.0.0.0:9090\nsecret: s3cret\nexternal-ui: ui\nmixed-port: 7890\n")
+	writeFile("sub.yaml", "proxies:\n  - {name: a, type: socks5, server: 1.1.1.1, port: 1080}\nexternal-controller-tls: 0.0.0.0:9443\n")
+
+	token, err := config.GetSubToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/sub/"+token+"/sub?default=1", nil)
+	routeCtx := chi.NewRouteContext()
+	routeCtx.URLParams.Add("token", token)
+	routeCtx.URLParams.Add("config", "sub")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, routeCtx))
+	rec := httptest.NewRecorder()
+	HandleSubscription(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("状态码为 %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var served map[string]any
+	if err := yaml.Unmarshal(rec.Body.Bytes(), &served); err != nil {
+		t.Fatalf("解析订阅内容失败: %v", err)
+	}
+	for _, key := range []string{"external-controller", "external-controller-tls", "external-ui", "secret"} {
+		if _, ok := served[key]; ok {
+			t.Errorf("订阅中包含 %s", key)
+		}
+	}
+	if served["mixed-port"] != 7890 {
+		t.Errorf("未应用default.yaml中的其他设置: %v", served)
+	}
+	if strings.Contains(rec.Body.String(), "s3cret") {
+		t.Error("订阅中包含控制器密钥")
+	}
+}
+
+func TestGetSubTokenConcurrent(t *testing.T) {
+	useTempAppConfig(t)
+
+	tokens := make([]string, 8)
+	var wg sync.WaitGroup
+	for i := range tokens {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := config.GetSubToken()
+			if err != nil {
+				t.Error(err)
+			}
+			tokens[i] = token
+		}()
+	}
+	wg.Wait()
+
+	for _, token := range tokens {
+		if token == "" || token != tokens[0] {
+			t.Fatalf("并发获取的令牌不一致: %v", tokens)
+		}
+	}
+	if stored := config.LoadAppConfig().SubToken; stored != tokens[0] {
+		t.Errorf("保存的令牌为 %s，返回的为 %s", stored, tokens[0])
+	}
+}
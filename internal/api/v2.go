@@ -0,0 +1,501 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"clash-center/internal/audit"
+	"clash-center/internal/clash"
+	"clash-center/internal/config"
+	"clash-center/internal/events"
+	"clash-center/internal/models"
+	"clash-center/internal/utils"
+	"clash-center/pkg/converter"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// v2接口以资源为中心，响应统一为 {success, data} 或 {success, error, code}
+
+// 设置v2路由
+func setupV2Routes(r chi.Router) {
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		utils.SendCodedErrorResponse(w, http.StatusNotFound, models.ErrCodeNotFound, "接口不存在")
+	})
+	r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		utils.SendCodedErrorResponse(w, http.StatusMethodNotAllowed, models.ErrCodeMethodNotAllowed, "接口不支持该请求方法")
+	})
+
+	// 配置文件
+	r.Get("/configs", HandleV2ListConfigs)
+	r.Post("/configs", HandleV2CreateConfig)
+	r.Get("/configs/{name}", HandleV2GetConfig)
+	r.Put("/configs/{name}", HandleV2ReplaceConfig)
+	r.Patch("/configs/{name}", HandleV2PatchConfig)
+	r.Delete("/configs/{name}", HandleV2DeleteConfig)
+	r.Post("/configs/{name}:refresh", HandleV2RefreshConfig)
+	r.Post("/configs/{name}:activate", HandleV2ActivateConfig)
+
+	// 内核
+	r.Get("/core", HandleV2GetCore)
+	r.Post("/core:start", HandleV2StartCore)
+	r.Post("/core:stop", HandleV2StopCore)
+	r.Post("/core:restart", HandleV2RestartCore)
+	r.Get("/core/logs", HandleV2GetCoreLogs)
+	r.Get("/core/binary", HandleV2GetCoreBinary)
+	// 上传的内核会被执行，未启用认证时只允许本机替换
+	r.With(localOrAuthenticated).Post("/core/binary", HandleV2UploadCoreBinary)
+	r.With(localOrAuthenticated).Post("/core/binary:rollback", HandleV2RollbackCoreBinary)
+
+	// 应用设置
+	r.Get("/settings", HandleV2GetSettings)
+	r.Patch("/settings", HandleV2PatchSettings)
+}
+
+// 按v2格式返回错误
+func sendV2Error(w http.ResponseWriter, err error) {
+	e := toAPIError(err)
+	utils.SendCodedErrorResponse(w, e.Status, e.Code, e.Message)
+}
+
+// 解析JSON请求体
+func decodeV2Request(r *http.Request, out any) error {
+	if err := json.NewDecoder(r.Body).Decode(out); err != nil {
+		return newAPIError(http.StatusBadRequest, models.ErrCodeInvalidRequest, "解析请求失败: %v", err)
+	}
+	return nil
+}
+
+// 根据路径参数查找配置文件，允许省略扩展名
+func v2ConfigFile(r *http.Request) (string, error) {
+	fileName, ok := config.ResolveConfigFile(chi.URLParam(r, "name"))
+	if !ok {
+		return "", newAPIError(http.StatusNotFound, models.ErrCodeConfigNotFound, "配置文件不存在")
+	}
+	audit.SetTarget(r, fileName)
+	return fileName, nil
+}
+
+// 转换配置文件信息
+func toConfigSummary(file models.ConfigFile) models.ConfigSummary {
+	return models.ConfigSummary{
+		Name:        file.Path,
+		DisplayName: file.DisplayName,
+		Source:      file.ConfigSrc,
+		Type:        file.ConfigType,
+		Current:     file.Path == config.OriginalConfigName,
+	}
+}
+
+// 获取单个配置文件的概要
+func configSummary(fileName string) (models.ConfigSummary, error) {
+	configs, err := config.GetConfigFiles()
+	if err != nil {
+		return models.ConfigSummary{}, fmt.Errorf("获取配置文件失败: %v", err)
+	}
+	for _, file := range configs {
+		if file.Path == fileName {
+			return toConfigSummary(file), nil
+		}
+	}
+	return models.ConfigSummary{}, newAPIError(http.StatusNotFound, models.ErrCodeConfigNotFound, "配置文件不存在")
+}
+
+// 返回修改配置的结果
+func sendConfigChangeResult(w http.ResponseWriter, statusCode int, fileName string, restarted, needRestart bool) {
+	summary, err := configSummary(fileName)
+	if err != nil {
+		sendV2Error(w, err)
+		return
+	}
+	utils.SendDataResponse(w, statusCode, models.ConfigChangeResult{
+		Config:      summary,
+		Restarted:   restarted,
+		NeedRestart: needRestart,
+	})
+}
+
+// 获取内核运行状态
+func coreStatus() models.CoreStatus {
+	status := models.CoreStatus{
+		Running:  clash.IsRunning(),
+		Config:   config.OriginalConfigName,
+		PID:      clash.PID(),
+		Uptime:   int64(clash.Uptime().Seconds()),
+		Adopted:  clash.Adopted(),
+		Conflict: clash.CachedConflict(),
+	}
+	if info, err := clash.InstalledBinary(); err == nil {
+		status.Version = info.Version
+	}
+	return status
+}
+
+// 处理获取配置文件列表请求
+func HandleV2ListConfigs(w http.ResponseWriter, r *http.Request) {
+	configs, err := config.GetConfigFiles()
+	if err != nil {
+		sendV2Error(w, fmt.Errorf("获取配置文件失败: %v", err))
+		return
+	}
+
+	list := models.ConfigList{
+		Items:   make([]models.ConfigSummary, 0, len(configs)),
+		Current: config.OriginalConfigName,
+	}
+	for _, file := range configs {
+		list.Items = append(list.Items, toConfigSummary(file))
+	}
+
+	utils.SendDataResponse(w, http.StatusOK, list)
+}
+
+// 处理添加配置文件请求，multipart请求上传文件，JSON请求从订阅或聚合来源生成
+func HandleV2CreateConfig(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		handleV2UploadConfig(w, r)
+		return
+	}
+
+	var request models.CreateConfigRequest
+	if err := decodeV2Request(r, &request); err != nil {
+		sendV2Error(w, err)
+		return
+	}
+
+	prefix := "config"
+	if len(request.Sources) > 0 {
+		prefix = "aggregate"
+	}
+	fileName := normalizeConfigFileName(request.Name, prefix)
+	audit.SetTarget(r, fileName)
+
+	if _, err := os.Stat(filepath.Join(config.ConfigDir, fileName)); err == nil {
+		sendV2Error(w, newAPIError(http.StatusConflict, models.ErrCodeConfigExists, "配置文件已存在"))
+		return
+	}
+
+	var err error
+	if len(request.Sources) > 0 {
+		err = addAggregateConfig(fileName, request.DisplayName, request.Sources)
+	} else {
+		err = addSubscriptionConfig(fileName, request)
+	}
+	if err != nil {
+		sendV2Error(w, err)
+		return
+	}
+
+	sendConfigChangeResult(w, http.StatusCreated, fileName, false, false)
+}
+
+// 处理上传配置文件请求，文件名可以通过name字段指定
+func handleV2UploadConfig(w http.ResponseWriter, r *http.Request) {
+	// 限制上传大小为10MB
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		sendV2Error(w, newAPIError(http.StatusBadRequest, models.ErrCodeInvalidRequest, "解析表单失败: %v", err))
+		return
+	}
+
+	file, handler, err := r.FormFile("file")
+	if err != nil {
+		sendV2Error(w, newAPIError(http.StatusBadRequest, models.ErrCodeInvalidRequest, "获取文件失败: %v", err))
+		return
+	}
+	defer file.Close()
+
+	// 只获取文件名部分，避免任何路径遍历攻击
+	fileName := filepath.Base(handler.Filename)
+	if name := r.FormValue("name"); name != "" {
+		fileName = normalizeConfigFileName(name, "config")
+	}
+	audit.SetTarget(r, fileName)
+
+	if _, err := os.Stat(filepath.Join(config.ConfigDir, fileName)); err == nil {
+		sendV2Error(w, newAPIError(http.StatusConflict, models.ErrCodeConfigExists, "配置文件已存在"))
+		return
+	}
+
+	if err := saveUploadedConfig(fileName, file); err != nil {
+		sendV2Error(w, err)
+		return
+	}
+
+	sendConfigChangeResult(w, http.StatusCreated, fileName, false, false)
+}
+
+// 处理获取配置文件详情请求
+func HandleV2GetConfig(w http.ResponseWriter, r *http.Request) {
+	fileName, err := v2ConfigFile(r)
+	if err != nil {
+		sendV2Error(w, err)
+		return
+	}
+
+	summary, err := configSummary(fileName)
+	if err != nil {
+		sendV2Error(w, err)
+		return
+	}
+	content, err := readConfigContent(fileName)
+	if err != nil {
+		sendV2Error(w, err)
+		return
+	}
+	configData, err := config.GetConfigInfo(fileName)
+	if err != nil {
+		sendV2Error(w, err)
+		return
+	}
+
+	detail := models.ConfigDetail{
+		ConfigSummary: summary,
+		Content:       content,
+		Sources:       config.GetAggregateSources(configData),
+	}
+	if filter := config.GetNodeFilter(configData); !filter.IsEmpty() {
+		detail.Filter = &filter
+	}
+	if fetch := config.GetFetchOptions(configData); !fetch.IsEmpty() {
+		detail.Fetch = &fetch
+	}
+	if provider := config.GetProviderOptions(configData); provider.Enable {
+		detail.Provider = &provider
+	}
+	detail.UserInfo, _ = configData["config_userinfo"].(string)
+
+	utils.SendDataResponse(w, http.StatusOK, detail)
+}
+
+// 处理替换配置内容请求，当前配置正在运行时会重启内核
+func HandleV2ReplaceConfig(w http.ResponseWriter, r *http.Request) {
+	fileName, err := v2ConfigFile(r)
+	if err != nil {
+		sendV2Error(w, err)
+		return
+	}
+
+	var request models.ReplaceConfigRequest
+	if err := decodeV2Request(r, &request); err != nil {
+		sendV2Error(w, err)
+		return
+	}
+
+	restarted, err := saveConfigContent(fileName, request.Content)
+	if err != nil {
+		sendV2Error(w, err)
+		return
+	}
+
+	sendConfigChangeResult(w, http.StatusOK, fileName, restarted, false)
+}
+
+// 处理修改配置元数据请求
+func HandleV2PatchConfig(w http.ResponseWriter, r *http.Request) {
+	fileName, err := v2ConfigFile(r)
+	if err != nil {
+		sendV2Error(w, err)
+		return
+	}
+
+	var request models.PatchConfigRequest
+	if err := decodeV2Request(r, &request); err != nil {
+		sendV2Error(w, err)
+		return
+	}
+
+	if request.Filter != nil {
+		if err := converter.ValidateNodeFilter(*request.Filter); err != nil {
+			sendV2Error(w, newAPIError(http.StatusBadRequest, models.ErrCodeInvalidRequest, "%v", err))
+			return
+		}
+	}
+
+	if request.DisplayName != nil {
+		if err := renameConfig(fileName, *request.DisplayName); err != nil {
+			sendV2Error(w, err)
+			return
+		}
+	}
+
+	// 过滤规则和请求设置在下次更新订阅时生效
+	fields := map[string]any{}
+	if request.Filter != nil {
+		fields["config_filter"] = nil
+		if !request.Filter.IsEmpty() {
+			fields["config_filter"] = *request.Filter
+		}
+	}
+	if request.Fetch != nil {
+		fields["config_fetch"] = nil
+		if !request.Fetch.IsEmpty() {
+			fields["config_fetch"] = *request.Fetch
+		}
+	}
+	for key, value := range fields {
+		if err := config.UpdateConfigField(fileName, key, value); err != nil {
+			sendV2Error(w, fmt.Errorf("保存配置失败: %v", err))
+			return
+		}
+	}
+	if len(fields) > 0 {
+		events.Publish(events.ConfigUpdated, map[string]any{"config": fileName})
+	}
+
+	needRestart := false
+	if request.Provider != nil {
+		needRestart, err = setProviderMode(fileName, *request.Provider)
+		if err != nil {
+			sendV2Error(w, err)
+			return
+		}
+	}
+
+	sendConfigChangeResult(w, http.StatusOK, fileName, false, needRestart)
+}
+
+// 处理删除配置文件请求
+func HandleV2DeleteConfig(w http.ResponseWriter, r *http.Request) {
+	fileName, err := v2ConfigFile(r)
+	if err != nil {
+		sendV2Error(w, err)
+		return
+	}
+
+	if err := deleteConfig(fileName); err != nil {
+		// 删除正在使用的配置属于资源状态冲突
+		e := toAPIError(err)
+		if e.Code == models.ErrCodeConfigInUse {
+			e.Status = http.StatusConflict
+		}
+		sendV2Error(w, e)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// 处理从订阅源更新配置请求
+func HandleV2RefreshConfig(w http.ResponseWriter, r *http.Request) {
+	fileName, err := v2ConfigFile(r)
+	if err != nil {
+		sendV2Error(w, err)
+		return
+	}
+
+	// 请求体可以为空，此时沿用保存的设置
+	var request models.RefreshConfigRequest
+	if r.ContentLength != 0 {
+		if err := decodeV2Request(r, &request); err != nil {
+			sendV2Error(w, err)
+			return
+		}
+	}
+
+	needRestart, err := refreshConfig(fileName, request.RawConfig, request.Filter, request.Fetch)
+	if err != nil {
+		sendV2Error(w, err)
+		return
+	}
+
+	sendConfigChangeResult(w, http.StatusOK, fileName, false, needRestart)
+}
+
+// 处理切换到该配置请求，会重启内核
+func HandleV2ActivateConfig(w http.ResponseWriter, r *http.Request) {
+	fileName, err := v2ConfigFile(r)
+	if err != nil {
+		sendV2Error(w, err)
+		return
+	}
+
+	if err := switchConfig(fileName); err != nil {
+		sendV2Error(w, err)
+		return
+	}
+
+	sendConfigChangeResult(w, http.StatusOK, fileName, true, false)
+}
+
+// 处理获取内核状态请求
+func HandleV2GetCore(w http.ResponseWriter, r *http.Request) {
+	utils.SendDataResponse(w, http.StatusOK, coreStatus())
+}
+
+// 处理启动内核请求，已经在运行时直接返回状态
+func HandleV2StartCore(w http.ResponseWriter, r *http.Request) {
+	audit.SetTarget(r, config.OriginalConfigName)
+
+	if _, err := startCore(); err != nil {
+		sendV2Error(w, err)
+		return
+	}
+
+	utils.SendDataResponse(w, http.StatusOK, coreStatus())
+}
+
+// 处理停止内核请求，没有运行时直接返回状态
+func HandleV2StopCore(w http.ResponseWriter, r *http.Request) {
+	audit.SetTarget(r, config.OriginalConfigName)
+
+	if _, err := stopCore(); err != nil {
+		sendV2Error(w, err)
+		return
+	}
+
+	utils.SendDataResponse(w, http.StatusOK, coreStatus())
+}
+
+// 处理重启内核请求
+func HandleV2RestartCore(w http.ResponseWriter, r *http.Request) {
+	audit.SetTarget(r, config.OriginalConfigName)
+
+	if err := restartCore(); err != nil {
+		sendV2Error(w, err)
+		return
+	}
+
+	utils.SendDataResponse(w, http.StatusOK, coreStatus())
+}
+
+// 处理获取内核输出请求，lines参数指定返回的行数
+func HandleV2GetCoreLogs(w http.ResponseWriter, r *http.Request) {
+	lines, _ := strconv.Atoi(r.URL.Query().Get("lines"))
+
+	utils.SendDataResponse(w, http.StatusOK, models.CoreLogs{
+		Lines: clash.RecentLogs(lines),
+	})
+}
+
+// 处理获取应用设置请求
+func HandleV2GetSettings(w http.ResponseWriter, r *http.Request) {
+	appConfig := config.LoadAppConfig()
+
+	utils.SendDataResponse(w, http.StatusOK, models.Settings{
+		AutoStart: appConfig.AutoStart,
+	})
+}
+
+// 处理修改应用设置请求
+func HandleV2PatchSettings(w http.ResponseWriter, r *http.Request) {
+	audit.Record(r)
+	var request models.PatchSettingsRequest
+	if err := decodeV2Request(r, &request); err != nil {
+		sendV2Error(w, err)
+		return
+	}
+
+	if request.AutoStart != nil {
+		if err := setAutoStart(*request.AutoStart); err != nil {
+			sendV2Error(w, err)
+			return
+		}
+	}
+
+	HandleV2GetSettings(w, r)
+}
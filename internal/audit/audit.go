@@ -0,0 +1,191 @@
+// Package audit 以JSON Lines格式记录管理操作
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 审计日志文件路径
+var LogPath = "./audit.jsonl"
+
+// 日志文件超过该大小时轮转为 LogPath.1，只保留一个历史文件
+var MaxSize int64 = 10 << 20
+
+var mu sync.Mutex
+
+// 已统计的文件行数，日志只会追加，再次统计时只需读取新增的部分
+type lineCount struct {
+	size  int64
+	lines int
+}
+
+var lineCounts = map[string]lineCount{}
+
+// Entry 一条审计记录
+type Entry struct {
+	Time     time.Time `json:"time"`
+	IP       string    `json:"ip"`
+	User     string    `json:"user,omitempty"`
+	Method   string    `json:"method"`
+	Path     string    `json:"path"`
+	Action   string    `json:"action"`
+	Target   string    `json:"target,omitempty"`
+	Status   int       `json:"status"`
+	Success  bool      `json:"success"`
+	Error    string    `json:"error,omitempty"`
+	Duration int64     `json:"duration_ms"`
+}
+
+// 轮转后的历史日志文件
+func backupPath() string {
+	return LogPath + ".1"
+}
+
+// Append 追加一条记录，日志文件过大时先轮转
+func Append(entry Entry) error {
+	content, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("编码审计记录失败: %v", err)
+	}
+	content = append(content, '\n')
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if dir := filepath.Dir(LogPath); dir != "" {
+		os.MkdirAll(dir, 0755)
+	}
+	if info, err := os.Stat(LogPath); err == nil && info.Size() > 0 && info.Size()+int64(len(content)) > MaxSize {
+		if err := os.Rename(LogPath, backupPath()); err != nil {
+			return fmt.Errorf("轮转审计日志失败: %v", err)
+		}
+		clear(lineCounts)
+	}
+
+	file, err := os.OpenFile(LogPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("打开审计日志失败: %v", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(content); err != nil {
+		return fmt.Errorf("写入审计日志失败: %v", err)
+	}
+	return nil
+}
+
+// Read 分页读取记录，最新的记录在前，page从1开始。
+// 从文件末尾向前读取，只解析当前页需要的记录
+func Read(page, size int) ([]Entry, int, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	entries := []Entry{}
+	total := 0
+	skip := (page - 1) * size
+	for _, path := range []string{LogPath, backupPath()} {
+		file, err := os.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("打开审计日志失败: %v", err)
+		}
+
+		lines, err := countLines(path, file)
+		if err == nil && len(entries) < size {
+			err = scanLinesBackward(file, func(line []byte) bool {
+				var entry Entry
+				// 跳过无法解析的行，例如写入中断留下的不完整记录
+				if json.Unmarshal(line, &entry) != nil {
+					return true
+				}
+				if skip > 0 {
+					skip--
+					return true
+				}
+				entries = append(entries, entry)
+				return len(entries) < size
+			})
+		}
+		file.Close()
+		if err != nil {
+			return nil, 0, fmt.Errorf("读取审计日志失败: %v", err)
+		}
+		total += lines
+	}
+	return entries, total, nil
+}
+
+// 统计文件中的记录数，文件变大时只统计新增的部分
+func countLines(path string, file *os.File) (int, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	cached := lineCounts[path]
+	if info.Size() < cached.size {
+		cached = lineCount{}
+	}
+
+	buf := make([]byte, 32*1024)
+	offset := cached.size
+	for offset < info.Size() {
+		n, err := file.ReadAt(buf[:min(int64(len(buf)), info.Size()-offset)], offset)
+		cached.lines += bytes.Count(buf[:n], []byte{'\n'})
+		offset += int64(n)
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		if n == 0 {
+			break
+		}
+	}
+	cached.size = offset
+	lineCounts[path] = cached
+	return cached.lines, nil
+}
+
+// 从文件末尾开始逐行回调，fn返回false时停止
+func scanLinesBackward(file *os.File, fn func(line []byte) bool) error {
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, 32*1024)
+	var rest []byte
+	offset := info.Size()
+	for offset > 0 {
+		n := min(int64(len(buf)), offset)
+		offset -= n
+		if _, err := file.ReadAt(buf[:n], offset); err != nil && err != io.EOF {
+			return err
+		}
+
+		chunk := append(bytes.Clone(buf[:n]), rest...)
+		for {
+			i := bytes.LastIndexByte(chunk, '\n')
+			if i < 0 {
+				break
+			}
+			if line := bytes.TrimSpace(chunk[i+1:]); len(line) > 0 && !fn(line) {
+				return nil
+			}
+			chunk = chunk[:i]
+		}
+		rest = chunk
+	}
+	if line := bytes.TrimSpace(rest); len(line) > 0 {
+		fn(line)
+	}
+	return nil
+}
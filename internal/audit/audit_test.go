@@ -0,0 +1,117 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func useTempLog(t *testing.T) {
+	t.Helper()
+	savedPath, savedSize := LogPath, MaxSize
+	t.Cleanup(func() {
+		LogPath, MaxSize = savedPath, savedSize
+		clear(lineCounts)
+	})
+	LogPath = filepath.Join(t.TempDir(), "audit.jsonl")
+	clear(lineCounts)
+}
+
+func appendEntries(t *testing.T, from, to int) {
+	t.Helper()
+	for i := from; i < to; i++ {
+		if err := Append(Entry{Action: fmt.Sprintf("action-%d", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func actions(entries []Entry) []string {
+	result := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		result = append(result, entry.Action)
+	}
+	return result
+}
+
+func TestReadPagesFromEnd(t *testing.T) {
+	useTempLog(t)
+	appendEntries(t, 0, 5)
+
+	entries, total, err := Read(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 5 || fmt.Sprint(actions(entries)) != "[action-4 action-3]" {
+		t.Fatalf("第1页为 %v，总数 %d", actions(entries), total)
+	}
+
+	entries, _, _ = Read(3, 2)
+	if fmt.Sprint(actions(entries)) != "[action-0]" {
+		t.Fatalf("第3页为 %v", actions(entries))
+	}
+	entries, _, _ = Read(4, 2)
+	if len(entries) != 0 {
+		t.Fatalf("超出范围的页返回了 %v", actions(entries))
+	}
+
+	// 新增记录后总数只统计新增部分也应正确
+	appendEntries(t, 5, 6)
+	entries, total, _ = Read(1, 1)
+	if total != 6 || entries[0].Action != "action-5" {
+		t.Fatalf("追加后第1页为 %v，总数 %d", actions(entries), total)
+	}
+}
+
+func TestReadSkipsBrokenLines(t *testing.T) {
+	useTempLog(t)
+	appendEntries(t, 0, 1)
+	file, err := os.OpenFile(LogPath, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"action":"broken`)
+	file.Close()
+
+	entries, _, err := Read(1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(actions(entries)) != "[action-0]" {
+		t.Fatalf("读取结果为 %v", actions(entries))
+	}
+}
+
+func TestAppendRotates(t *testing.T) {
+	useTempLog(t)
+	// 每个文件最多容纳两条记录
+	line, _ := json.Marshal(Entry{Action: "action-0"})
+	MaxSize = int64(len(line)+1)*2 + 1
+	appendEntries(t, 0, 10)
+
+	info, err := os.Stat(LogPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() > MaxSize {
+		t.Fatalf("日志文件大小为 %d，超过了上限", info.Size())
+	}
+	if _, err := os.Stat(LogPath + ".1"); err != nil {
+		t.Fatalf("没有生成轮转文件: %v", err)
+	}
+
+	// 读取时会接着读取轮转后的文件，更早的记录已被丢弃
+	entries, total, err := Read(1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 4 || fmt.Sprint(actions(entries)) != "[action-9 action-8 action-7 action-6]" {
+		t.Fatalf("读取结果为 %v，总数 %d", actions(entries), total)
+	}
+	entries, _, _ = Read(2, 3)
+	if fmt.Sprint(actions(entries)) != "[action-6]" {
+		t.Fatalf("跨文件翻页结果为 %v", actions(entries))
+	}
+}
@@ -0,0 +1,135 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+type contextKey struct{}
+
+// 请求处理过程中记录的审计信息
+type requestInfo struct {
+	user     string
+	target   string
+	recorded bool // 处理函数标记了需要审计的操作
+}
+
+// SetUser 记录通过认证的用户，审计中间件位于认证之前，无法从请求上下文中读取
+func SetUser(r *http.Request, user string) {
+	if info, ok := r.Context().Value(contextKey{}).(*requestInfo); ok {
+		info.user = user
+	}
+}
+
+// SetTarget 设置本次操作的目标（通常是配置文件名）并记录审计日志，未经过审计中间件的请求会被忽略
+func SetTarget(r *http.Request, target string) {
+	if info, ok := r.Context().Value(contextKey{}).(*requestInfo); ok {
+		info.target = target
+		info.recorded = true
+	}
+}
+
+// Record 记录没有目标文件的操作，如修改自动启动设置
+func Record(r *http.Request) {
+	if info, ok := r.Context().Value(contextKey{}).(*requestInfo); ok {
+		info.recorded = true
+	}
+}
+
+// 错误响应最多保留的内容长度
+const maxCapturedBody = 4096
+
+// 捕获响应内容的前一部分，用于提取错误信息
+type limitedBuffer struct {
+	bytes.Buffer
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remaining := maxCapturedBody - b.Len(); remaining > 0 {
+		b.Buffer.Write(p[:min(len(p), remaining)])
+	}
+	return len(p), nil
+}
+
+// Middleware 记录处理函数通过SetTarget或Record标记的操作，以及被拒绝访问的修改请求
+// 需要放在认证之前以便记录被拒绝的请求，测速、关闭连接等频繁的请求不会记录
+func Middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+			info := &requestInfo{}
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			var body limitedBuffer
+			ww.Tee(&body)
+
+			next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), contextKey{}, info)))
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if !info.recorded && status != http.StatusUnauthorized && status != http.StatusForbidden {
+				return
+			}
+			entry := Entry{
+				Time:     start,
+				IP:       clientIP(r),
+				User:     info.user,
+				Method:   r.Method,
+				Path:     r.URL.Path,
+				Action:   strings.TrimPrefix(r.URL.Path, "/api/"),
+				Target:   info.target,
+				Status:   status,
+				Success:  status < http.StatusBadRequest,
+				Duration: time.Since(start).Milliseconds(),
+			}
+			if !entry.Success {
+				entry.Error = responseError(body.Bytes())
+			}
+
+			if err := Append(entry); err != nil {
+				log.Printf("记录审计日志失败: %v", err)
+			}
+		})
+	}
+}
+
+// 请求的来源地址，不信任可以伪造的X-Forwarded-For
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// 从错误响应中提取错误信息
+func responseError(body []byte) string {
+	var response struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &response); err == nil {
+		if response.Error != "" {
+			return response.Error
+		}
+		if response.Message != "" {
+			return response.Message
+		}
+	}
+	return strings.TrimSpace(string(body))
+}
@@ -0,0 +1,149 @@
+// Package certs 生成和加载HTTPS使用的证书
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// 自签名证书的有效期，不超过浏览器接受的825天
+const SelfSignedValidity = 825 * 24 * time.Hour
+
+// EnsureSelfSigned 证书和私钥都已存在且证书未过期时直接使用，否则生成新的自签名证书
+// 有效期较短，过期后在启动时自动更换
+// hosts为证书中的域名和IP地址，本机地址总是包含在内，返回是否生成了新证书
+func EnsureSelfSigned(certPath, keyPath string, hosts []string) (bool, error) {
+	_, keyErr := os.Stat(keyPath)
+	if keyErr == nil && !expired(certPath) {
+		return false, nil
+	}
+
+	certPEM, keyPEM, err := GenerateSelfSigned(hosts)
+	if err != nil {
+		return false, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(certPath), 0755); err != nil {
+		return false, fmt.Errorf("创建证书目录失败: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(keyPath), 0700); err != nil {
+		return false, fmt.Errorf("创建私钥目录失败: %v", err)
+	}
+	if err := os.WriteFile(keyPath, keyPEM, 0600); err != nil {
+		return false, fmt.Errorf("保存私钥失败: %v", err)
+	}
+	if err := os.WriteFile(certPath, certPEM, 0644); err != nil {
+		return false, fmt.Errorf("保存证书失败: %v", err)
+	}
+	return true, nil
+}
+
+// 证书文件不存在或已过期，无法解析的文件不覆盖，由加载时报错
+func expired(certPath string) bool {
+	content, err := os.ReadFile(certPath)
+	if err != nil {
+		return os.IsNotExist(err)
+	}
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return false
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	return err == nil && time.Now().After(cert.NotAfter)
+}
+
+// GenerateSelfSigned 生成PEM格式的自签名证书和ECDSA私钥
+// 证书是不能签发其他证书的终端证书，泄露私钥时不会被用来冒充其他网站
+func GenerateSelfSigned(hosts []string) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("生成私钥失败: %v", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, fmt.Errorf("生成证书序列号失败: %v", err)
+	}
+
+	notBefore := time.Now().Add(-time.Hour)
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "clash-center", Organization: []string{"clash-center"}},
+		NotBefore:             notBefore,
+		NotAfter:              notBefore.Add(SelfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  false,
+	}
+
+	seen := make(map[string]bool)
+	for _, host := range append([]string{"localhost", "127.0.0.1", "::1"}, hosts...) {
+		if host == "" || seen[host] {
+			continue
+		}
+		seen[host] = true
+		if ip := net.ParseIP(host); ip != nil {
+			if !ip.IsUnspecified() {
+				template.IPAddresses = append(template.IPAddresses, ip)
+			}
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("生成证书失败: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("编码私钥失败: %v", err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// Load 加载证书和私钥，并检查证书是否在有效期内
+func Load(certPath, keyPath string) (tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return cert, fmt.Errorf("加载证书失败: %v", err)
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return cert, fmt.Errorf("解析证书失败: %v", err)
+	}
+	if time.Now().After(leaf.NotAfter) {
+		return cert, fmt.Errorf("证书已于 %s 过期", leaf.NotAfter.Format(time.DateOnly))
+	}
+	cert.Leaf = leaf
+	return cert, nil
+}
+
+// CertPool 返回只包含指定证书的证书池，用于信任自签名证书
+func CertPool(certPath string) (*x509.CertPool, error) {
+	content, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, fmt.Errorf("读取证书失败: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(content) {
+		return nil, fmt.Errorf("证书文件中没有有效的证书: %s", certPath)
+	}
+	return pool, nil
+}
@@ -0,0 +1,124 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func parseCert(t *testing.T, certPEM []byte) *x509.Certificate {
+	t.Helper()
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		t.Fatalf("不是PEM格式的证书: %q", certPEM)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// 写入一个已过期的证书
+func writeExpiredCert(t *testing.T, certPath string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-48 * time.Hour),
+		NotAfter:     time.Now().Add(-24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGenerateSelfSigned(t *testing.T) {
+	certPEM, _, err := GenerateSelfSigned([]string{"center.example", "192.0.2.1", "0.0.0.0", "localhost", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := parseCert(t, certPEM)
+
+	// 终端证书不能签发其他证书
+	if cert.IsCA || !cert.BasicConstraintsValid {
+		t.Errorf("证书不应是CA: IsCA=%v BasicConstraintsValid=%v", cert.IsCA, cert.BasicConstraintsValid)
+	}
+	if cert.KeyUsage&x509.KeyUsageCertSign != 0 {
+		t.Errorf("证书不应包含CertSign用途: %v", cert.KeyUsage)
+	}
+	if cert.KeyUsage&x509.KeyUsageDigitalSignature == 0 || !slices.Equal(cert.ExtKeyUsage, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}) {
+		t.Errorf("证书用途 = %v %v", cert.KeyUsage, cert.ExtKeyUsage)
+	}
+	if validity := cert.NotAfter.Sub(cert.NotBefore); validity > 825*24*time.Hour {
+		t.Errorf("有效期 %v 超过825天", validity)
+	}
+
+	// 本机地址总是包含在内，重复和未指定的地址不加入
+	if !slices.Equal(cert.DNSNames, []string{"localhost", "center.example"}) {
+		t.Errorf("域名 = %v", cert.DNSNames)
+	}
+	var ips []string
+	for _, ip := range cert.IPAddresses {
+		ips = append(ips, ip.String())
+	}
+	if !slices.Equal(ips, []string{"127.0.0.1", "::1", "192.0.2.1"}) {
+		t.Errorf("IP地址 = %v", ips)
+	}
+}
+
+func TestEnsureSelfSignedTrusted(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "tls", "cert.pem"), filepath.Join(dir, "tls", "key.pem")
+	created, err := EnsureSelfSigned(certPath, keyPath, []string{"center.example"})
+	if err != nil || !created {
+		t.Fatalf("生成证书: created=%v err=%v", created, err)
+	}
+	if info, err := os.Stat(keyPath); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("私钥权限: %v %v", info, err)
+	}
+
+	// 已存在的证书不会重新生成
+	if created, err := EnsureSelfSigned(certPath, keyPath, nil); err != nil || created {
+		t.Errorf("证书已存在时不应重新生成: created=%v err=%v", created, err)
+	}
+
+	// 过期的证书在启动时更换
+	writeExpiredCert(t, certPath)
+	if created, err := EnsureSelfSigned(certPath, keyPath, []string{"center.example"}); err != nil || !created {
+		t.Fatalf("过期的证书应重新生成: created=%v err=%v", created, err)
+	}
+
+	cert, err := Load(certPath, keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 信任该证书后可以验证域名和回环地址
+	pool, err := CertPool(certPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"center.example", "127.0.0.1"} {
+		if _, err := cert.Leaf.Verify(x509.VerifyOptions{DNSName: name, Roots: pool}); err != nil {
+			t.Errorf("验证 %s 失败: %v", name, err)
+		}
+	}
+	if _, err := cert.Leaf.Verify(x509.VerifyOptions{DNSName: "other.example", Roots: pool}); err == nil {
+		t.Error("证书中没有的域名不应通过验证")
+	}
+}
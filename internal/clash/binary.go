@@ -0,0 +1,392 @@
+package clash
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"clash-center/internal/config"
+)
+
+// 内核可执行文件的管理：检测版本和架构，替换为上传的文件并保留上一个版本用于回滚
+
+// 解压后内核文件的最大大小
+const maxBinarySize = 256 << 20
+
+var (
+	// ErrInvalidBinary 文件不是当前系统可以运行的内核
+	ErrInvalidBinary = errors.New("无效的内核文件")
+	// ErrConfigTestFailed 内核无法通过当前配置的检查
+	ErrConfigTestFailed = errors.New("内核检查当前配置失败")
+	// ErrNoPreviousBinary 没有可以回滚的内核
+	ErrNoPreviousBinary = errors.New("没有可以回滚的内核")
+)
+
+// 替换和回滚内核时加锁
+var binaryMu sync.Mutex
+
+// BinaryInfo 内核可执行文件的信息
+type BinaryInfo struct {
+	Path    string
+	Version string // 版本号，如 v1.19.0
+	Build   string // -v 输出的完整版本信息
+	OS      string
+	Arch    string
+	Size    int64
+	ModTime time.Time
+}
+
+// PreviousBinaryPath 上一个版本的内核，替换时保留用于回滚
+func PreviousBinaryPath() string {
+	return ClashPath + ".previous"
+}
+
+// 按文件大小和修改时间缓存版本信息，避免每次查询状态都运行内核
+var versionCache struct {
+	sync.Mutex
+	path    string
+	size    int64
+	modTime time.Time
+	info    BinaryInfo
+	err     error
+}
+
+// InstalledBinary 返回当前内核的信息，内核不存在或无法运行时返回错误
+func InstalledBinary() (BinaryInfo, error) {
+	return InspectBinary(ClashPath)
+}
+
+// InspectBinary 检查内核文件的架构并读取版本，结果按文件缓存
+func InspectBinary(path string) (BinaryInfo, error) {
+	resolved, err := exec.LookPath(path)
+	if err != nil {
+		return BinaryInfo{Path: path}, fmt.Errorf("找不到内核 %s", path)
+	}
+	stat, err := os.Stat(resolved)
+	if err != nil {
+		return BinaryInfo{Path: path}, err
+	}
+
+	versionCache.Lock()
+	defer versionCache.Unlock()
+	if versionCache.path == resolved && versionCache.size == stat.Size() && versionCache.modTime.Equal(stat.ModTime()) {
+		return versionCache.info, versionCache.err
+	}
+
+	info, err := inspectBinary(resolved)
+	info.Path = path
+	versionCache.path = resolved
+	versionCache.size = stat.Size()
+	versionCache.modTime = stat.ModTime()
+	versionCache.info = info
+	versionCache.err = err
+	return info, err
+}
+
+func inspectBinary(path string) (BinaryInfo, error) {
+	info := BinaryInfo{Path: path}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return info, err
+	}
+	info.Size = stat.Size()
+	info.ModTime = stat.ModTime()
+
+	info.OS, info.Arch, err = binaryPlatform(path)
+	if err != nil {
+		return info, err
+	}
+	if info.OS != runtime.GOOS || info.Arch != runtime.GOARCH {
+		return info, fmt.Errorf("%w: 内核为 %s/%s，当前系统为 %s/%s", ErrInvalidBinary, info.OS, info.Arch, runtime.GOOS, runtime.GOARCH)
+	}
+
+	info.Build, info.Version, err = binaryVersion(path)
+	return info, err
+}
+
+// 运行 -v 获取版本，输出如 Mihomo Meta v1.19.0 linux amd64 with go1.23.2 ...
+func binaryVersion(path string) (string, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cmd, err := coreCommand(ctx, path, "-v")
+	if err != nil {
+		return "", "", err
+	}
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", "", fmt.Errorf("%w: 运行 %s -v 失败: %v", ErrInvalidBinary, path, err)
+	}
+
+	build := strings.TrimSpace(firstLine(string(output)))
+	for _, field := range strings.Fields(build) {
+		if len(field) > 1 && field[0] == 'v' && field[1] >= '0' && field[1] <= '9' {
+			return build, field, nil
+		}
+	}
+	// 开发版本没有v开头的版本号，如 alpha-abc1234
+	if fields := strings.Fields(build); len(fields) >= 3 {
+		return build, fields[2], nil
+	}
+	return build, build, nil
+}
+
+// 与内核进程使用相同的用户和权限运行检查命令，上传的文件不会以clash-center的身份执行
+func coreCommand(ctx context.Context, path string, args ...string) (*exec.Cmd, error) {
+	cmd := exec.CommandContext(ctx, path, args...)
+	if err := configureCommand(cmd); err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
+
+// 根据可执行文件头判断目标系统和架构，返回Go的GOOS和GOARCH名称
+func binaryPlatform(path string) (string, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", "", err
+	}
+	defer file.Close()
+
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(file, magic); err != nil {
+		return "", "", fmt.Errorf("%w: 文件太小", ErrInvalidBinary)
+	}
+
+	switch {
+	case bytes.Equal(magic, []byte(elf.ELFMAG)):
+		f, err := elf.NewFile(file)
+		if err != nil {
+			return "", "", fmt.Errorf("%w: %v", ErrInvalidBinary, err)
+		}
+		arch := elfArch(f)
+		if arch == "" {
+			return "", "", fmt.Errorf("%w: 不支持的架构 %s", ErrInvalidBinary, f.Machine)
+		}
+		// ELF文件的OS/ABI通常为0，按Linux处理
+		return "linux", arch, nil
+	case bytes.HasPrefix(magic, []byte("MZ")):
+		f, err := pe.NewFile(file)
+		if err != nil {
+			return "", "", fmt.Errorf("%w: %v", ErrInvalidBinary, err)
+		}
+		arch, ok := peArchs[f.Machine]
+		if !ok {
+			return "", "", fmt.Errorf("%w: 不支持的架构 %#x", ErrInvalidBinary, f.Machine)
+		}
+		return "windows", arch, nil
+	default:
+		f, err := macho.NewFile(file)
+		if err != nil {
+			return "", "", fmt.Errorf("%w: 不是可执行文件", ErrInvalidBinary)
+		}
+		arch, ok := machoArchs[f.Cpu]
+		if !ok {
+			return "", "", fmt.Errorf("%w: 不支持的架构 %s", ErrInvalidBinary, f.Cpu)
+		}
+		return "darwin", arch, nil
+	}
+}
+
+// ELF文件的架构，MIPS和PowerPC需要区分字长和字节序
+func elfArch(f *elf.File) string {
+	little := f.ByteOrder == binary.LittleEndian
+	is64 := f.Class == elf.ELFCLASS64
+	switch f.Machine {
+	case elf.EM_X86_64:
+		return "amd64"
+	case elf.EM_386:
+		return "386"
+	case elf.EM_AARCH64:
+		return "arm64"
+	case elf.EM_ARM:
+		return "arm"
+	case elf.EM_RISCV:
+		return "riscv64"
+	case elf.EM_LOONGARCH:
+		return "loong64"
+	case elf.EM_S390:
+		return "s390x"
+	case elf.EM_PPC64:
+		if little {
+			return "ppc64le"
+		}
+		return "ppc64"
+	case elf.EM_MIPS:
+		arch := "mips"
+		if is64 {
+			arch = "mips64"
+		}
+		if little {
+			arch += "le"
+		}
+		return arch
+	}
+	return ""
+}
+
+var peArchs = map[uint16]string{
+	pe.IMAGE_FILE_MACHINE_AMD64: "amd64",
+	pe.IMAGE_FILE_MACHINE_I386:  "386",
+	pe.IMAGE_FILE_MACHINE_ARM64: "arm64",
+}
+
+var machoArchs = map[macho.Cpu]string{
+	macho.CpuAmd64: "amd64",
+	macho.CpuArm64: "arm64",
+}
+
+// TestConfig 使用指定的内核检查当前合并后的配置，配置文件不存在时跳过
+func TestConfig(path string) error {
+	if _, err := os.Stat(config.MergedConfigPath); err != nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	cmd, err := coreCommand(ctx, path, "-t", "-d", ClashHome, "-f", config.MergedConfigPath)
+	if err != nil {
+		return err
+	}
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w: %v\n%s", ErrConfigTestFailed, err, lastLines(string(output), 5))
+	}
+	return nil
+}
+
+// InstallBinary 保存上传的内核，支持gzip压缩的文件
+// 新内核需要与当前系统的架构一致并能通过当前配置的检查，替换前原来的内核保留为上一个版本
+func InstallBinary(src io.Reader) (BinaryInfo, error) {
+	binaryMu.Lock()
+	defer binaryMu.Unlock()
+
+	if filepath.Base(ClashPath) == ClashPath {
+		return BinaryInfo{}, fmt.Errorf("内核路径 %s 不是文件路径，无法替换", ClashPath)
+	}
+	dir := filepath.Dir(ClashPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return BinaryInfo{}, fmt.Errorf("创建内核目录失败: %v", err)
+	}
+
+	// 先写入同一目录的临时文件，检查通过后再重命名
+	tmp, err := os.CreateTemp(dir, ".clash-core-*")
+	if err != nil {
+		return BinaryInfo{}, fmt.Errorf("创建临时文件失败: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	reader := bufio.NewReader(src)
+	if magic, _ := reader.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			tmp.Close()
+			return BinaryInfo{}, fmt.Errorf("%w: 解压失败: %v", ErrInvalidBinary, err)
+		}
+		defer gz.Close()
+		reader = bufio.NewReader(gz)
+	}
+	n, err := io.Copy(tmp, io.LimitReader(reader, maxBinarySize+1))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return BinaryInfo{}, fmt.Errorf("保存内核失败: %v", err)
+	}
+	if n > maxBinarySize {
+		return BinaryInfo{}, fmt.Errorf("%w: 文件超过 %d MB", ErrInvalidBinary, maxBinarySize>>20)
+	}
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return BinaryInfo{}, fmt.Errorf("设置执行权限失败: %v", err)
+	}
+
+	info, err := verifyBinary(tmp.Name())
+	if err != nil {
+		return info, err
+	}
+
+	if _, err := os.Stat(ClashPath); err == nil {
+		if err := os.Rename(ClashPath, PreviousBinaryPath()); err != nil {
+			return info, fmt.Errorf("保留当前内核失败: %v", err)
+		}
+	}
+	if err := os.Rename(tmp.Name(), ClashPath); err != nil {
+		return info, fmt.Errorf("替换内核失败: %v", err)
+	}
+
+	info.Path = ClashPath
+	log.Printf("已安装Clash内核 %s", info.Version)
+	return info, nil
+}
+
+// RollbackBinary 换回上一个版本的内核，当前内核成为新的上一个版本，再次回滚时换回
+func RollbackBinary() (BinaryInfo, error) {
+	binaryMu.Lock()
+	defer binaryMu.Unlock()
+
+	previous := PreviousBinaryPath()
+	if _, err := os.Stat(previous); err != nil {
+		return BinaryInfo{}, ErrNoPreviousBinary
+	}
+	info, err := verifyBinary(previous)
+	if err != nil {
+		return info, err
+	}
+
+	swap := ClashPath + ".swap"
+	if err := os.Rename(ClashPath, swap); err != nil && !os.IsNotExist(err) {
+		return info, fmt.Errorf("回滚内核失败: %v", err)
+	}
+	if err := os.Rename(previous, ClashPath); err != nil {
+		os.Rename(swap, ClashPath)
+		return info, fmt.Errorf("回滚内核失败: %v", err)
+	}
+	if err := os.Rename(swap, previous); err != nil && !os.IsNotExist(err) {
+		log.Printf("保留回滚前的内核失败: %v", err)
+	}
+
+	info.Path = ClashPath
+	log.Printf("已回滚Clash内核到 %s", info.Version)
+	return info, nil
+}
+
+// 检查内核的架构和版本，并用它检查当前配置
+func verifyBinary(path string) (BinaryInfo, error) {
+	info, err := inspectBinary(path)
+	if err != nil {
+		return info, err
+	}
+	if err := TestConfig(path); err != nil {
+		return info, err
+	}
+	return info, nil
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
+// 返回最后n行，用于在错误中附带内核的输出
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,226 @@
+package clash
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"clash-center/internal/config"
+)
+
+// 设置该环境变量时测试程序作为假内核运行，值为 -v 输出的版本号
+const fakeCoreEnv = "CLASH_CENTER_FAKE_CORE"
+
+// 设置该环境变量时假内核检查配置失败
+const fakeCoreFailEnv = "CLASH_CENTER_FAKE_CORE_FAIL"
+
+func TestMain(m *testing.M) {
+	if version := os.Getenv(fakeCoreEnv); version != "" {
+		runFakeCore(version)
+	}
+	os.Exit(m.Run())
+}
+
+// 模拟内核的 -v 和 -t 参数
+func runFakeCore(version string) {
+	for _, arg := range os.Args[1:] {
+		switch arg {
+		case "-v":
+			fmt.Printf("Mihomo Meta %s %s %s with go1.24.4\n", version, runtime.GOOS, runtime.GOARCH)
+			os.Exit(0)
+		case "-t":
+			if os.Getenv(fakeCoreFailEnv) != "" {
+				fmt.Println("parse config error: proxy 0: missing type")
+				os.Exit(1)
+			}
+			fmt.Println("configuration file test is successful")
+			os.Exit(0)
+		}
+	}
+	os.Exit(2)
+}
+
+// 使用测试程序本身作为当前系统可以运行的内核，末尾附加标记用于区分不同的文件
+func fakeCoreBinary(t *testing.T, mark string) []byte {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("测试内核需要可以直接执行的文件")
+	}
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(self)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append(data, mark...)
+}
+
+// 内核和配置文件放在临时目录中
+func useTempCore(t *testing.T, version string) {
+	t.Helper()
+	dir := t.TempDir()
+	savedPath, savedHome, savedCore := config.MergedConfigPath, ClashHome, ClashPath
+	t.Cleanup(func() {
+		config.MergedConfigPath, ClashHome, ClashPath = savedPath, savedHome, savedCore
+	})
+	ClashHome = dir
+	ClashPath = filepath.Join(dir, "bin", "mihomo")
+	config.MergedConfigPath = filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(config.MergedConfigPath, []byte("mixed-port: 7890\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(fakeCoreEnv, version)
+}
+
+func assertBinaryMark(t *testing.T, path, mark string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasSuffix(data, []byte(mark)) {
+		t.Errorf("%s 不是标记为 %q 的内核", path, mark)
+	}
+}
+
+func TestInstallBinary(t *testing.T) {
+	useTempCore(t, "v1.19.0")
+
+	info, err := InstallBinary(bytes.NewReader(fakeCoreBinary(t, "first")))
+	if err != nil {
+		t.Fatalf("安装内核失败: %v", err)
+	}
+	if info.Version != "v1.19.0" || info.OS != runtime.GOOS || info.Arch != runtime.GOARCH || info.Path != ClashPath {
+		t.Errorf("内核信息 = %+v", info)
+	}
+	assertBinaryMark(t, ClashPath, "first")
+	if _, err := os.Stat(PreviousBinaryPath()); !os.IsNotExist(err) {
+		t.Errorf("第一次安装不应产生上一个版本: %v", err)
+	}
+
+	// 再次安装时原来的内核保留为上一个版本
+	if _, err := InstallBinary(bytes.NewReader(fakeCoreBinary(t, "second"))); err != nil {
+		t.Fatalf("安装内核失败: %v", err)
+	}
+	assertBinaryMark(t, ClashPath, "second")
+	assertBinaryMark(t, PreviousBinaryPath(), "first")
+
+	// 临时文件已清理
+	entries, err := os.ReadDir(filepath.Dir(ClashPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("内核目录中有 %d 个文件，应只有当前和上一个版本", len(entries))
+	}
+}
+
+func TestInstallBinaryGzip(t *testing.T) {
+	useTempCore(t, "v1.19.1")
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if _, err := gz.Write(fakeCoreBinary(t, "gzipped")); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := InstallBinary(&compressed)
+	if err != nil {
+		t.Fatalf("安装gzip压缩的内核失败: %v", err)
+	}
+	if info.Version != "v1.19.1" {
+		t.Errorf("版本 = %q", info.Version)
+	}
+	assertBinaryMark(t, ClashPath, "gzipped")
+	if stat, err := os.Stat(ClashPath); err != nil || stat.Mode().Perm()&0100 == 0 {
+		t.Errorf("安装的内核不可执行: %v", err)
+	}
+
+	// 损坏的gzip数据
+	broken := []byte{0x1f, 0x8b, 0x00, 0x01, 0x02}
+	if _, err := InstallBinary(bytes.NewReader(broken)); !errors.Is(err, ErrInvalidBinary) {
+		t.Errorf("损坏的gzip文件返回 %v，应为 ErrInvalidBinary", err)
+	}
+	assertBinaryMark(t, ClashPath, "gzipped")
+}
+
+func TestInstallBinaryRejected(t *testing.T) {
+	useTempCore(t, "v1.19.0")
+	if _, err := InstallBinary(bytes.NewReader(fakeCoreBinary(t, "current"))); err != nil {
+		t.Fatalf("安装内核失败: %v", err)
+	}
+
+	// 不是可执行文件
+	script := []byte("#!/bin/sh\necho Mihomo Meta v9.9.9\n")
+	if _, err := InstallBinary(bytes.NewReader(script)); !errors.Is(err, ErrInvalidBinary) {
+		t.Errorf("shell脚本返回 %v，应为 ErrInvalidBinary", err)
+	}
+
+	// 无法通过当前配置的检查
+	t.Setenv(fakeCoreFailEnv, "1")
+	_, err := InstallBinary(bytes.NewReader(fakeCoreBinary(t, "broken")))
+	if !errors.Is(err, ErrConfigTestFailed) {
+		t.Fatalf("配置检查失败时返回 %v，应为 ErrConfigTestFailed", err)
+	}
+	if !strings.Contains(err.Error(), "missing type") {
+		t.Errorf("错误中没有内核的输出: %v", err)
+	}
+
+	// 被拒绝的文件不会替换当前内核
+	assertBinaryMark(t, ClashPath, "current")
+	if _, err := os.Stat(PreviousBinaryPath()); !os.IsNotExist(err) {
+		t.Errorf("被拒绝的文件不应产生上一个版本: %v", err)
+	}
+}
+
+func TestRollbackBinary(t *testing.T) {
+	useTempCore(t, "v1.19.0")
+
+	if _, err := RollbackBinary(); !errors.Is(err, ErrNoPreviousBinary) {
+		t.Errorf("没有上一个版本时返回 %v，应为 ErrNoPreviousBinary", err)
+	}
+
+	for _, mark := range []string{"old", "new"} {
+		if _, err := InstallBinary(bytes.NewReader(fakeCoreBinary(t, mark))); err != nil {
+			t.Fatalf("安装内核失败: %v", err)
+		}
+	}
+
+	info, err := RollbackBinary()
+	if err != nil {
+		t.Fatalf("回滚失败: %v", err)
+	}
+	if info.Path != ClashPath || info.Version != "v1.19.0" {
+		t.Errorf("回滚后的内核信息 = %+v", info)
+	}
+	assertBinaryMark(t, ClashPath, "old")
+	assertBinaryMark(t, PreviousBinaryPath(), "new")
+
+	// 再次回滚时换回
+	if _, err := RollbackBinary(); err != nil {
+		t.Fatalf("再次回滚失败: %v", err)
+	}
+	assertBinaryMark(t, ClashPath, "new")
+	assertBinaryMark(t, PreviousBinaryPath(), "old")
+	if _, err := os.Stat(ClashPath + ".swap"); !os.IsNotExist(err) {
+		t.Errorf("回滚后遗留了临时文件: %v", err)
+	}
+
+	// 上一个版本无法通过配置检查时不回滚
+	t.Setenv(fakeCoreFailEnv, "1")
+	if _, err := RollbackBinary(); !errors.Is(err, ErrConfigTestFailed) {
+		t.Errorf("配置检查失败时返回 %v，应为 ErrConfigTestFailed", err)
+	}
+	assertBinaryMark(t, ClashPath, "new")
+}
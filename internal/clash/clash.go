@@ -1,63 +1,178 @@
 package clash
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"clash-center/internal/config"
+	"clash-center/internal/events"
+	"clash-center/internal/metrics"
 )
 
 var (
-	// Clash 进程
+	// Clash 进程，接管已在运行的内核时为nil
 	ClashCmd *exec.Cmd
-	// Clash 运行状态
-	IsRunning bool
 	// Clash 可执行文件
 	ClashPath = "./clash/clash.meta"
 	// Clash 主目录
 	ClashHome = "./clash"
+
+	// 保护下面的进程状态，进程等待协程和各个接口会同时访问
+	stateMu sync.Mutex
+	// Clash 运行状态
+	running bool
+	// 当前的内核进程，包括启动的和接管的
+	process *os.Process
+	// 当前进程是否是接管的已在运行的内核
+	adopted bool
+	// 当前进程退出时关闭
+	exited chan struct{}
+	// 当前进程的启动时间
+	startedAt time.Time
+	// 当前进程是否由StopClash主动停止，用于区分崩溃
+	stopRequested *atomic.Bool
+
+	startsTotal  = metrics.NewCounter("clash_center_core_starts_total", "Clash内核启动次数")
+	crashesTotal = metrics.NewCounter("clash_center_core_crashes_total", "Clash内核意外退出次数")
 )
 
-// 启动 Clash 服务
-func StartClash() error {
-	if IsRunning {
-		StopClash()
+// IsRunning 内核是否正在运行
+func IsRunning() bool {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	return running
+}
+
+// 当前进程已运行的时间，未运行时为0
+func Uptime() time.Duration {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	if !running {
+		return 0
 	}
+	return time.Since(startedAt)
+}
 
-	log.Printf("启动Clash\n")
+// PID 当前内核进程的PID，未运行时为0
+func PID() int {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	if !running || process == nil {
+		return 0
+	}
+	return process.Pid
+}
+
+// Adopted 当前内核是否是启动时接管的已在运行的进程
+func Adopted() bool {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	return running && adopted
+}
 
+// 启动 Clash 服务
+func StartClash() error {
+	// 在停止正在运行的内核之前检查，内核不存在时保持原状态
 	path, err := exec.LookPath(ClashPath)
 	if err != nil {
-		log.Fatal("Clash.Meta not found")
+		return fmt.Errorf("找不到Clash内核 %s，请安装或上传内核", ClashPath)
+	}
+
+	if IsRunning() {
+		StopClash()
+	}
+
+	// 控制器端口被占用时新内核无法正常工作
+	if conflict := Conflict(); conflict != "" {
+		return fmt.Errorf("%s，请先停止该程序", conflict)
 	}
 
+	log.Printf("启动Clash\n")
+
 	// 构建启动命令
 	ClashCmd = exec.Command(path, "-d", ClashHome)
 
-	// 设置输出
-	ClashCmd.Stdout = os.Stdout
-	ClashCmd.Stderr = os.Stderr
+	// 设置输出，同时保留最近的输出供查看
+	output := io.MultiWriter(os.Stdout, coreLogs)
+	ClashCmd.Stdout = output
+	ClashCmd.Stderr = output
+
+	// 设置运行用户、capabilities和进程组
+	if err := configureCommand(ClashCmd); err != nil {
+		return err
+	}
 
 	// 启动进程
 	err = ClashCmd.Start()
+	if errors.Is(err, os.ErrPermission) {
+		return fmt.Errorf("启动Clash失败，权限不足，请检查 core.user 和 core.capabilities 设置: %v", err)
+	}
 	if err != nil {
 		return fmt.Errorf("启动Clash失败: %v", err)
 	}
+	if err := afterStart(ClashCmd.Process.Pid); err != nil {
+		log.Printf("警告: %v", err)
+	}
 
-	IsRunning = true
+	startsTotal.Inc()
+	writePIDFile(ClashCmd.Process.Pid)
+	watchProcess(ClashCmd.Process, ClashCmd.Wait, time.Now(), false)
+	return nil
+}
+
+// 开始跟踪内核进程，wait在进程退出时返回
+func watchProcess(proc *os.Process, wait func() error, started time.Time, isAdopted bool) {
+	stopping := new(atomic.Bool)
+	done := make(chan struct{})
+	stateMu.Lock()
+	running = true
+	process = proc
+	adopted = isAdopted
+	startedAt = started
+	stopRequested = stopping
+	exited = done
+	stateMu.Unlock()
+
+	// 恢复并跟踪当前配置的代理组选择，接管的内核保留当前的选择
+	trackSelections(config.OriginalConfigName, done, !isAdopted)
+
+	configName := config.OriginalConfigName
+	events.Publish(events.CoreStarted, map[string]any{
+		"config":  configName,
+		"pid":     proc.Pid,
+		"adopted": isAdopted,
+	})
 
 	// 异步等待进程结束
 	go func() {
-		err := ClashCmd.Wait()
+		err := wait()
 		if err != nil {
 			log.Printf("Clash进程结束，错误: %v", err)
 		}
-		IsRunning = false
+		if !stopping.Load() {
+			crashesTotal.Inc()
+			data := map[string]any{"config": configName}
+			if err != nil {
+				data["error"] = err.Error()
+			}
+			events.Publish(events.CoreCrashed, data)
+		}
+		// 重启时旧进程可能在新进程启动后才退出，此时不能修改运行状态
+		stateMu.Lock()
+		if process == proc {
+			running = false
+			removePIDFile()
+		}
+		stateMu.Unlock()
+		close(done)
 	}()
-
-	return nil
 }
 
 // 使用当前配置启动Clash
@@ -86,20 +201,41 @@ func StartClashWithCurrentConfig() error {
 
 // 停止 Clash 服务
 func StopClash() error {
-	if !IsRunning || ClashCmd == nil {
+	stateMu.Lock()
+	proc, done, stopping := process, exited, stopRequested
+	isRunning := running
+	stateMu.Unlock()
+	if !isRunning || proc == nil {
 		return nil
 	}
 
 	log.Println("停止Clash服务...")
 
+	// 停止前保存代理组选择，下次启动时恢复
+	captureSelections(currentTracker())
+
 	// 终止进程
-	if ClashCmd.Process != nil {
-		err := ClashCmd.Process.Kill()
-		if err != nil {
-			return fmt.Errorf("无法终止Clash进程: %v", err)
-		}
+	if stopping != nil {
+		stopping.Store(true)
+	}
+	if err := killProcess(proc); err != nil {
+		return fmt.Errorf("无法终止Clash进程: %v", err)
 	}
 
-	IsRunning = false
+	// 等待进程退出，避免新进程启动时端口仍被占用
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		log.Printf("等待Clash进程退出超时")
+	}
+
+	// 期间可能已经启动了新的进程
+	stateMu.Lock()
+	if process == proc {
+		running = false
+		removePIDFile()
+	}
+	stateMu.Unlock()
+	events.Publish(events.CoreStopped, nil)
 	return nil
 }
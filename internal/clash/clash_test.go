@@ -0,0 +1,136 @@
+package clash
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"clash-center/internal/config"
+)
+
+// 启动一个很快退出的脚本作为内核
+func startShortCore(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("测试内核使用shell脚本")
+	}
+
+	dir := t.TempDir()
+	savedPath, savedHome, savedCore := config.MergedConfigPath, ClashHome, ClashPath
+	t.Cleanup(func() {
+		StopClash()
+		config.MergedConfigPath, ClashHome, ClashPath = savedPath, savedHome, savedCore
+	})
+	ClashHome = dir
+	ClashPath = filepath.Join(dir, "fake-core")
+	if err := os.WriteFile(ClashPath, []byte("#!/bin/sh\nexec sleep 0.3\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// 控制器地址指向未被占用的端口，以免被当作冲突
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.Close()
+	config.MergedConfigPath = filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(config.MergedConfigPath, []byte("external-controller: "+ln.Addr().String()+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := StartClash(); err != nil {
+		t.Fatalf("启动测试内核失败: %v", err)
+	}
+}
+
+func TestRunningStateWhileProcessExits(t *testing.T) {
+	startShortCore(t)
+	if !IsRunning() || PID() == 0 {
+		t.Fatal("启动后没有处于运行状态")
+	}
+
+	// 进程退出时等待协程修改状态，同时读取状态不应产生数据竞争
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					IsRunning()
+					PID()
+					Uptime()
+					Adopted()
+				}
+			}
+		}()
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for IsRunning() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(stop)
+	wg.Wait()
+
+	if IsRunning() || PID() != 0 || Uptime() != 0 {
+		t.Fatal("进程退出后仍处于运行状态")
+	}
+	if _, err := os.Stat(PIDFilePath()); !os.IsNotExist(err) {
+		t.Errorf("进程退出后PID文件仍然存在: %v", err)
+	}
+}
+
+func TestCachedConflict(t *testing.T) {
+	// 控制器地址被一个不是内核的程序占用
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	dir := t.TempDir()
+	savedPath, savedTTL := config.MergedConfigPath, conflictCacheTTL
+	t.Cleanup(func() { config.MergedConfigPath, conflictCacheTTL = savedPath, savedTTL })
+	config.MergedConfigPath = filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(config.MergedConfigPath, []byte("external-controller: "+ln.Addr().String()+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	conflict := CachedConflict()
+	if conflict == "" {
+		t.Fatal("控制器地址被占用时没有报告冲突")
+	}
+
+	// 缓存时间内不再连接控制器
+	ln.Close()
+	if got := CachedConflict(); got != conflict {
+		t.Errorf("缓存时间内返回 %q，应为上次的结果", got)
+	}
+
+	// 启动前的检查不使用缓存，并更新缓存
+	if got := Conflict(); got != "" {
+		t.Errorf("占用的程序退出后仍报告冲突: %q", got)
+	}
+	if got := CachedConflict(); got != "" {
+		t.Errorf("重新检查后缓存没有更新: %q", got)
+	}
+
+	// 缓存过期后重新检查
+	ln2, err := net.Listen("tcp", ln.Addr().String())
+	if err != nil {
+		t.Skipf("无法重新监听 %s: %v", ln.Addr(), err)
+	}
+	defer ln2.Close()
+	conflictCacheTTL = 0
+	if CachedConflict() == "" {
+		t.Error("缓存过期后没有重新检查")
+	}
+}
@@ -0,0 +1,12 @@
+package clash
+
+import (
+	"clash-center/internal/config"
+	"clash-center/internal/mihomo"
+)
+
+// 创建当前内核控制器的客户端，地址和密钥来自合并后的配置
+func NewControllerClient() *mihomo.Client {
+	controller := config.GetControllerInfo()
+	return mihomo.NewClient(controller.Address, controller.Secret)
+}
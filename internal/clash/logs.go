@@ -0,0 +1,51 @@
+package clash
+
+import (
+	"bytes"
+	"sync"
+)
+
+// 保留的内核输出行数
+var LogBufferLines = 500
+
+// 保存内核最近的输出，供API和命令行查看
+type logBuffer struct {
+	mu      sync.Mutex
+	lines   []string
+	partial []byte
+}
+
+var coreLogs = &logBuffer{}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	data := append(b.partial, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		b.lines = append(b.lines, string(bytes.TrimRight(data[:i], "\r")))
+		data = data[i+1:]
+	}
+	b.partial = append([]byte(nil), data...)
+
+	if overflow := len(b.lines) - LogBufferLines; overflow > 0 {
+		b.lines = append([]string(nil), b.lines[overflow:]...)
+	}
+	return len(p), nil
+}
+
+// RecentLogs 返回内核最近的n行输出，n不大于0时返回全部
+func RecentLogs(n int) []string {
+	coreLogs.mu.Lock()
+	defer coreLogs.mu.Unlock()
+
+	lines := coreLogs.lines
+	if n > 0 && len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return append([]string{}, lines...)
+}
@@ -0,0 +1,67 @@
+//go:build !windows
+
+package clash
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// 检查进程是否存在，属于其他用户的进程同样视为存在
+func processAlive(pid int) bool {
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = proc.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// 进程的可执行文件路径，没有/proc时返回空字符串
+func processExecutable(pid int) string {
+	exe, err := os.Readlink("/proc/" + strconv.Itoa(pid) + "/exe")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(exe, " (deleted)")
+}
+
+// /proc中的时间单位，Linux用户空间固定为每秒100
+const userHZ = 100
+
+// 进程的启动时间，根据/proc/<pid>/stat中的启动时刻和系统启动时间计算，无法确定时返回当前时间
+func processStartTime(pid int) time.Time {
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return time.Now()
+	}
+	// 进程名可能包含空格，从最后一个右括号之后开始解析，starttime为第22个字段
+	content := string(stat)
+	fields := strings.Fields(content[strings.LastIndex(content, ")")+1:])
+	if len(fields) < 20 {
+		return time.Now()
+	}
+	ticks, err := strconv.ParseInt(fields[19], 10, 64)
+	if err != nil {
+		return time.Now()
+	}
+
+	procStat, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return time.Now()
+	}
+	for _, line := range strings.Split(string(procStat), "\n") {
+		if value, ok := strings.CutPrefix(line, "btime "); ok {
+			boot, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			if err != nil {
+				break
+			}
+			return time.Unix(boot, 0).Add(time.Duration(ticks) * time.Second / userHZ)
+		}
+	}
+	return time.Now()
+}
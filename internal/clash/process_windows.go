@@ -0,0 +1,54 @@
+package clash
+
+import (
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	// 查询进程状态和路径所需的最小权限
+	processQueryLimitedInformation = 0x1000
+	// GetExitCodeProcess 对仍在运行的进程返回的退出码
+	stillActive = 259
+)
+
+var procQueryFullProcessImageName = syscall.NewLazyDLL("kernel32.dll").NewProc("QueryFullProcessImageNameW")
+
+// 检查进程是否存在，已退出但句柄未释放的进程视为不存在
+func processAlive(pid int) bool {
+	handle, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		// 没有权限打开的进程仍然存在
+		return err == syscall.ERROR_ACCESS_DENIED
+	}
+	defer syscall.CloseHandle(handle)
+
+	var code uint32
+	if err := syscall.GetExitCodeProcess(handle, &code); err != nil {
+		return false
+	}
+	return code == stillActive
+}
+
+// 进程的可执行文件路径，无法打开进程时返回空字符串
+func processExecutable(pid int) string {
+	handle, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		return ""
+	}
+	defer syscall.CloseHandle(handle)
+
+	buf := make([]uint16, syscall.MAX_LONG_PATH)
+	size := uint32(len(buf))
+	ret, _, _ := procQueryFullProcessImageName.Call(uintptr(handle), 0, uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&size)))
+	if ret == 0 {
+		return ""
+	}
+	return syscall.UTF16ToString(buf[:size])
+}
+
+// 无法确定进程的启动时间，使用当前时间
+func processStartTime(pid int) time.Time {
+	return time.Now()
+}
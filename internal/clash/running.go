@@ -0,0 +1,197 @@
+package clash
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"clash-center/internal/config"
+)
+
+// 检查接管的内核是否退出的间隔
+var adoptedPollInterval = 500 * time.Millisecond
+
+// 状态接口使用的冲突检查结果的缓存时间，避免每次轮询都连接控制器
+var conflictCacheTTL = 5 * time.Second
+
+var conflictCache struct {
+	sync.Mutex
+	address string
+	checked time.Time
+	result  string
+}
+
+// PIDFilePath 内核的PID文件，clash-center重启后据此找到仍在运行的内核
+func PIDFilePath() string {
+	return filepath.Join(ClashHome, "clash.pid")
+}
+
+func writePIDFile(pid int) {
+	if err := os.WriteFile(PIDFilePath(), []byte(strconv.Itoa(pid)+"\n"), 0644); err != nil {
+		log.Printf("写入PID文件失败: %v", err)
+	}
+}
+
+func readPIDFile() (int, error) {
+	content, err := os.ReadFile(PIDFilePath())
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("PID文件内容无效: %q", strings.TrimSpace(string(content)))
+	}
+	return pid, nil
+}
+
+func removePIDFile() {
+	if err := os.Remove(PIDFilePath()); err != nil && !os.IsNotExist(err) {
+		log.Printf("删除PID文件失败: %v", err)
+	}
+}
+
+// DetectRunning 在启动时检查是否已有内核在运行
+// PID文件中的进程仍是内核时接管该进程，之后可以正常监控和停止；控制器端口被其他程序占用时记录警告
+func DetectRunning() {
+	if IsRunning() {
+		return
+	}
+
+	pid, err := readPIDFile()
+	if err == nil {
+		switch exe := processExecutable(pid); {
+		case !processAlive(pid):
+			log.Printf("PID文件中的内核进程 %d 已不在运行", pid)
+		case exe == "":
+			// PID可能已被其他进程复用，无法确认时不接管，以免停止内核时结束无关的进程
+			log.Printf("无法确定进程 %d 的可执行文件，不接管该进程", pid)
+		case isCoreExecutable(exe):
+			adoptProcess(pid)
+			return
+		default:
+			log.Printf("PID文件中的进程 %d 已不是内核（%s）", pid, exe)
+		}
+		removePIDFile()
+	} else if !os.IsNotExist(err) {
+		log.Printf("读取PID文件失败: %v", err)
+		removePIDFile()
+	}
+
+	if conflict := Conflict(); conflict != "" {
+		log.Printf("警告: %s", conflict)
+	}
+}
+
+// Conflict 内核不由clash-center管理而控制器地址已被占用时，返回冲突的说明
+func Conflict() string {
+	if IsRunning() {
+		return ""
+	}
+
+	address := config.GetControllerInfo().Address
+	result := checkConflict(address)
+
+	conflictCache.Lock()
+	conflictCache.address, conflictCache.checked, conflictCache.result = address, time.Now(), result
+	conflictCache.Unlock()
+	return result
+}
+
+// CachedConflict 与Conflict相同，但在缓存时间内返回上次的检查结果，用于状态查询
+// 启动内核前总是重新检查，结果同时更新缓存
+func CachedConflict() string {
+	if IsRunning() {
+		return ""
+	}
+
+	address := config.GetControllerInfo().Address
+	conflictCache.Lock()
+	if conflictCache.address == address && time.Since(conflictCache.checked) < conflictCacheTTL {
+		result := conflictCache.result
+		conflictCache.Unlock()
+		return result
+	}
+	conflictCache.Unlock()
+	return Conflict()
+}
+
+// 连接控制器地址，地址被占用时返回冲突的说明
+func checkConflict(address string) string {
+	conn, err := net.DialTimeout("tcp", address, 500*time.Millisecond)
+	if err != nil {
+		return ""
+	}
+	conn.Close()
+
+	if version := controllerVersion(); version != "" {
+		return fmt.Sprintf("控制器地址 %s 上已有不是由clash-center管理的内核在运行（%s）", address, version)
+	}
+	return fmt.Sprintf("控制器地址 %s 已被其他程序占用", address)
+}
+
+// 通过控制器获取内核版本，控制器不可用时返回空字符串
+func controllerVersion() string {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	version, err := NewControllerClient().Version(ctx)
+	if err != nil {
+		return ""
+	}
+	return version.Version
+}
+
+// 运行中的内核可能在替换后被重命名为上一个版本
+func isCoreExecutable(exe string) bool {
+	path, err := exec.LookPath(ClashPath)
+	if err != nil {
+		path = ClashPath
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	return samePath(exe, path) || samePath(exe, path+".previous")
+}
+
+// Windows的路径不区分大小写
+func samePath(a, b string) bool {
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// 接管已在运行的内核，定期检查进程是否退出
+func adoptProcess(pid int) {
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		log.Printf("接管内核进程 %d 失败: %v", pid, err)
+		return
+	}
+
+	// 接管的内核使用上次的配置
+	if config.OriginalConfigName == "" {
+		config.OriginalConfigName = config.LoadAppConfig().LastConfig
+	}
+
+	ClashCmd = nil
+	wait := func() error {
+		for processAlive(pid) {
+			time.Sleep(adoptedPollInterval)
+		}
+		return nil
+	}
+	watchProcess(proc, wait, processStartTime(pid), true)
+	log.Printf("已接管正在运行的Clash内核，PID %d", pid)
+}
@@ -0,0 +1,193 @@
+package clash
+
+import (
+	"context"
+	"log"
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"clash-center/internal/config"
+)
+
+var (
+	// 定期从控制器同步代理组选择的间隔，用于记住在其他面板中做出的选择
+	SelectionPollInterval = time.Minute
+	// 启动后等待控制器可用的最长时间
+	ControllerReadyTimeout = 30 * time.Second
+)
+
+// 当前内核进程的代理组选择状态
+type selectionTracker struct {
+	configName string
+	done       chan struct{}
+	// 恢复完成之前控制器中的选择都是默认值，不能保存
+	restored atomic.Bool
+}
+
+var (
+	trackerMu sync.Mutex
+	tracker   *selectionTracker
+)
+
+// 内核启动后恢复上次的代理组选择，之后定期保存当前选择
+// 接管已在运行的内核时restore为false，保留内核中当前的选择
+func trackSelections(configName string, done chan struct{}, restore bool) {
+	t := &selectionTracker{configName: configName, done: done}
+	trackerMu.Lock()
+	tracker = t
+	trackerMu.Unlock()
+
+	if configName == "" {
+		return
+	}
+
+	go func() {
+		if !waitControllerReady(done) {
+			return
+		}
+		if restore {
+			restoreSelections(configName)
+		}
+		t.restored.Store(true)
+
+		ticker := time.NewTicker(SelectionPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				captureSelections(t)
+			}
+		}
+	}()
+}
+
+// 获取当前内核进程的选择状态
+func currentTracker() *selectionTracker {
+	trackerMu.Lock()
+	defer trackerMu.Unlock()
+	return tracker
+}
+
+// 等待控制器可用，进程退出或超时返回false
+func waitControllerReady(done chan struct{}) bool {
+	client := NewControllerClient()
+	deadline := time.Now().Add(ControllerReadyTimeout)
+	for time.Now().Before(deadline) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		_, err := client.Version(ctx)
+		cancel()
+		if err == nil {
+			return true
+		}
+
+		select {
+		case <-done:
+			return false
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+
+	log.Printf("等待Clash控制器超时，未恢复代理组选择")
+	return false
+}
+
+// 通过控制器重新选择上次记住的节点，不存在的代理组和节点会被跳过
+func restoreSelections(configName string) {
+	selections := config.GetSelections(configName)
+	if len(selections) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client := NewControllerClient()
+	proxies, err := client.Proxies(ctx)
+	if err != nil {
+		log.Printf("获取代理组失败，未恢复代理组选择: %v", err)
+		return
+	}
+
+	restored := 0
+	for group, node := range selections {
+		proxy, ok := proxies[group]
+		if !ok || proxy.Type != "Selector" {
+			log.Printf("代理组 %s 已不存在，跳过恢复", group)
+			continue
+		}
+		if !slices.Contains(proxy.All, node) {
+			log.Printf("代理组 %s 中的节点 %s 已不存在，跳过恢复", group, node)
+			continue
+		}
+		if proxy.Now == node {
+			continue
+		}
+
+		if err := client.SelectProxy(ctx, group, node); err != nil {
+			log.Printf("恢复代理组 %s 的选择失败: %v", group, err)
+			continue
+		}
+		restored++
+	}
+
+	log.Printf("已恢复配置 %s 的 %d 个代理组选择\n", configName, restored)
+}
+
+// 从控制器读取所有select代理组的当前选择并保存
+func captureSelections(t *selectionTracker) {
+	if t == nil || t.configName == "" || !t.restored.Load() {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	proxies, err := NewControllerClient().Proxies(ctx)
+	if err != nil {
+		log.Printf("获取代理组选择失败: %v", err)
+		return
+	}
+
+	selections := make(map[string]string)
+	for name, proxy := range proxies {
+		if proxy.Type == "Selector" && proxy.Now != "" {
+			selections[name] = proxy.Now
+		}
+	}
+
+	if maps.Equal(selections, config.GetSelections(t.configName)) {
+		return
+	}
+	if err := config.SaveSelections(t.configName, selections); err != nil {
+		log.Printf("保存代理组选择失败: %v", err)
+	}
+}
+
+// RememberSelection 记住通过API做出的选择，不必等到下一次同步
+func RememberSelection(group, node string) {
+	t := currentTracker()
+	if t == nil || t.configName == "" || !t.restored.Load() {
+		return
+	}
+
+	selections := maps.Clone(config.GetSelections(t.configName))
+	if selections == nil {
+		selections = make(map[string]string)
+	}
+	selections[group] = node
+	if err := config.SaveSelections(t.configName, selections); err != nil {
+		log.Printf("保存代理组选择失败: %v", err)
+	}
+}
+
+// SaveSelections 保存当前的代理组选择，用于退出时保留内核运行的情况
+func SaveSelections() {
+	if IsRunning() {
+		captureSelections(currentTracker())
+	}
+}
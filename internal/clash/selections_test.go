@@ -0,0 +1,178 @@
+package clash
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"clash-center/internal/config"
+	"clash-center/internal/mihomo"
+)
+
+// 模拟控制器的代理组状态，记录收到的选择请求
+type fakeController struct {
+	mu      sync.Mutex
+	proxies map[string]mihomo.Proxy
+	// 返回错误的代理组
+	failing map[string]bool
+	selects []string
+	fail    bool
+}
+
+func (f *fakeController) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if f.fail {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/proxies":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"proxies": f.proxies})
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/proxies/"):
+		group := strings.TrimPrefix(r.URL.Path, "/proxies/")
+		var body struct {
+			Name string `json:"name"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		f.selects = append(f.selects, group+"="+body.Name)
+		if f.failing[group] {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"message": "Selector update error"})
+			return
+		}
+		proxy := f.proxies[group]
+		proxy.Now = body.Name
+		f.proxies[group] = proxy
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// 启动模拟控制器，合并配置中的控制器地址和密钥指向它，应用配置使用临时文件
+func useFakeController(t *testing.T, proxies map[string]mihomo.Proxy) *fakeController {
+	t.Helper()
+	f := &fakeController{proxies: proxies, failing: make(map[string]bool)}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+
+	dir := t.TempDir()
+	savedMerged, savedApp := config.MergedConfigPath, config.AppConfigPath
+	t.Cleanup(func() { config.MergedConfigPath, config.AppConfigPath = savedMerged, savedApp })
+	config.MergedConfigPath = filepath.Join(dir, "config.yaml")
+	config.AppConfigPath = filepath.Join(dir, "app_config.json")
+	content := "external-controller: " + server.Listener.Addr().String() + "\nsecret: secret\n"
+	if err := os.WriteFile(config.MergedConfigPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// 测试使用的代理组
+func testProxies() map[string]mihomo.Proxy {
+	return map[string]mihomo.Proxy{
+		"节点选择": {Name: "节点选择", Type: "Selector", Now: "自动选择", All: []string{"自动选择", "hk", "jp"}},
+		"香港":   {Name: "香港", Type: "Selector", Now: "hk", All: []string{"hk"}},
+		"日本":   {Name: "日本", Type: "Selector", Now: "jp", All: []string{"jp"}},
+		"自动选择": {Name: "自动选择", Type: "URLTest", Now: "jp", All: []string{"hk", "jp"}},
+		"hk":   {Name: "hk", Type: "Shadowsocks"},
+		"jp":   {Name: "jp", Type: "Shadowsocks"},
+	}
+}
+
+func TestRestoreSelections(t *testing.T) {
+	f := useFakeController(t, testProxies())
+	f.proxies["流媒体"] = mihomo.Proxy{Name: "流媒体", Type: "Selector", Now: "hk", All: []string{"hk", "jp"}}
+	f.failing["流媒体"] = true
+
+	if err := config.SaveSelections("sub.yaml", map[string]string{
+		"节点选择": "jp",
+		// 当前已经是记住的节点，不需要选择
+		"香港": "hk",
+		// 节点已不存在
+		"日本": "jp2",
+		// 代理组已不存在
+		"美国": "us",
+		// 不是select类型的代理组
+		"自动选择": "hk",
+		// 控制器返回错误时继续恢复其他代理组
+		"流媒体": "jp",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	restoreSelections("sub.yaml")
+
+	slices.Sort(f.selects)
+	if want := []string{"流媒体=jp", "节点选择=jp"}; !slices.Equal(f.selects, want) {
+		t.Errorf("选择请求 = %v，应为 %v", f.selects, want)
+	}
+	if now := f.proxies["节点选择"].Now; now != "jp" {
+		t.Errorf("节点选择当前为 %s，应为 jp", now)
+	}
+
+	// 没有记住的选择时不访问控制器
+	f.selects = nil
+	f.fail = true
+	restoreSelections("other.yaml")
+	if len(f.selects) != 0 {
+		t.Errorf("没有记住的选择时发送了请求: %v", f.selects)
+	}
+}
+
+func TestCaptureSelections(t *testing.T) {
+	f := useFakeController(t, testProxies())
+	f.proxies["空"] = mihomo.Proxy{Name: "空", Type: "Selector", All: []string{}}
+	tr := &selectionTracker{configName: "sub.yaml"}
+
+	// 恢复完成之前不保存控制器中的默认选择
+	captureSelections(tr)
+	if got := config.GetSelections("sub.yaml"); got != nil {
+		t.Errorf("恢复完成之前保存了选择: %v", got)
+	}
+
+	// 只保存有当前选择的select代理组
+	tr.restored.Store(true)
+	captureSelections(tr)
+	want := map[string]string{"节点选择": "自动选择", "香港": "hk", "日本": "jp"}
+	if got := config.GetSelections("sub.yaml"); !maps.Equal(got, want) {
+		t.Errorf("保存的选择 = %v，应为 %v", got, want)
+	}
+
+	// 在其他面板中做出的选择在下次同步时保存
+	f.proxies["节点选择"] = mihomo.Proxy{Name: "节点选择", Type: "Selector", Now: "hk", All: []string{"自动选择", "hk", "jp"}}
+	captureSelections(tr)
+	if got := config.GetSelections("sub.yaml")["节点选择"]; got != "hk" {
+		t.Errorf("节点选择 = %s，应为 hk", got)
+	}
+
+	// 控制器不可用时保留已保存的选择
+	f.fail = true
+	captureSelections(tr)
+	if got := config.GetSelections("sub.yaml"); len(got) != 3 {
+		t.Errorf("控制器不可用时修改了保存的选择: %v", got)
+	}
+
+	// 没有配置名称时不保存
+	f.fail = false
+	captureSelections(&selectionTracker{})
+	captureSelections(nil)
+	if selections := config.LoadAppConfig().Selections; len(selections) != 1 {
+		t.Errorf("保存了没有配置名称的选择: %v", selections)
+	}
+}
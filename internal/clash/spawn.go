@@ -0,0 +1,56 @@
+package clash
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// 内核进程的运行方式，只在Linux上支持
+var (
+	// 运行内核的用户名或UID，为空时与clash-center相同
+	CoreUser string
+	// 运行内核的用户组名或GID，为空时使用用户的主组
+	CoreGroup string
+	// 以其他用户运行时授予内核的ambient capabilities，如 CAP_NET_ADMIN
+	CoreCapabilities []string
+	// 内核的打开文件数限制，0表示不修改
+	CoreNoFile uint64
+	// clash-center意外退出时内核随之退出，退出时保留内核运行的情况下关闭
+	KillWithParent bool
+)
+
+// 可以授予内核的capabilities及其编号
+var capabilityNumbers = map[string]int{
+	"CAP_DAC_READ_SEARCH":  2,
+	"CAP_NET_BIND_SERVICE": 10,
+	"CAP_NET_BROADCAST":    11,
+	"CAP_NET_ADMIN":        12,
+	"CAP_NET_RAW":          13,
+	"CAP_SYS_PTRACE":       19,
+	"CAP_SYS_RESOURCE":     24,
+}
+
+// ParseCapability 解析capability名称，不区分大小写，可以省略 CAP_ 前缀
+func ParseCapability(name string) (int, error) {
+	normalized := strings.ToUpper(strings.TrimSpace(name))
+	if !strings.HasPrefix(normalized, "CAP_") {
+		normalized = "CAP_" + normalized
+	}
+	number, ok := capabilityNumbers[normalized]
+	if !ok {
+		names := make([]string, 0, len(capabilityNumbers))
+		for known := range capabilityNumbers {
+			names = append(names, known)
+		}
+		sort.Strings(names)
+		return 0, fmt.Errorf("不支持的capability %q，可用的有 %s", name, strings.Join(names, ", "))
+	}
+	return number, nil
+}
+
+// CheckProcessSettings 检查运行内核的用户、capabilities和文件数限制，当前进程权限不足时返回错误
+func CheckProcessSettings() error {
+	return configureCommand(&exec.Cmd{})
+}
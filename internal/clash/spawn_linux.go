@@ -0,0 +1,191 @@
+package clash
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	capSetGID      = 6
+	capSetUID      = 7
+	capSysResource = 24
+)
+
+// 设置内核进程的运行用户、capabilities和进程组，并检查当前进程是否有足够的权限
+func configureCommand(cmd *exec.Cmd) error {
+	attr := &syscall.SysProcAttr{
+		// 内核和它启动的子进程使用单独的进程组，停止时一起终止
+		Setpgid: true,
+	}
+	if KillWithParent {
+		attr.Pdeathsig = syscall.SIGKILL
+	}
+
+	switchUser := false
+	if CoreUser != "" {
+		cred, err := lookupCredential(CoreUser, CoreGroup)
+		if err != nil {
+			return err
+		}
+		switchUser = cred.Uid != uint32(os.Geteuid()) || cred.Gid != uint32(os.Getegid())
+		if switchUser && (!hasCapability("CapEff", capSetUID) || !hasCapability("CapEff", capSetGID)) {
+			return fmt.Errorf("以用户 %s 运行内核需要以root运行clash-center，或者授予 CAP_SETUID 和 CAP_SETGID", CoreUser)
+		}
+
+		for _, name := range CoreCapabilities {
+			number, err := ParseCapability(name)
+			if err != nil {
+				return err
+			}
+			// 只能授予当前进程拥有的capabilities
+			if !hasCapability("CapPrm", number) {
+				return fmt.Errorf("clash-center 没有 %s，无法授予内核", strings.ToUpper(name))
+			}
+			attr.AmbientCaps = append(attr.AmbientCaps, uintptr(number))
+		}
+
+		if err := checkHomeWritable(cred); err != nil {
+			return err
+		}
+		// 用户不变时没有权限修改附加组
+		cred.NoSetGroups = !switchUser
+		attr.Credential = cred
+	}
+
+	if CoreNoFile != 0 {
+		var current syscall.Rlimit
+		if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &current); err == nil &&
+			uint64(current.Max) < CoreNoFile && !hasCapability("CapEff", capSysResource) {
+			return fmt.Errorf("打开文件数限制 %d 超过当前的上限 %d，需要root权限或 CAP_SYS_RESOURCE", CoreNoFile, current.Max)
+		}
+		// 修改其他用户的进程的限制同样需要权限
+		if switchUser && !hasCapability("CapEff", capSysResource) {
+			return fmt.Errorf("为用户 %s 运行的内核设置打开文件数限制需要root权限或 CAP_SYS_RESOURCE", CoreUser)
+		}
+	}
+
+	cmd.SysProcAttr = attr
+	return nil
+}
+
+// 查找用户和用户组，用户和用户组都可以直接使用数字ID
+func lookupCredential(userName, groupName string) (*syscall.Credential, error) {
+	cred := &syscall.Credential{}
+
+	u, err := user.Lookup(userName)
+	if err != nil {
+		u, err = user.LookupId(userName)
+	}
+	if err == nil {
+		uid, _ := strconv.ParseUint(u.Uid, 10, 32)
+		gid, _ := strconv.ParseUint(u.Gid, 10, 32)
+		cred.Uid, cred.Gid = uint32(uid), uint32(gid)
+		// 附加组只保留该用户所属的组，不继承clash-center的组
+		if ids, err := u.GroupIds(); err == nil {
+			for _, id := range ids {
+				if n, err := strconv.ParseUint(id, 10, 32); err == nil {
+					cred.Groups = append(cred.Groups, uint32(n))
+				}
+			}
+		}
+	} else if uid, parseErr := strconv.ParseUint(userName, 10, 32); parseErr == nil {
+		cred.Uid, cred.Gid = uint32(uid), uint32(uid)
+	} else {
+		return nil, fmt.Errorf("找不到用户 %s: %v", userName, err)
+	}
+
+	if groupName != "" {
+		g, err := user.LookupGroup(groupName)
+		if err != nil {
+			g, err = user.LookupGroupId(groupName)
+		}
+		if err == nil {
+			gid, _ := strconv.ParseUint(g.Gid, 10, 32)
+			cred.Gid = uint32(gid)
+		} else if gid, parseErr := strconv.ParseUint(groupName, 10, 32); parseErr == nil {
+			cred.Gid = uint32(gid)
+		} else {
+			return nil, fmt.Errorf("找不到用户组 %s: %v", groupName, err)
+		}
+	}
+	return cred, nil
+}
+
+// 内核在Clash主目录中保存缓存和下载的数据库，需要对运行内核的用户可写
+func checkHomeWritable(cred *syscall.Credential) error {
+	if cred.Uid == 0 {
+		return nil
+	}
+	info, err := os.Stat(ClashHome)
+	if err != nil {
+		return nil
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+
+	mode := info.Mode().Perm()
+	inGroup := stat.Gid == cred.Gid
+	for _, gid := range cred.Groups {
+		inGroup = inGroup || stat.Gid == gid
+	}
+	if (stat.Uid == cred.Uid && mode&0200 != 0) || (inGroup && mode&0020 != 0) || mode&0002 != 0 {
+		return nil
+	}
+	return fmt.Errorf("Clash主目录 %s 对用户 %s 不可写，请执行 chown -R %d:%d %s", ClashHome, CoreUser, cred.Uid, cred.Gid, ClashHome)
+}
+
+// 检查当前进程是否拥有指定的capability，field为 /proc/self/status 中的 CapEff 或 CapPrm
+func hasCapability(field string, number int) bool {
+	file, err := os.Open("/proc/self/status")
+	if err != nil {
+		return os.Geteuid() == 0
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		value, ok := strings.CutPrefix(scanner.Text(), field+":")
+		if !ok {
+			continue
+		}
+		mask, err := strconv.ParseUint(strings.TrimSpace(value), 16, 64)
+		return err == nil && mask&(1<<number) != 0
+	}
+	return false
+}
+
+// prlimit64使用的结构，与平台无关
+type rlimit64 struct {
+	Cur uint64
+	Max uint64
+}
+
+// 内核启动后设置打开文件数限制，内核此时尚未建立连接
+func afterStart(pid int) error {
+	if CoreNoFile == 0 {
+		return nil
+	}
+	limit := rlimit64{Cur: CoreNoFile, Max: CoreNoFile}
+	_, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64, uintptr(pid), syscall.RLIMIT_NOFILE, uintptr(unsafe.Pointer(&limit)), 0, 0, 0)
+	if errno != 0 {
+		return fmt.Errorf("设置内核的打开文件数限制为 %d 失败: %v", CoreNoFile, errno)
+	}
+	return nil
+}
+
+// 终止内核所在的进程组，内核启动的子进程一起终止
+func killProcess(proc *os.Process) error {
+	if pgid, err := syscall.Getpgid(proc.Pid); err == nil && pgid == proc.Pid {
+		return syscall.Kill(-pgid, syscall.SIGKILL)
+	}
+	return proc.Kill()
+}
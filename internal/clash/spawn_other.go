@@ -0,0 +1,25 @@
+//go:build !linux
+
+package clash
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// 其他系统不支持切换用户和设置打开文件数限制，设置时返回错误
+func configureCommand(cmd *exec.Cmd) error {
+	if CoreUser != "" || CoreNoFile != 0 {
+		return errors.New("以其他用户运行内核和打开文件数限制只支持Linux")
+	}
+	return nil
+}
+
+func afterStart(pid int) error {
+	return nil
+}
+
+func killProcess(proc *os.Process) error {
+	return proc.Kill()
+}
@@ -0,0 +1,227 @@
+// Package cli 提供命令行子命令，优先通过API操作运行中的服务器，服务器未运行时可以读取配置目录，修改配置需要指定 --local
+package cli
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"clash-center/internal/certs"
+	"clash-center/internal/config"
+	"clash-center/internal/models"
+	"clash-center/internal/settings"
+	"clash-center/pkg/converter"
+
+	"github.com/spf13/pflag"
+)
+
+// 子命令的参数
+type options struct {
+	all    bool              // update: 更新所有订阅
+	follow bool              // logs: 持续输出
+	lines  int               // logs: 输出的行数
+	level  string            // logs -f: 日志级别
+	output string            // convert: 输出文件
+	format string            // convert: 输出格式
+	filter models.NodeFilter // convert: 节点过滤规则
+}
+
+// 执行子命令的上下文
+type session struct {
+	client *client // 为nil时直接操作配置目录
+	opts   options
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// 子命令
+type command struct {
+	name    string
+	usage   string
+	summary string
+	// 需要服务器运行
+	remoteOnly bool
+	// 不需要服务器
+	offline bool
+	// 修改配置目录，服务器无法连接时需要 --local 才能直接修改
+	mutating bool
+	setup    func(flags *pflag.FlagSet, opts *options)
+	run      func(s *session, args []string) error
+}
+
+var commands = []command{
+	{name: "configs", usage: "configs list", summary: "列出配置文件", run: runConfigs},
+	{name: "switch", usage: "switch <name>", summary: "切换配置文件", mutating: true, run: runSwitch},
+	{name: "update", usage: "update <name>|--all", summary: "从订阅源更新配置", mutating: true, run: runUpdate,
+		setup: func(flags *pflag.FlagSet, opts *options) {
+			flags.BoolVar(&opts.all, "all", false, "更新所有订阅和聚合配置")
+		}},
+	{name: "start", usage: "start", summary: "启动内核", remoteOnly: true, run: runCoreAction("start")},
+	{name: "stop", usage: "stop", summary: "停止内核", remoteOnly: true, run: runCoreAction("stop")},
+	{name: "restart", usage: "restart", summary: "重启内核", remoteOnly: true, run: runCoreAction("restart")},
+	{name: "status", usage: "status", summary: "查看运行状态", run: runStatus},
+	{name: "logs", usage: "logs [-f] [-n lines]", summary: "查看内核输出", remoteOnly: true, run: runLogs,
+		setup: func(flags *pflag.FlagSet, opts *options) {
+			flags.BoolVarP(&opts.follow, "follow", "f", false, "持续输出内核日志")
+			flags.IntVarP(&opts.lines, "lines", "n", 100, "输出最近的行数，0表示全部")
+			flags.StringVar(&opts.level, "level", "info", "持续输出时的日志级别：debug、info、warning、error")
+		}},
+	{name: "convert", usage: "convert [url|file|-]", summary: "将订阅转换为Clash、Base64或sing-box格式", offline: true, run: runConvert,
+		setup: func(flags *pflag.FlagSet, opts *options) {
+			flags.StringVarP(&opts.output, "output", "o", "", "输出到文件，默认输出到标准输出")
+			flags.StringVar(&opts.format, "format", converter.FormatClash, "输出格式：clash、base64、singbox")
+			flags.StringVar(&opts.filter.Include, "include", "", "只保留名称匹配该正则的节点")
+			flags.StringVar(&opts.filter.Exclude, "exclude", "", "剔除名称匹配该正则的节点")
+			flags.StringVar(&opts.filter.Prefix, "prefix", "", "节点名称前缀")
+			flags.BoolVar(&opts.filter.EmojiFlag, "emoji-flag", false, "根据地区添加国旗")
+		}},
+}
+
+// IsCommand 判断参数是否为子命令
+func IsCommand(name string) bool {
+	if name == "help" {
+		return true
+	}
+	_, ok := findCommand(name)
+	return ok
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// Run 执行子命令，args[0]为子命令名称，返回进程退出码
+func Run(args []string) int {
+	cmd, ok := findCommand(args[0])
+	if !ok {
+		printUsage(os.Stdout)
+		if args[0] == "help" {
+			return 0
+		}
+		return 2
+	}
+
+	var opts options
+	flags := pflag.NewFlagSet("clash-center "+cmd.name, pflag.ContinueOnError)
+	flags.SetOutput(os.Stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法: clash-center %s [参数]\n\n%s\n\n", cmd.usage, cmd.summary)
+		flags.PrintDefaults()
+	}
+	settingsPath := flags.StringP("settings", "s", os.Getenv("CLASH_CENTER_SETTINGS"), "服务器的设置文件，用于确定默认的服务器地址和目录")
+	server := flags.String("server", os.Getenv("CLASH_CENTER_SERVER"), "服务器地址，Unix套接字使用 unix:///路径，默认根据设置文件确定，也可以通过 CLASH_CENTER_SERVER 设置")
+	token := flags.String("token", os.Getenv("CLASH_CENTER_TOKEN"), "API访问令牌，也可以通过 CLASH_CENTER_TOKEN 设置")
+	user := flags.String("user", os.Getenv("CLASH_CENTER_USER"), "HTTP Basic认证，格式为 用户名:密码")
+	local := flags.Bool("local", false, "不连接服务器，直接操作配置目录")
+	insecure := flags.Bool("insecure", false, "不验证服务器的HTTPS证书")
+	dataDir := flags.StringP("data-dir", "d", "", "服务器的数据目录，默认根据设置文件确定")
+	configDir := flags.StringP("config-dir", "c", "", "服务器未运行时使用的配置文件目录，默认根据设置文件确定")
+	clashHome := flags.StringP("clash-home", "h", "", "服务器未运行时使用的Clash主目录，默认根据设置文件确定")
+	if cmd.setup != nil {
+		cmd.setup(flags, &opts)
+	}
+
+	if err := flags.Parse(args[1:]); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		flags.Usage()
+		return 2
+	}
+
+	// 与服务器使用相同的设置，命令行参数优先
+	st, err := settings.Load(*settingsPath, *dataDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return 1
+	}
+	if *dataDir != "" {
+		st.DataDir = *dataDir
+	}
+	// 命令行中的相对路径以当前目录为准
+	if *configDir != "" {
+		st.Paths.ConfigDir, _ = filepath.Abs(*configDir)
+	}
+	if *clashHome != "" {
+		st.Paths.ClashHome, _ = filepath.Abs(*clashHome)
+	}
+	if err := st.ResolvePaths(); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return 1
+	}
+	st.ApplyPaths()
+	// 本机服务器使用自签名证书时信任该证书
+	var tlsConfig *tls.Config
+	switch {
+	case *server != "":
+	case !st.Listen.TCPEnabled():
+		*server = "unix://" + st.Listen.Socket
+	default:
+		*server = "http://" + st.Listen.LocalAddress()
+		if st.TLS.Enabled() {
+			*server = "https://" + st.Listen.LocalAddress()
+			if pool, err := certs.CertPool(st.TLS.Cert); err == nil {
+				tlsConfig = &tls.Config{RootCAs: pool}
+			}
+		}
+	}
+	if *insecure {
+		tlsConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	s := &session{opts: opts, stdout: os.Stdout, stderr: os.Stderr}
+	var probeErr error
+	if !cmd.offline && !*local {
+		c := newClient(*server, *token, *user, tlsConfig)
+		reachable, err := c.reachable()
+		if reachable && err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			return 1
+		}
+		if reachable {
+			s.client = c
+		}
+		probeErr = err
+	}
+
+	if s.client == nil && cmd.remoteOnly {
+		fmt.Fprintf(os.Stderr, "错误: 无法连接服务器 %s（%v），%s 命令需要服务器运行\n", *server, probeErr, cmd.name)
+		return 1
+	}
+	// 连接超时或地址错误时服务器可能仍在运行，直接修改文件会与服务器冲突
+	if s.client == nil && cmd.mutating && !*local {
+		fmt.Fprintf(os.Stderr, "错误: 无法连接服务器 %s（%v）\n服务器未运行时可以使用 --local 直接修改配置目录 %s\n", *server, probeErr, config.ConfigDir)
+		return 1
+	}
+	if s.client == nil && !cmd.offline {
+		fmt.Fprintf(os.Stderr, "服务器未运行，直接操作配置目录 %s\n", config.ConfigDir)
+	}
+
+	if err := cmd.run(s, flags.Args()); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// 输出子命令列表
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "用法: clash-center [参数]            启动服务器")
+	fmt.Fprintln(w, "      clash-center <命令> [参数]     管理配置和内核")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "命令:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-24s %s\n", cmd.usage, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "使用 clash-center <命令> --help 查看命令的参数")
+}
@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"clash-center/internal/config"
+)
+
+// 准备只包含 x.yaml 的数据目录，返回一个没有服务器监听的地址
+func prepareOffline(t *testing.T) (string, string) {
+	t.Helper()
+	dataDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dataDir, "configs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dataDir, "configs", "x.yaml"), []byte("mixed-port: 7890\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	saved := []string{config.ConfigDir, config.AppConfigPath, config.MergedConfigPath, config.DefaultConfigPath, config.RuleSetDir}
+	t.Cleanup(func() {
+		config.ConfigDir, config.AppConfigPath, config.MergedConfigPath, config.DefaultConfigPath, config.RuleSetDir = saved[0], saved[1], saved[2], saved[3], saved[4]
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.Close()
+	return dataDir, "http://" + ln.Addr().String()
+}
+
+func TestSwitchRequiresLocalWhenServerUnreachable(t *testing.T) {
+	dataDir, server := prepareOffline(t)
+
+	if code := Run([]string{"switch", "x", "-d", dataDir, "--server", server}); code == 0 {
+		t.Fatal("无法连接服务器时没有 --local 也修改了配置")
+	}
+	if last := config.LoadAppConfig().LastConfig; last != "" {
+		t.Fatalf("没有 --local 时保存了上次配置 %s", last)
+	}
+
+	if code := Run([]string{"switch", "x", "-d", dataDir, "--server", server, "--local"}); code != 0 {
+		t.Fatalf("使用 --local 时返回 %d", code)
+	}
+	if last := config.LoadAppConfig().LastConfig; last != "x.yaml" {
+		t.Fatalf("使用 --local 后上次配置为 %q", last)
+	}
+}
+
+func TestReadOnlyCommandFallsBackOffline(t *testing.T) {
+	dataDir, server := prepareOffline(t)
+
+	if code := Run([]string{"configs", "list", "-d", dataDir, "--server", server}); code != 0 {
+		t.Fatalf("服务器无法连接时列出配置返回 %d", code)
+	}
+}
@@ -0,0 +1,162 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"clash-center/internal/models"
+)
+
+// 访问运行中的clash-center的客户端
+type client struct {
+	baseURL    string
+	token      string
+	user       string // 用户名:密码，使用HTTP Basic认证
+	transport  http.RoundTripper
+	httpClient *http.Client
+}
+
+// 服务器返回的错误
+type apiError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *apiError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("%s (%s)", e.Message, e.Code)
+	}
+	return e.Message
+}
+
+// tlsConfig为nil时使用系统证书验证HTTPS服务器，server为 unix:///路径 时通过Unix套接字访问
+func newClient(server, token, user string, tlsConfig *tls.Config) *client {
+	socket, isSocket := strings.CutPrefix(server, "unix://")
+	if isSocket {
+		server = "http://localhost"
+	} else if !strings.Contains(server, "://") {
+		server = "http://" + server
+	}
+
+	transport := http.DefaultTransport
+	if tlsConfig != nil || isSocket {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.TLSClientConfig = tlsConfig
+		if isSocket {
+			t.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socket)
+			}
+		}
+		transport = t
+	}
+	return &client{
+		baseURL:    strings.TrimRight(server, "/"),
+		token:      token,
+		user:       user,
+		transport:  transport,
+		httpClient: &http.Client{Transport: transport, Timeout: 5 * time.Minute},
+	}
+}
+
+// 构建带认证信息的请求
+func (c *client) newRequest(method, path string, body any) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		content, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("编码请求失败: %v", err)
+		}
+		reader = bytes.NewReader(content)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	} else if name, password, ok := strings.Cut(c.user, ":"); ok {
+		req.SetBasicAuth(name, password)
+	}
+	return req, nil
+}
+
+// 调用v2接口，将响应中的data解码到out
+func (c *client) do(method, path string, body, out any) error {
+	req, err := c.newRequest(method, path, body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	var response struct {
+		models.APIResponse
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return fmt.Errorf("解析响应失败 (HTTP %d): %v", resp.StatusCode, err)
+	}
+	if !response.Success || resp.StatusCode >= http.StatusBadRequest {
+		return &apiError{Status: resp.StatusCode, Code: response.Code, Message: response.Error}
+	}
+
+	if out != nil && len(response.Data) > 0 {
+		if err := json.Unmarshal(response.Data, out); err != nil {
+			return fmt.Errorf("解析响应失败: %v", err)
+		}
+	}
+	return nil
+}
+
+// 配置文件资源的路径
+func configPath(name string, action string) string {
+	return "/api/v2/configs/" + url.PathEscape(name) + action
+}
+
+// 检查服务器是否可以访问，连接失败时返回false，认证失败时返回错误
+func (c *client) reachable() (bool, error) {
+	req, err := c.newRequest(http.MethodGet, "/api/v2/core", nil)
+	if err != nil {
+		return false, err
+	}
+
+	probe := &http.Client{Transport: c.transport, Timeout: 3 * time.Second}
+	resp, err := probe.Do(req)
+	var certErr *tls.CertificateVerificationError
+	if errors.As(err, &certErr) {
+		return true, fmt.Errorf("无法验证服务器证书，可以使用 --insecure 跳过验证: %v", certErr.Err)
+	}
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return true, errors.New("认证失败，请通过 --token 或 --user 提供访问凭据")
+	}
+	return true, nil
+}
@@ -0,0 +1,383 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"clash-center/internal/clash"
+	"clash-center/internal/config"
+	"clash-center/internal/converter"
+	"clash-center/internal/models"
+	conv "clash-center/pkg/converter"
+)
+
+// 列出配置文件
+func runConfigs(s *session, args []string) error {
+	if len(args) > 0 && args[0] != "list" {
+		return fmt.Errorf("未知的命令: configs %s", args[0])
+	}
+
+	list, err := s.listConfigs()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(s.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\t文件\t名称\t类型\t订阅")
+	for _, item := range list.Items {
+		marker := ""
+		if item.Current {
+			marker = "*"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", marker, item.Name, item.DisplayName, configKind(item), item.Source)
+	}
+	return w.Flush()
+}
+
+// 获取配置文件列表，服务器未运行时读取配置目录，当前配置为上次使用的配置
+func (s *session) listConfigs() (models.ConfigList, error) {
+	var list models.ConfigList
+	if s.client != nil {
+		err := s.client.do(http.MethodGet, "/api/v2/configs", nil, &list)
+		return list, err
+	}
+
+	files, err := config.GetConfigFiles()
+	if err != nil {
+		return list, fmt.Errorf("获取配置文件失败: %v", err)
+	}
+	list.Current = config.LoadAppConfig().LastConfig
+	for _, file := range files {
+		list.Items = append(list.Items, models.ConfigSummary{
+			Name:        file.Path,
+			DisplayName: file.DisplayName,
+			Source:      file.ConfigSrc,
+			Type:        file.ConfigType,
+			Current:     file.Path == list.Current,
+		})
+	}
+	return list, nil
+}
+
+// 配置文件的类型说明
+func configKind(item models.ConfigSummary) string {
+	switch {
+	case item.Type == "aggregate":
+		return "聚合"
+	case item.Source != "":
+		return "订阅"
+	}
+	return "本地"
+}
+
+// 切换配置文件，服务器未运行时设为下次启动使用的配置
+func runSwitch(s *session, args []string) error {
+	if len(args) != 1 {
+		return errors.New("用法: clash-center switch <name>")
+	}
+
+	if s.client != nil {
+		var result models.ConfigChangeResult
+		if err := s.client.do(http.MethodPost, configPath(args[0], ":activate"), nil, &result); err != nil {
+			return err
+		}
+		fmt.Fprintf(s.stdout, "已切换到 %s 并重启内核\n", result.Config.Name)
+		return nil
+	}
+
+	fileName, ok := config.ResolveConfigFile(args[0])
+	if !ok {
+		return fmt.Errorf("配置文件不存在: %s", args[0])
+	}
+	config.UpdateLastConfig(fileName)
+	fmt.Fprintf(s.stdout, "已将 %s 设为当前配置，服务器启动时生效\n", fileName)
+	return nil
+}
+
+// 从订阅源更新配置
+func runUpdate(s *session, args []string) error {
+	var names []string
+	switch {
+	case s.opts.all && len(args) == 0:
+		list, err := s.listConfigs()
+		if err != nil {
+			return err
+		}
+		for _, item := range list.Items {
+			if item.Source != "" || item.Type == "aggregate" {
+				names = append(names, item.Name)
+			}
+		}
+		if len(names) == 0 {
+			fmt.Fprintln(s.stdout, "没有可以更新的订阅")
+			return nil
+		}
+	case !s.opts.all && len(args) == 1:
+		names = args
+	default:
+		return errors.New("用法: clash-center update <name>|--all")
+	}
+
+	failed := 0
+	needRestart := false
+	for _, name := range names {
+		restart, err := s.updateConfig(name)
+		if err != nil {
+			failed++
+			fmt.Fprintf(s.stderr, "更新 %s 失败: %v\n", name, err)
+			continue
+		}
+		needRestart = needRestart || restart
+		fmt.Fprintf(s.stdout, "已更新 %s\n", name)
+	}
+
+	if needRestart {
+		fmt.Fprintln(s.stdout, "当前使用的配置已更新，执行 clash-center restart 后生效")
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d 个配置更新失败", failed)
+	}
+	return nil
+}
+
+// 更新单个配置，返回是否需要重启内核
+func (s *session) updateConfig(name string) (bool, error) {
+	if s.client != nil {
+		var result models.ConfigChangeResult
+		err := s.client.do(http.MethodPost, configPath(name, ":refresh"), nil, &result)
+		return result.NeedRestart, err
+	}
+
+	fileName, ok := config.ResolveConfigFile(name)
+	if !ok {
+		return false, fmt.Errorf("配置文件不存在: %s", name)
+	}
+	yamlConfig, err := config.GetConfigInfo(fileName)
+	if err != nil {
+		return false, err
+	}
+	configName, _ := yamlConfig["config_name"].(string)
+
+	if converter.IsAggregateConfig(yamlConfig) {
+		return false, converter.SaveAggregateConfig(fileName, configName, config.GetAggregateSources(yamlConfig))
+	}
+
+	configSrc, _ := yamlConfig["config_src"].(string)
+	if configSrc == "" {
+		return false, errors.New("该配置文件没有订阅URL源")
+	}
+	err = converter.FetchAndSaveConfig(configSrc, fileName, configName, config.GetNodeFilter(yamlConfig), config.GetFetchOptions(yamlConfig))
+	if err != nil {
+		return false, err
+	}
+
+	// 重新生成引用此配置的聚合配置
+	converter.RefreshDependentAggregates(fileName)
+	return false, nil
+}
+
+// 启动、停止或重启内核
+func runCoreAction(action string) func(s *session, args []string) error {
+	return func(s *session, args []string) error {
+		var status models.CoreStatus
+		if err := s.client.do(http.MethodPost, "/api/v2/core:"+action, nil, &status); err != nil {
+			return err
+		}
+		printCoreStatus(s.stdout, status)
+		return nil
+	}
+}
+
+// 查看运行状态
+func runStatus(s *session, args []string) error {
+	if s.client != nil {
+		var status models.CoreStatus
+		if err := s.client.do(http.MethodGet, "/api/v2/core", nil, &status); err != nil {
+			return err
+		}
+		printCoreStatus(s.stdout, status)
+		return nil
+	}
+
+	appConfig := config.LoadAppConfig()
+	fmt.Fprintln(s.stdout, "服务器: 未运行")
+	fmt.Fprintf(s.stdout, "配置: %s\n", valueOrNone(appConfig.LastConfig))
+	fmt.Fprintf(s.stdout, "自动启动: %s\n", yesNo(appConfig.AutoStart))
+	if info, err := clash.InstalledBinary(); err == nil {
+		fmt.Fprintf(s.stdout, "内核版本: %s\n", info.Version)
+	} else {
+		fmt.Fprintf(s.stdout, "内核: %v\n", err)
+	}
+	return nil
+}
+
+func printCoreStatus(w io.Writer, status models.CoreStatus) {
+	if status.Running {
+		uptime := time.Duration(status.Uptime) * time.Second
+		adopted := ""
+		if status.Adopted {
+			adopted = "，启动时接管"
+		}
+		fmt.Fprintf(w, "内核: 运行中 (PID %d，已运行 %s%s)\n", status.PID, uptime, adopted)
+	} else {
+		fmt.Fprintln(w, "内核: 未运行")
+	}
+	if status.Conflict != "" {
+		fmt.Fprintf(w, "冲突: %s\n", status.Conflict)
+	}
+	fmt.Fprintf(w, "配置: %s\n", valueOrNone(status.Config))
+	fmt.Fprintf(w, "内核版本: %s\n", valueOrNone(status.Version))
+}
+
+// 查看内核输出，-f 时持续输出控制器日志
+func runLogs(s *session, args []string) error {
+	var logs models.CoreLogs
+	path := "/api/v2/core/logs?lines=" + strconv.Itoa(s.opts.lines)
+	if err := s.client.do(http.MethodGet, path, nil, &logs); err != nil {
+		return err
+	}
+	for _, line := range logs.Lines {
+		fmt.Fprintln(s.stdout, line)
+	}
+
+	if !s.opts.follow {
+		return nil
+	}
+	return s.followLogs()
+}
+
+// 通过控制器代理持续读取内核日志，直到连接断开
+func (s *session) followLogs() error {
+	req, err := s.client.newRequest(http.MethodGet, "/api/core/logs?level="+url.QueryEscape(s.opts.level), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := (&http.Client{Transport: s.client.transport}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("读取日志失败: HTTP %d", resp.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var entry struct {
+			Type    string `json:"type"`
+			Payload string `json:"payload"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		fmt.Fprintf(s.stdout, "%s [%s] %s\n", time.Now().Format("15:04:05"), entry.Type, entry.Payload)
+	}
+	return scanner.Err()
+}
+
+// 将订阅链接、文件或标准输入转换为指定格式，转换摘要输出到标准错误
+func runConvert(s *session, args []string) error {
+	if len(args) > 1 {
+		return errors.New("用法: clash-center convert [url|file|-]")
+	}
+
+	input := "-"
+	if len(args) == 1 {
+		input = args[0]
+	}
+	content, err := readConvertInput(input)
+	if err != nil {
+		return err
+	}
+
+	result, err := conv.Convert(content, conv.Options{Format: s.opts.format, Filter: s.opts.filter})
+	if err != nil {
+		return err
+	}
+
+	if s.opts.output != "" {
+		if err := os.WriteFile(s.opts.output, result.Content, 0644); err != nil {
+			return fmt.Errorf("写入文件失败: %v", err)
+		}
+	} else {
+		// Base64输出没有结尾的换行，避免与终端提示符连在一起
+		content := result.Content
+		if !bytes.HasSuffix(content, []byte("\n")) {
+			content = append(content, '\n')
+		}
+		if _, err := s.stdout.Write(content); err != nil {
+			return err
+		}
+	}
+
+	printConvertSummary(s.stderr, result)
+	return nil
+}
+
+// 读取待转换的内容，"-" 表示标准输入
+func readConvertInput(input string) ([]byte, error) {
+	switch {
+	case input == "-":
+		content, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("读取标准输入失败: %v", err)
+		}
+		return content, nil
+	case strings.HasPrefix(input, "http://") || strings.HasPrefix(input, "https://"):
+		result, err := converter.FetchURL(input, models.FetchOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return result.Body, nil
+	}
+
+	content, err := os.ReadFile(input)
+	if err != nil {
+		return nil, fmt.Errorf("读取文件失败: %v", err)
+	}
+	return content, nil
+}
+
+func printConvertSummary(w io.Writer, result conv.Result) {
+	inputFormat := result.Input.Format
+	if result.Input.Base64 {
+		inputFormat = "base64 " + inputFormat
+	}
+	fmt.Fprintf(w, "输入格式: %s\n", inputFormat)
+	fmt.Fprintf(w, "节点: %d，代理组: %d\n", result.Nodes, result.Groups)
+	if result.Filtered > 0 {
+		fmt.Fprintf(w, "被过滤的节点: %d\n", result.Filtered)
+	}
+	if result.Input.InvalidLinks > 0 {
+		fmt.Fprintf(w, "无法识别的链接: %d\n", result.Input.InvalidLinks)
+	}
+	if len(result.Skipped) > 0 {
+		fmt.Fprintf(w, "无法转换为该格式的节点: %s\n", strings.Join(result.Skipped, ", "))
+	}
+}
+
+func valueOrNone(value string) string {
+	if value == "" {
+		return "无"
+	}
+	return value
+}
+
+func yesNo(value bool) string {
+	if value {
+		return "是"
+	}
+	return "否"
+}
@@ -1,13 +1,17 @@
 package config
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log"
 	"maps"
+	"net"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"clash-center/internal/models"
 
@@ -23,8 +27,15 @@ var (
 	DefaultConfigPath = "./default.yaml"
 	// 应用程序配置文件路径
 	AppConfigPath = "./app_config.json"
+	// 共享规则集目录
+	RuleSetDir = "./rulesets"
+	// 内核访问clash-center的地址，用于生成proxy-providers和rule-providers
+	ServerURL = "http://127.0.0.1:7788"
 	// 当前使用的原始配置文件路径（用于显示）
 	OriginalConfigName string
+
+	// 保护应用程序配置的读取-修改-写入过程
+	appConfigMu sync.Mutex
 )
 
 // 加载应用程序配置
@@ -77,11 +88,92 @@ func SaveAppConfig(config models.AppConfig) error {
 	return nil
 }
 
+// 修改并保存应用程序配置，多处同时修改时不会互相覆盖
+func UpdateAppConfig(update func(config *models.AppConfig)) error {
+	appConfigMu.Lock()
+	defer appConfigMu.Unlock()
+
+	config := LoadAppConfig()
+	update(&config)
+	return SaveAppConfig(config)
+}
+
 // 更新上次使用的配置文件
 func UpdateLastConfig(configPath string) {
-	config := LoadAppConfig()
-	config.LastConfig = configPath
-	SaveAppConfig(config)
+	UpdateAppConfig(func(config *models.AppConfig) {
+		config.LastConfig = configPath
+	})
+}
+
+// 获取配置文件中记住的代理组选择，代理组名称 -> 节点名称
+func GetSelections(configName string) map[string]string {
+	return LoadAppConfig().Selections[configName]
+}
+
+// 保存配置文件的代理组选择，selections为空时删除记录
+func SaveSelections(configName string, selections map[string]string) error {
+	return UpdateAppConfig(func(config *models.AppConfig) {
+		if len(selections) == 0 {
+			delete(config.Selections, configName)
+			return
+		}
+		if config.Selections == nil {
+			config.Selections = make(map[string]map[string]string)
+		}
+		config.Selections[configName] = selections
+	})
+}
+
+// 获取本地订阅接口的访问令牌，不存在时自动生成
+func GetSubToken() (string, error) {
+	if token := LoadAppConfig().SubToken; token != "" {
+		return token, nil
+	}
+
+	candidate, err := newSubToken()
+	if err != nil {
+		return "", err
+	}
+
+	// 在同一次加锁的读写中检查并生成，避免并发请求各自生成不同的令牌
+	var token string
+	err = UpdateAppConfig(func(config *models.AppConfig) {
+		if config.SubToken == "" {
+			config.SubToken = candidate
+		}
+		token = config.SubToken
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// 重新生成本地订阅接口的访问令牌
+func ResetSubToken() (string, error) {
+	token, err := newSubToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = UpdateAppConfig(func(config *models.AppConfig) {
+		config.SubToken = token
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// 生成随机的订阅令牌
+func newSubToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("生成订阅令牌失败: %v", err)
+	}
+	return hex.EncodeToString(buf), nil
 }
 
 // 获取配置文件列表
@@ -97,6 +189,7 @@ func GetConfigFiles() ([]models.ConfigFile, error) {
 			filePath := file.Name()
 			displayName := strings.TrimSuffix(file.Name(), filepath.Ext(file.Name()))
 			configSrc := ""
+			configType := ""
 
 			// 尝试从YAML文件中读取config_name和config_src字段
 			yamlFile, err := os.Open(filepath.Join(ConfigDir, filePath))
@@ -115,6 +208,11 @@ func GetConfigFiles() ([]models.ConfigFile, error) {
 					if src, ok := yamlConfig["config_src"].(string); ok {
 						configSrc = src
 					}
+
+					// 获取config_type字段的值
+					if t, ok := yamlConfig["config_type"].(string); ok {
+						configType = t
+					}
 				}
 			}
 
@@ -122,6 +220,7 @@ func GetConfigFiles() ([]models.ConfigFile, error) {
 				Path:        filePath,
 				DisplayName: displayName,
 				ConfigSrc:   configSrc,
+				ConfigType:  configType,
 			})
 		}
 	}
@@ -129,36 +228,83 @@ func GetConfigFiles() ([]models.ConfigFile, error) {
 	return configs, nil
 }
 
+// ResolveConfigFile 根据配置名称查找配置目录中的配置文件，允许省略扩展名
+func ResolveConfigFile(name string) (string, bool) {
+	// 只获取文件名部分，避免任何路径遍历攻击
+	fileName := filepath.Base(name)
+	if fileName == "." || fileName == "/" {
+		return "", false
+	}
+
+	candidates := []string{fileName}
+	if ext := filepath.Ext(fileName); ext != ".yaml" && ext != ".yml" {
+		candidates = []string{fileName + ".yaml", fileName + ".yml"}
+	}
+
+	for _, candidate := range candidates {
+		if _, err := os.Stat(filepath.Join(ConfigDir, candidate)); err == nil {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
 // 合并配置文件
 func MergeConfig(targetConfigPath string) error {
+	finalConfig, err := BuildRuntimeConfig(targetConfigPath)
+	if err != nil {
+		return err
+	}
+
+	// 写入合并后的配置到文件
+	mergedFile, err := os.Create(MergedConfigPath)
+	if err != nil {
+		return fmt.Errorf("创建配置文件失败: %v", err)
+	}
+	defer mergedFile.Close()
+
+	encoder := yaml.NewEncoder(mergedFile)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(finalConfig); err != nil {
+		return fmt.Errorf("写入配置失败: %v", err)
+	}
+
+	log.Printf("配置已成功合并并写入到: %s", MergedConfigPath)
+	return nil
+}
+
+// BuildMergedConfig 读取目标配置，并根据需要用默认配置覆盖
+func BuildMergedConfig(targetConfigPath string, applyDefault bool) (map[string]any, error) {
 	// 读取默认配置文件
 	defaultConfig := make(map[string]any)
 	defaultExists := false
 
-	defaultFile, err := os.Open(DefaultConfigPath)
-	if err == nil {
-		defer defaultFile.Close()
-		defaultExists = true
+	if applyDefault {
+		defaultFile, err := os.Open(DefaultConfigPath)
+		if err == nil {
+			defer defaultFile.Close()
+			defaultExists = true
 
-		decoder := yaml.NewDecoder(defaultFile)
-		if err := decoder.Decode(&defaultConfig); err != nil {
-			return fmt.Errorf("解析默认配置文件失败: %v", err)
+			decoder := yaml.NewDecoder(defaultFile)
+			if err := decoder.Decode(&defaultConfig); err != nil {
+				return nil, fmt.Errorf("解析默认配置文件失败: %v", err)
+			}
+		} else {
+			log.Printf("默认配置文件不存在，跳过合并: %v", err)
 		}
-	} else {
-		log.Printf("默认配置文件不存在，跳过合并: %v", err)
 	}
 
 	// 读取目标配置文件
 	targetConfig := make(map[string]any)
 	targetFile, err := os.Open(filepath.Join(ConfigDir, targetConfigPath))
 	if err != nil {
-		return fmt.Errorf("打开目标配置文件失败: %v", err)
+		return nil, fmt.Errorf("打开目标配置文件失败: %v", err)
 	}
 	defer targetFile.Close()
 
 	decoder := yaml.NewDecoder(targetFile)
 	if err := decoder.Decode(&targetConfig); err != nil {
-		return fmt.Errorf("解析目标配置文件失败: %v", err)
+		return nil, fmt.Errorf("解析目标配置文件失败: %v", err)
 	}
 
 	// 将目标配置中的设置合并到默认配置中
@@ -173,21 +319,50 @@ func MergeConfig(targetConfigPath string) error {
 		log.Printf("已将默认配置覆盖到目标配置")
 	}
 
-	// 写入合并后的配置到文件
-	mergedFile, err := os.Create(MergedConfigPath)
+	return finalConfig, nil
+}
+
+// BuildRuntimeConfig 生成交给内核使用的配置，启用provider模式时将节点改为由proxy-providers提供
+func BuildRuntimeConfig(targetConfigPath string) (map[string]any, error) {
+	finalConfig, err := BuildMergedConfig(targetConfigPath, true)
 	if err != nil {
-		return fmt.Errorf("创建配置文件失败: %v", err)
+		return nil, err
 	}
-	defer mergedFile.Close()
 
-	encoder := yaml.NewEncoder(mergedFile)
-	encoder.SetIndent(2)
-	if err := encoder.Encode(finalConfig); err != nil {
-		return fmt.Errorf("写入配置失败: %v", err)
+	if err := ApplyProviderMode(finalConfig, targetConfigPath); err != nil {
+		return nil, fmt.Errorf("生成proxy-providers失败: %v", err)
 	}
 
-	log.Printf("配置已成功合并并写入到: %s", MergedConfigPath)
-	return nil
+	return finalConfig, nil
+}
+
+// StripMetadata 删除配置中config_开头的元数据字段
+func StripMetadata(configData map[string]any) {
+	for key := range configData {
+		if strings.HasPrefix(key, "config_") {
+			delete(configData, key)
+		}
+	}
+}
+
+// 本机控制器相关的配置项，不应随订阅下发给其他设备
+var controllerKeys = []string{
+	"external-controller",
+	"external-controller-tls",
+	"external-controller-unix",
+	"external-controller-pipe",
+	"external-controller-cors",
+	"external-ui",
+	"external-ui-name",
+	"external-ui-url",
+	"secret",
+}
+
+// StripControllerSettings 删除配置中的控制器地址、密钥和面板设置
+func StripControllerSettings(configData map[string]any) {
+	for _, key := range controllerKeys {
+		delete(configData, key)
+	}
 }
 
 // 获取配置信息
@@ -211,14 +386,23 @@ func GetConfigInfo(configPath string) (map[string]any, error) {
 
 // 更新配置文件名称
 func UpdateConfigName(configPathName, configName string) error {
+	return UpdateConfigField(configPathName, "config_name", configName)
+}
+
+// UpdateConfigField 更新配置文件中的单个字段，value为nil时删除该字段
+func UpdateConfigField(configPathName, key string, value any) error {
 	// 读取原YAML文件
 	yamlConfig, err := GetConfigInfo(configPathName)
 	if err != nil {
 		return fmt.Errorf("读取配置文件失败: %v", err)
 	}
 
-	// 更新config_name字段
-	yamlConfig["config_name"] = configName
+	// 更新字段
+	if value == nil {
+		delete(yamlConfig, key)
+	} else {
+		yamlConfig[key] = value
+	}
 
 	// 重写YAML文件
 	file, err := os.Create(filepath.Join(ConfigDir, configPathName))
@@ -235,3 +419,121 @@ func UpdateConfigName(configPathName, configName string) error {
 
 	return nil
 }
+
+// DecodeConfigField 将配置中config_开头的元数据字段解码到结构体中
+func DecodeConfigField(configData map[string]any, key string, out any) error {
+	value, ok := configData[key]
+	if !ok || value == nil {
+		return nil
+	}
+
+	// 通过YAML重新编码完成map到结构体的转换
+	content, err := yaml.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("编码字段%s失败: %v", key, err)
+	}
+	if err := yaml.Unmarshal(content, out); err != nil {
+		return fmt.Errorf("解析字段%s失败: %v", key, err)
+	}
+
+	return nil
+}
+
+// GetNodeFilter 获取配置中保存的节点过滤规则
+func GetNodeFilter(configData map[string]any) models.NodeFilter {
+	var filter models.NodeFilter
+	if err := DecodeConfigField(configData, "config_filter", &filter); err != nil {
+		log.Printf("读取节点过滤规则失败: %v", err)
+	}
+	return filter
+}
+
+// GetAggregateSources 获取聚合配置中保存的来源列表
+func GetAggregateSources(configData map[string]any) []models.AggregateSource {
+	var sources []models.AggregateSource
+	if err := DecodeConfigField(configData, "config_sources", &sources); err != nil {
+		log.Printf("读取聚合配置来源失败: %v", err)
+	}
+	return sources
+}
+
+// GetProviderOptions 获取配置中保存的provider模式设置
+func GetProviderOptions(configData map[string]any) models.ProviderOptions {
+	var options models.ProviderOptions
+	if err := DecodeConfigField(configData, "config_provider", &options); err != nil {
+		log.Printf("读取provider设置失败: %v", err)
+	}
+	return options
+}
+
+// GetFetchOptions 获取配置中保存的订阅请求设置
+func GetFetchOptions(configData map[string]any) models.FetchOptions {
+	var options models.FetchOptions
+	if err := DecodeConfigField(configData, "config_fetch", &options); err != nil {
+		log.Printf("读取订阅请求设置失败: %v", err)
+	}
+	return options
+}
+
+// GetMixedPort 获取当前内核配置中的混合代理端口，未配置时返回0
+func GetMixedPort() int {
+	content, err := os.ReadFile(MergedConfigPath)
+	if err != nil {
+		return 0
+	}
+
+	var mergedConfig struct {
+		MixedPort int `yaml:"mixed-port"`
+		Port      int `yaml:"port"`
+	}
+	if err := yaml.Unmarshal(content, &mergedConfig); err != nil {
+		return 0
+	}
+
+	// 没有mixed-port时退回到HTTP代理端口
+	if mergedConfig.MixedPort != 0 {
+		return mergedConfig.MixedPort
+	}
+	return mergedConfig.Port
+}
+
+// ControllerInfo 内核控制器的连接信息
+type ControllerInfo struct {
+	Address string // 本机可访问的控制器地址，如 127.0.0.1:9090
+	Port    string
+	Secret  string
+}
+
+// GetControllerInfo 获取内核控制器的地址和密钥，优先读取合并后的配置
+func GetControllerInfo() ControllerInfo {
+	info := ControllerInfo{Address: "127.0.0.1:9090", Port: "9090"}
+
+	var configData map[string]any
+	if content, err := os.ReadFile(MergedConfigPath); err == nil {
+		yaml.Unmarshal(content, &configData)
+	}
+	if configData == nil && OriginalConfigName != "" {
+		configData, _ = GetConfigInfo(OriginalConfigName)
+	}
+	if configData == nil {
+		return info
+	}
+
+	// 解析external-controller，监听所有地址时通过本机地址访问
+	if controller, ok := configData["external-controller"].(string); ok && controller != "" {
+		host, port, err := net.SplitHostPort(controller)
+		if err == nil {
+			if host == "" || host == "0.0.0.0" || host == "::" {
+				host = "127.0.0.1"
+			}
+			info.Address = net.JoinHostPort(host, port)
+			info.Port = port
+		}
+	}
+
+	if secret, ok := configData["secret"].(string); ok {
+		info.Secret = secret
+	}
+
+	return info
+}
@@ -0,0 +1,283 @@
+package config
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"clash-center/pkg/converter"
+)
+
+var (
+	// 共享规则集支持的文件扩展名及对应的rule-providers格式
+	ruleSetFormats = map[string]string{
+		".yaml": "yaml",
+		".yml":  "yaml",
+		".list": "text",
+		".txt":  "text",
+	}
+	// provider名称中不允许出现的字符
+	providerNameSanitizer = regexp.MustCompile(`[^\p{L}\p{N}_-]+`)
+)
+
+const (
+	defaultProviderInterval    = 3600
+	defaultHealthCheckURL      = "http://www.gstatic.com/generate_204"
+	defaultHealthCheckInterval = 300
+)
+
+// ApplyProviderMode 将配置中的节点替换为指向clash-center的proxy-providers，
+// 并为引用了共享规则集的RULE-SET规则生成rule-providers
+func ApplyProviderMode(finalConfig map[string]any, targetConfigPath string) error {
+	options := GetProviderOptions(finalConfig)
+	if !options.Enable {
+		return nil
+	}
+
+	token, err := GetSubToken()
+	if err != nil {
+		return err
+	}
+
+	if options.Interval <= 0 {
+		options.Interval = defaultProviderInterval
+	}
+	if options.HealthCheckURL == "" {
+		options.HealthCheckURL = defaultHealthCheckURL
+	}
+	if options.HealthCheckInterval <= 0 {
+		options.HealthCheckInterval = defaultHealthCheckInterval
+	}
+
+	providerName := ProviderName(targetConfigPath)
+
+	// 记录所有节点名称，用于从代理组中移除
+	nodeNames := make(map[string]bool)
+	for _, proxy := range mapList(finalConfig["proxies"]) {
+		if name, ok := proxy["name"].(string); ok {
+			nodeNames[name] = true
+		}
+	}
+
+	// 规则只能引用proxies中的节点和代理组，无法引用provider中的节点，这些节点保留在proxies中
+	// 已有filter的代理组原样保留，filter只作用于provider，无法再用filter选出原来的节点
+	groups := mapList(finalConfig["proxy-groups"])
+	inline := ruleTargetNodes(finalConfig, nodeNames)
+	for _, group := range groups {
+		if _, exists := group["filter"]; !exists {
+			continue
+		}
+		members, _ := group["proxies"].([]any)
+		for _, member := range members {
+			if name, ok := member.(string); ok && nodeNames[name] {
+				inline[name] = true
+			}
+		}
+	}
+
+	var kept []any
+	for _, proxy := range mapList(finalConfig["proxies"]) {
+		if name, _ := proxy["name"].(string); inline[name] {
+			kept = append(kept, proxy)
+		}
+	}
+	if len(kept) == 0 {
+		delete(finalConfig, "proxies")
+	} else {
+		finalConfig["proxies"] = kept
+	}
+
+	proxyProviders := mapValue(finalConfig["proxy-providers"])
+	proxyProviders[providerName] = map[string]any{
+		"type":     "http",
+		"url":      ServerURL + "/provider/" + token + "/" + url.PathEscape(targetConfigPath),
+		"path":     "./providers/" + providerName + ".yaml",
+		"interval": options.Interval,
+		"health-check": map[string]any{
+			"enable":   true,
+			"url":      options.HealthCheckURL,
+			"interval": options.HealthCheckInterval,
+		},
+	}
+	finalConfig["proxy-providers"] = proxyProviders
+
+	// 将代理组中的节点替换为对provider的引用
+	for _, group := range groups {
+		if _, exists := group["filter"]; exists {
+			continue
+		}
+		members, _ := group["proxies"].([]any)
+
+		var kept []any
+		var used []string
+		for _, member := range members {
+			if name, ok := member.(string); ok && nodeNames[name] && !inline[name] {
+				used = append(used, name)
+				continue
+			}
+			kept = append(kept, member)
+		}
+
+		if len(used) == 0 {
+			continue
+		}
+
+		use, _ := group["use"].([]any)
+		if !slices.Contains(use, any(providerName)) {
+			use = append(use, providerName)
+		}
+		group["use"] = use
+
+		// 只包含部分节点的代理组（如按地区分组）通过filter保留原来的节点，
+		// 保留在proxies中的节点也要排除，以免在代理组中重复出现
+		if len(used) < len(nodeNames) {
+			group["filter"] = nodeNameFilter(used)
+		}
+
+		if len(kept) == 0 {
+			delete(group, "proxies")
+		} else {
+			group["proxies"] = kept
+		}
+	}
+	if groups != nil {
+		finalConfig["proxy-groups"] = groups
+	}
+
+	applySharedRuleSets(finalConfig, token, options.Interval)
+	return nil
+}
+
+// 规则和子规则中直接引用的节点
+func ruleTargetNodes(finalConfig map[string]any, nodeNames map[string]bool) map[string]bool {
+	targets := make(map[string]bool)
+	collect := func(value any) {
+		rules, _ := value.([]any)
+		for _, rule := range rules {
+			if ruleStr, ok := rule.(string); ok {
+				if target := converter.RuleTarget(ruleStr); nodeNames[target] {
+					targets[target] = true
+				}
+			}
+		}
+	}
+
+	collect(finalConfig["rules"])
+	for _, rules := range mapValue(finalConfig["sub-rules"]) {
+		collect(rules)
+	}
+	return targets
+}
+
+// 生成只匹配指定节点名称的filter正则表达式
+func nodeNameFilter(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = regexp.QuoteMeta(name)
+	}
+	return "^(?:" + strings.Join(quoted, "|") + ")$"
+}
+
+// ProviderName 根据配置文件名生成provider名称
+func ProviderName(configPath string) string {
+	name := strings.TrimSuffix(filepath.Base(configPath), filepath.Ext(configPath))
+	name = providerNameSanitizer.ReplaceAllString(name, "_")
+	if name == "" {
+		name = "subscription"
+	}
+	return name
+}
+
+// FindRuleSetFile 在共享规则集目录中查找规则集文件
+func FindRuleSetFile(name string) (string, bool) {
+	// 只获取文件名部分，避免任何路径遍历攻击
+	name = filepath.Base(name)
+
+	if _, ok := ruleSetFormats[filepath.Ext(name)]; ok {
+		if _, err := os.Stat(filepath.Join(RuleSetDir, name)); err == nil {
+			return name, true
+		}
+		return "", false
+	}
+
+	for ext := range ruleSetFormats {
+		if _, err := os.Stat(filepath.Join(RuleSetDir, name+ext)); err == nil {
+			return name + ext, true
+		}
+	}
+	return "", false
+}
+
+// 为RULE-SET规则中引用、但未定义的规则集生成rule-providers
+func applySharedRuleSets(finalConfig map[string]any, token string, interval int) {
+	rules, _ := finalConfig["rules"].([]any)
+	if len(rules) == 0 {
+		return
+	}
+
+	ruleProviders := mapValue(finalConfig["rule-providers"])
+	added := false
+
+	for _, rule := range rules {
+		ruleStr, ok := rule.(string)
+		if !ok {
+			continue
+		}
+
+		parts := strings.Split(ruleStr, ",")
+		if len(parts) < 3 || strings.TrimSpace(parts[0]) != "RULE-SET" {
+			continue
+		}
+
+		name := strings.TrimSpace(parts[1])
+		if _, exists := ruleProviders[name]; exists {
+			continue
+		}
+
+		fileName, ok := FindRuleSetFile(name)
+		if !ok {
+			continue
+		}
+
+		ruleProviders[name] = map[string]any{
+			"type":     "http",
+			"behavior": "classical",
+			"format":   ruleSetFormats[filepath.Ext(fileName)],
+			"url":      ServerURL + "/ruleset/" + token + "/" + url.PathEscape(fileName),
+			"path":     "./ruleset/" + fileName,
+			"interval": interval,
+		}
+		added = true
+	}
+
+	if added {
+		finalConfig["rule-providers"] = ruleProviders
+	}
+}
+
+// 将YAML解析出的列表转换为map列表
+func mapList(value any) []map[string]any {
+	list, ok := value.([]any)
+	if !ok {
+		return nil
+	}
+
+	result := make([]map[string]any, 0, len(list))
+	for _, item := range list {
+		if m, ok := item.(map[string]any); ok {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+// 将YAML解析出的值转换为map，不存在时返回空map
+func mapValue(value any) map[string]any {
+	if m, ok := value.(map[string]any); ok {
+		return m
+	}
+	return make(map[string]any)
+}
@@ -0,0 +1,210 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 使用临时的应用配置和共享规则集目录
+func useTempProviderEnv(t *testing.T) {
+	t.Helper()
+	savedApp, savedRuleSets := AppConfigPath, RuleSetDir
+	t.Cleanup(func() { AppConfigPath, RuleSetDir = savedApp, savedRuleSets })
+	AppConfigPath = filepath.Join(t.TempDir(), "app_config.json")
+	RuleSetDir = t.TempDir()
+	if err := os.WriteFile(filepath.Join(RuleSetDir, "streaming.list"), []byte("DOMAIN-SUFFIX,netflix.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+const providerTestProxies = `
+config_provider: {enable: true}
+proxies:
+  - {name: hk1, type: ss, server: hk1.example.com, port: 443}
+  - {name: hk2, type: ss, server: hk2.example.com, port: 443}
+  - {name: jp1, type: ss, server: jp1.example.com, port: 443}
+`
+
+func TestApplyProviderMode(t *testing.T) {
+	useTempProviderEnv(t)
+
+	type group struct {
+		Proxies []string `yaml:"proxies"`
+		Use     []string `yaml:"use"`
+		Filter  string   `yaml:"filter"`
+	}
+	tests := []struct {
+		name string
+		// 追加在节点之后的代理组和规则
+		config string
+		// 保留在proxies中的节点
+		inline []string
+		groups map[string]group
+	}{
+		{
+			name: "包含全部节点的代理组",
+			config: `
+proxy-groups:
+  - {name: 节点选择, type: select, proxies: [自动选择, hk1, hk2, jp1, DIRECT]}
+  - {name: 自动选择, type: url-test, proxies: [hk1, hk2, jp1]}
+rules:
+  - MATCH,节点选择
+`,
+			groups: map[string]group{
+				"节点选择": {Proxies: []string{"自动选择", "DIRECT"}, Use: []string{"sub"}},
+				"自动选择": {Use: []string{"sub"}},
+			},
+		},
+		{
+			name: "只包含部分节点的代理组",
+			config: `
+proxy-groups:
+  - {name: 香港, type: select, proxies: [hk1, hk2]}
+  - {name: 日本, type: select, proxies: [jp1]}
+`,
+			groups: map[string]group{
+				"香港": {Use: []string{"sub"}, Filter: "^(?:hk1|hk2)$"},
+				"日本": {Use: []string{"sub"}, Filter: "^(?:jp1)$"},
+			},
+		},
+		{
+			name: "已有filter的代理组保持不变",
+			config: `
+proxy-groups:
+  - {name: 全部, type: select, proxies: [hk1, hk2, jp1]}
+  - {name: 香港, type: select, filter: "(?i)hk", proxies: [hk1]}
+`,
+			inline: []string{"hk1"},
+			groups: map[string]group{
+				"全部": {Proxies: []string{"hk1"}, Use: []string{"sub"}, Filter: "^(?:hk2|jp1)$"},
+				"香港": {Proxies: []string{"hk1"}, Filter: "(?i)hk"},
+			},
+		},
+		{
+			name: "规则直接引用的节点保留在proxies中",
+			config: `
+proxy-groups:
+  - {name: 全部, type: select, proxies: [hk1, hk2, jp1]}
+rules:
+  - DOMAIN-SUFFIX,jp.example,jp1
+  - AND,((DOMAIN,a.example),(NETWORK,UDP)),hk2,no-resolve
+  - MATCH,全部
+`,
+			inline: []string{"hk2", "jp1"},
+			groups: map[string]group{
+				"全部": {Proxies: []string{"hk2", "jp1"}, Use: []string{"sub"}, Filter: "^(?:hk1)$"},
+			},
+		},
+		{
+			name: "只被规则引用的节点不生成provider引用",
+			config: `
+proxy-groups:
+  - {name: 日本, type: select, proxies: [jp1]}
+rules:
+  - DOMAIN-SUFFIX,jp.example,jp1
+`,
+			inline: []string{"jp1"},
+			groups: map[string]group{
+				"日本": {Proxies: []string{"jp1"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var finalConfig map[string]any
+			if err := yaml.Unmarshal([]byte(providerTestProxies+tt.config), &finalConfig); err != nil {
+				t.Fatal(err)
+			}
+			if err := ApplyProviderMode(finalConfig, "sub.yaml"); err != nil {
+				t.Fatal(err)
+			}
+
+			// 重新编码后按结构比较
+			content, _ := yaml.Marshal(finalConfig)
+			var result struct {
+				Proxies []struct {
+					Name string `yaml:"name"`
+				} `yaml:"proxies"`
+				Groups []struct {
+					Name  string `yaml:"name"`
+					group `yaml:",inline"`
+				} `yaml:"proxy-groups"`
+				Providers map[string]map[string]any `yaml:"proxy-providers"`
+			}
+			if err := yaml.Unmarshal(content, &result); err != nil {
+				t.Fatal(err)
+			}
+
+			var inline []string
+			for _, proxy := range result.Proxies {
+				inline = append(inline, proxy.Name)
+			}
+			if !reflect.DeepEqual(inline, tt.inline) {
+				t.Errorf("proxies中的节点 = %v，应为 %v", inline, tt.inline)
+			}
+			if _, ok := result.Providers["sub"]; !ok {
+				t.Errorf("没有生成proxy-provider: %v", result.Providers)
+			}
+			for _, g := range result.Groups {
+				if want := tt.groups[g.Name]; !reflect.DeepEqual(g.group, want) {
+					t.Errorf("代理组 %s = %+v，应为 %+v", g.Name, g.group, want)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyProviderModeRuleSets(t *testing.T) {
+	useTempProviderEnv(t)
+
+	var finalConfig map[string]any
+	content := providerTestProxies + `
+rule-providers:
+  custom: {type: http, behavior: domain, url: "https://example.com/custom.yaml", path: ./custom.yaml}
+rules:
+  - RULE-SET,streaming,DIRECT
+  - RULE-SET,custom,DIRECT
+  - RULE-SET,missing,DIRECT
+  - MATCH,DIRECT
+`
+	if err := yaml.Unmarshal([]byte(content), &finalConfig); err != nil {
+		t.Fatal(err)
+	}
+	if err := ApplyProviderMode(finalConfig, "sub.yaml"); err != nil {
+		t.Fatal(err)
+	}
+
+	providers, _ := finalConfig["rule-providers"].(map[string]any)
+	// 共享目录中的规则集生成rule-provider，已定义和不存在的规则集不处理
+	streaming, ok := providers["streaming"].(map[string]any)
+	if !ok {
+		t.Fatalf("没有为共享规则集生成rule-provider: %v", providers)
+	}
+	token, _ := GetSubToken()
+	if streaming["format"] != "text" || streaming["url"] != ServerURL+"/ruleset/"+token+"/streaming.list" || streaming["path"] != "./ruleset/streaming.list" {
+		t.Errorf("规则集 = %v", streaming)
+	}
+	if custom, _ := providers["custom"].(map[string]any); custom["url"] != "https://example.com/custom.yaml" {
+		t.Errorf("已定义的规则集被修改: %v", custom)
+	}
+	if _, ok := providers["missing"]; ok {
+		t.Error("为不存在的规则集生成了rule-provider")
+	}
+}
+
+func TestApplyProviderModeDisabled(t *testing.T) {
+	var finalConfig map[string]any
+	if err := yaml.Unmarshal([]byte("proxies:\n  - {name: a, type: ss}\n"), &finalConfig); err != nil {
+		t.Fatal(err)
+	}
+	if err := ApplyProviderMode(finalConfig, "sub.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := finalConfig["proxy-providers"]; ok || finalConfig["proxies"] == nil {
+		t.Errorf("未启用provider模式时修改了配置: %v", finalConfig)
+	}
+}
@@ -0,0 +1,348 @@
+package converter
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"clash-center/internal/config"
+	"clash-center/internal/events"
+	"clash-center/internal/models"
+	conv "clash-center/pkg/converter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// AggregateConfigType 聚合配置的config_type取值
+const AggregateConfigType = "aggregate"
+
+// IsAggregateConfig 判断配置是否为聚合配置
+func IsAggregateConfig(configData map[string]any) bool {
+	configType, _ := configData["config_type"].(string)
+	return configType == AggregateConfigType
+}
+
+// SaveAggregateConfig 构建聚合配置并保存到文件
+func SaveAggregateConfig(filePathName string, configName string, sources []models.AggregateSource) error {
+	yamlConfig, err := BuildAggregateConfig(filePathName, configName, sources)
+	if err != nil {
+		return err
+	}
+
+	content, err := yaml.Marshal(yamlConfig)
+	if err != nil {
+		return fmt.Errorf("编码YAML失败: %v", err)
+	}
+
+	return SaveConfigToFile(content, filePathName)
+}
+
+// BuildAggregateConfig 从多个来源加载节点，合并为一个配置
+func BuildAggregateConfig(filePathName string, configName string, sources []models.AggregateSource) (map[string]any, error) {
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("聚合配置至少需要一个来源")
+	}
+
+	var proxies []map[string]any
+	var proxyGroups []map[string]any
+	var groupNames []any
+
+	names := make(map[string]bool)
+	seen := make(map[string]bool)
+	groupSeen := make(map[string]bool)
+
+	// 汇总各来源的流量信息
+	var userInfo conv.UserInfo
+	hasUserInfo := false
+
+	// 上次生成的聚合配置，来源加载失败时保留该来源原有的节点
+	previous, _ := config.GetConfigInfo(filePathName)
+	// 各来源对应的代理组名称，代理组可能因重名被改名，下次加载失败时按此找回原有的节点
+	sourceGroups := make(map[string]string)
+	keyCounts := make(map[string]int)
+
+	for _, source := range sources {
+		label := sourceLabel(source)
+		key := sourceKey(source)
+		keyCounts[key]++
+		if keyCounts[key] > 1 {
+			key = fmt.Sprintf("%s#%d", key, keyCounts[key])
+		}
+
+		sourceProxies, sourceUserInfo, err := loadSourceProxies(source, filePathName)
+		relabel := true
+		if err != nil {
+			events.Publish(events.SubscriptionFailed, map[string]any{
+				"config": filePathName,
+				"name":   configName,
+				"source": label,
+				"error":  fmt.Sprintf("聚合来源 %s: %v", label, err),
+			})
+			sourceProxies = previousSourceProxies(previous, key, label)
+			if len(sourceProxies) == 0 {
+				log.Printf("加载聚合来源 %s 失败，跳过该来源: %v", label, err)
+				continue
+			}
+			log.Printf("加载聚合来源 %s 失败，保留上次的 %d 个节点: %v", label, len(sourceProxies), err)
+			relabel = false
+		}
+
+		if info, ok := conv.ParseUserInfo(sourceUserInfo); ok {
+			userInfo = userInfo.Add(info)
+			hasUserInfo = true
+		}
+
+		var members []any
+		for _, proxy := range sourceProxies {
+			// 服务器、端口和认证信息完全相同的节点只保留一个
+			key := proxyFingerprint(proxy)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			// 复制节点，避免修改来源配置
+			node := make(map[string]any, len(proxy))
+			for k, v := range proxy {
+				node[k] = v
+			}
+			name, _ := node["name"].(string)
+			if relabel {
+				name = fmt.Sprintf("[%s] %s", label, name)
+			}
+			node["name"] = conv.UniqueName(names, name)
+
+			proxies = append(proxies, node)
+			members = append(members, node["name"])
+		}
+
+		if len(members) == 0 {
+			continue
+		}
+
+		groupName := conv.UniqueName(groupSeen, "📦 "+label)
+		sourceGroups[key] = groupName
+		proxyGroups = append(proxyGroups, map[string]any{
+			"name":    groupName,
+			"type":    "select",
+			"proxies": members,
+		})
+		groupNames = append(groupNames, groupName)
+	}
+
+	if len(proxies) == 0 {
+		return nil, fmt.Errorf("所有来源均未能提供有效的代理节点")
+	}
+
+	// 全局代理组包含各来源的代理组和全部节点
+	allNames := make([]any, len(proxies))
+	for i, proxy := range proxies {
+		allNames[i] = proxy["name"]
+	}
+
+	globalMembers := append([]any{"♻️ 自动选择"}, groupNames...)
+	globalMembers = append(globalMembers, "DIRECT")
+	globalMembers = append(globalMembers, allNames...)
+
+	groups := []map[string]any{
+		{
+			"name":    "🚀 节点选择",
+			"type":    "select",
+			"proxies": globalMembers,
+		},
+		{
+			"name":     "♻️ 自动选择",
+			"type":     "url-test",
+			"proxies":  allNames,
+			"url":      "http://www.gstatic.com/generate_204",
+			"interval": 300,
+		},
+	}
+	groups = append(groups, proxyGroups...)
+
+	yamlConfig := map[string]any{
+		"proxies":        proxies,
+		"proxy-groups":   groups,
+		"rules":          conv.DefaultRules(),
+		"config_type":    AggregateConfigType,
+		"config_sources": sources,
+		"config_groups":  sourceGroups,
+	}
+	if configName != "" {
+		yamlConfig["config_name"] = configName
+	}
+	if hasUserInfo {
+		yamlConfig["config_userinfo"] = userInfo.String()
+	}
+
+	return yamlConfig, nil
+}
+
+// 从上次生成的聚合配置中取出来源对应代理组中的节点，节点名称已带有来源前缀
+func previousSourceProxies(previous map[string]any, key, label string) []map[string]any {
+	if previous == nil {
+		return nil
+	}
+
+	// 早期生成的配置没有记录代理组名称，按默认名称查找
+	groupName := "📦 " + label
+	if recorded, ok := previous["config_groups"].(map[string]any); ok {
+		groupName, _ = recorded[key].(string)
+		if groupName == "" {
+			return nil
+		}
+	}
+
+	members := make(map[string]bool)
+	groups, _ := previous["proxy-groups"].([]any)
+	for _, item := range groups {
+		group, _ := item.(map[string]any)
+		if name, _ := group["name"].(string); name != groupName {
+			continue
+		}
+		list, _ := group["proxies"].([]any)
+		for _, member := range list {
+			if name, ok := member.(string); ok {
+				members[name] = true
+			}
+		}
+	}
+
+	var result []map[string]any
+	for _, proxy := range conv.GetProxyList(previous) {
+		if name, _ := proxy["name"].(string); members[name] {
+			result = append(result, proxy)
+		}
+	}
+	return result
+}
+
+// RefreshDependentAggregates 重新构建引用了指定配置文件或其订阅URL的聚合配置，返回已更新的文件列表
+func RefreshDependentAggregates(fileName string) []string {
+	files, err := os.ReadDir(config.ConfigDir)
+	if err != nil {
+		log.Printf("读取配置目录失败: %v", err)
+		return nil
+	}
+
+	// 聚合配置也可能直接使用该配置的订阅URL作为来源
+	var configSrc string
+	if configData, err := config.GetConfigInfo(fileName); err == nil {
+		configSrc, _ = configData["config_src"].(string)
+	}
+
+	var refreshed []string
+	for _, file := range files {
+		ext := filepath.Ext(file.Name())
+		if file.IsDir() || file.Name() == fileName || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+
+		configData, err := config.GetConfigInfo(file.Name())
+		if err != nil || !IsAggregateConfig(configData) {
+			continue
+		}
+
+		sources := config.GetAggregateSources(configData)
+		if !referencesSource(sources, fileName, configSrc) {
+			continue
+		}
+
+		configName, _ := configData["config_name"].(string)
+		if err := SaveAggregateConfig(file.Name(), configName, sources); err != nil {
+			log.Printf("更新聚合配置 %s 失败: %v", file.Name(), err)
+			continue
+		}
+
+		log.Printf("来源 %s 已更新，聚合配置 %s 已重新生成", fileName, file.Name())
+		events.Publish(events.ConfigUpdated, map[string]any{"config": file.Name(), "name": configName})
+		refreshed = append(refreshed, file.Name())
+	}
+
+	return refreshed
+}
+
+// 判断来源列表中是否引用了指定配置文件或订阅URL
+func referencesSource(sources []models.AggregateSource, fileName, configSrc string) bool {
+	for _, source := range sources {
+		if source.File != "" && filepath.Base(source.File) == fileName {
+			return true
+		}
+		if source.URL != "" && source.URL == configSrc {
+			return true
+		}
+	}
+	return false
+}
+
+// 加载单个来源中的节点，同时返回来源的流量信息
+func loadSourceProxies(source models.AggregateSource, selfFileName string) ([]map[string]any, string, error) {
+	if source.File != "" {
+		// 只获取文件名部分，避免任何路径遍历攻击
+		fileName := filepath.Base(source.File)
+		if fileName == selfFileName {
+			return nil, "", fmt.Errorf("聚合配置不能引用自身")
+		}
+
+		configData, err := config.GetConfigInfo(fileName)
+		if err != nil {
+			return nil, "", err
+		}
+		userInfo, _ := configData["config_userinfo"].(string)
+		return conv.GetProxyList(configData), userInfo, nil
+	}
+
+	if source.URL != "" {
+		result, err := FetchURL(source.URL, source.Fetch)
+		if err != nil {
+			return nil, "", err
+		}
+
+		configData, err := conv.ParseConfigContent(result.Body)
+		if err != nil {
+			return nil, "", err
+		}
+		if err := conv.ApplyNodeFilter(configData, source.Filter); err != nil {
+			return nil, "", err
+		}
+		return conv.GetProxyList(configData), result.Header.Get("subscription-userinfo"), nil
+	}
+
+	return nil, "", fmt.Errorf("来源缺少文件或URL")
+}
+
+// 来源的标识，用于在重新生成时找到该来源上次的代理组
+func sourceKey(source models.AggregateSource) string {
+	if source.File != "" {
+		return "file:" + filepath.Base(source.File)
+	}
+	return "url:" + source.URL
+}
+
+// 获取来源的显示名称
+func sourceLabel(source models.AggregateSource) string {
+	if source.Name != "" {
+		return source.Name
+	}
+	if source.File != "" {
+		base := filepath.Base(source.File)
+		return strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	if u, err := url.Parse(source.URL); err == nil && u.Hostname() != "" {
+		return u.Hostname()
+	}
+	return "未命名来源"
+}
+
+// 生成节点的唯一标识，服务器、端口和认证信息相同的节点视为重复
+func proxyFingerprint(proxy map[string]any) string {
+	fields := []string{"type", "server", "port", "uuid", "password", "cipher", "auth-str", "token", "username"}
+	parts := make([]string, len(fields))
+	for i, field := range fields {
+		parts[i] = fmt.Sprint(proxy[field])
+	}
+	return strings.Join(parts, "|")
+}
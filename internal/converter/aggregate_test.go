@@ -0,0 +1,199 @@
+package converter
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"clash-center/internal/config"
+	"clash-center/internal/events"
+	"clash-center/internal/models"
+	conv "clash-center/pkg/converter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 使用临时的配置目录，写入作为来源的配置文件
+func useAggregateSources(t *testing.T, files map[string]string) {
+	t.Helper()
+	saved := config.ConfigDir
+	t.Cleanup(func() { config.ConfigDir = saved })
+	config.ConfigDir = t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(config.ConfigDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+const aggregateSourceA = `proxies:
+  - {name: hk, type: ss, server: a.example.com, port: 443, cipher: aes-128-gcm, password: p1}
+  - {name: jp, type: ss, server: b.example.com, port: 443, cipher: aes-128-gcm, password: p2}
+`
+
+const aggregateSourceB = `proxies:
+  - {name: hk, type: ss, server: a.example.com, port: 443, cipher: aes-128-gcm, password: p1}
+  - {name: hk, type: ss, server: c.example.com, port: 443, cipher: aes-128-gcm, password: p3}
+`
+
+// 取出节点名称和代理组
+func aggregateResult(t *testing.T, yamlConfig map[string]any) ([]string, map[string][]string) {
+	t.Helper()
+	// 经过YAML编码和解码，与保存后再读取的结构一致
+	content, err := yaml.Marshal(yamlConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]any
+	if err := yaml.Unmarshal(content, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, proxy := range conv.GetProxyList(decoded) {
+		name, _ := proxy["name"].(string)
+		names = append(names, name)
+	}
+	groups := make(map[string][]string)
+	list, _ := decoded["proxy-groups"].([]any)
+	for _, item := range list {
+		group, _ := item.(map[string]any)
+		name, _ := group["name"].(string)
+		members, _ := group["proxies"].([]any)
+		for _, member := range members {
+			groups[name] = append(groups[name], member.(string))
+		}
+	}
+	return names, groups
+}
+
+func saveAggregate(t *testing.T, fileName string, sources []models.AggregateSource) {
+	t.Helper()
+	if err := SaveAggregateConfig(fileName, "聚合", sources); err != nil {
+		t.Fatalf("生成聚合配置失败: %v", err)
+	}
+}
+
+func loadAggregate(t *testing.T, fileName string) map[string]any {
+	t.Helper()
+	configData, err := config.GetConfigInfo(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return configData
+}
+
+func TestBuildAggregateDedupAndNames(t *testing.T) {
+	useAggregateSources(t, map[string]string{"a.yaml": aggregateSourceA, "b.yaml": aggregateSourceB})
+
+	// 两个来源使用相同的名称，代理组需要改名
+	yamlConfig, err := BuildAggregateConfig("all.yaml", "", []models.AggregateSource{
+		{File: "a.yaml", Name: "机场"},
+		{File: "b.yaml", Name: "机场"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, groups := aggregateResult(t, yamlConfig)
+
+	// b.yaml 中与 a.yaml 相同的节点被去掉，同名的节点加上序号
+	want := []string{"[机场] hk", "[机场] jp", "[机场] hk-1"}
+	if !slices.Equal(names, want) {
+		t.Errorf("节点 = %v，应为 %v", names, want)
+	}
+	if got := groups["📦 机场"]; !slices.Equal(got, want[:2]) {
+		t.Errorf("第一个来源的代理组 = %v", got)
+	}
+	if got := groups["📦 机场-1"]; !slices.Equal(got, want[2:]) {
+		t.Errorf("第二个来源的代理组 = %v", got)
+	}
+	if got := groups["♻️ 自动选择"]; !slices.Equal(got, want) {
+		t.Errorf("自动选择 = %v", got)
+	}
+	for _, member := range []string{"📦 机场", "📦 机场-1", "DIRECT"} {
+		if !slices.Contains(groups["🚀 节点选择"], member) {
+			t.Errorf("节点选择中缺少 %s", member)
+		}
+	}
+}
+
+func TestBuildAggregateSkipsFailingSource(t *testing.T) {
+	useAggregateSources(t, map[string]string{"a.yaml": aggregateSourceA})
+	ch, cancel := events.Subscribe(10, 0)
+	defer cancel()
+
+	// 第一次生成时来源就无法加载，跳过该来源
+	yamlConfig, err := BuildAggregateConfig("all.yaml", "聚合", []models.AggregateSource{
+		{File: "a.yaml"},
+		{File: "missing.yaml"},
+	})
+	if err != nil {
+		t.Fatalf("一个来源失败时不应整体失败: %v", err)
+	}
+	names, groups := aggregateResult(t, yamlConfig)
+	if !slices.Equal(names, []string{"[a] hk", "[a] jp"}) {
+		t.Errorf("节点 = %v", names)
+	}
+	if _, ok := groups["📦 missing"]; ok {
+		t.Error("失败的来源不应生成代理组")
+	}
+
+	select {
+	case event := <-ch:
+		if event.Type != events.SubscriptionFailed || event.Data["config"] != "all.yaml" || event.Data["source"] != "missing" {
+			t.Errorf("事件 = %+v", event)
+		}
+		if msg, _ := event.Data["error"].(string); !strings.Contains(msg, "missing") {
+			t.Errorf("事件中的错误没有说明来源: %q", msg)
+		}
+	case <-time.After(time.Second):
+		t.Error("来源失败时没有发布订阅失败事件")
+	}
+
+	// 所有来源都失败时报错
+	if _, err := BuildAggregateConfig("all.yaml", "", []models.AggregateSource{{File: "missing.yaml"}}); err == nil {
+		t.Error("所有来源都失败时应返回错误")
+	}
+}
+
+func TestBuildAggregateKeepsPreviousNodes(t *testing.T) {
+	useAggregateSources(t, map[string]string{"a.yaml": aggregateSourceA, "b.yaml": aggregateSourceB})
+	sources := []models.AggregateSource{
+		{File: "a.yaml", Name: "机场"},
+		{File: "b.yaml", Name: "机场"},
+	}
+	saveAggregate(t, "all.yaml", sources)
+
+	// 第二个来源的代理组因重名改为 "📦 机场-1"，来源失败后仍按记录找回它的节点
+	if err := os.Remove(filepath.Join(config.ConfigDir, "b.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	saveAggregate(t, "all.yaml", sources)
+	names, groups := aggregateResult(t, loadAggregate(t, "all.yaml"))
+	if !slices.Equal(names, []string{"[机场] hk", "[机场] jp", "[机场] hk-1"}) {
+		t.Errorf("节点 = %v", names)
+	}
+	if got := groups["📦 机场-1"]; !slices.Equal(got, []string{"[机场] hk-1"}) {
+		t.Errorf("保留的代理组 = %v", got)
+	}
+
+	// 再次失败时节点名称不会重复添加前缀
+	saveAggregate(t, "all.yaml", sources)
+	names, _ = aggregateResult(t, loadAggregate(t, "all.yaml"))
+	if !slices.Contains(names, "[机场] hk-1") {
+		t.Errorf("再次失败后的节点 = %v", names)
+	}
+
+	// 第一个来源失败时不会取到第二个来源的节点
+	if err := os.Remove(filepath.Join(config.ConfigDir, "a.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	saveAggregate(t, "all.yaml", sources)
+	_, groups = aggregateResult(t, loadAggregate(t, "all.yaml"))
+	if got := groups["📦 机场"]; !slices.Equal(got, []string{"[机场] hk", "[机场] jp"}) {
+		t.Errorf("第一个来源保留的节点 = %v", got)
+	}
+}
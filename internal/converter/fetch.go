@@ -0,0 +1,173 @@
+package converter
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"clash-center/internal/config"
+	"clash-center/internal/metrics"
+	"clash-center/internal/models"
+)
+
+var (
+	// 默认User-Agent，多数机场据此返回Clash格式的配置
+	DefaultUserAgent = "clash.meta"
+	// 默认请求超时
+	DefaultFetchTimeout = 30 * time.Second
+	// 默认响应内容大小上限
+	DefaultMaxFetchSize int64 = 10 << 20
+	// 重试前的等待时间，每次重试递增
+	RetryBackoff = time.Second
+	// 重试次数上限，避免设置过大时请求长时间阻塞
+	MaxFetchRetries = 5
+
+	fetchDuration = metrics.NewHistogram("clash_center_subscription_fetch_duration_seconds",
+		"订阅请求耗时（包括重试）", []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60})
+	fetchFailures = metrics.NewCounter("clash_center_subscription_fetch_failures_total", "订阅请求失败次数（重试后仍失败）")
+)
+
+// FetchResult 订阅请求结果
+type FetchResult struct {
+	Body   []byte
+	Header http.Header
+}
+
+// 可以重试的请求错误
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryableError) Unwrap() error {
+	return e.err
+}
+
+// FetchURL 按照请求设置获取订阅内容，网络错误和服务端错误会按设置重试
+func FetchURL(rawURL string, options models.FetchOptions) (*FetchResult, error) {
+	start := time.Now()
+	result, err := fetchWithRetry(rawURL, options)
+	fetchDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		fetchFailures.Inc()
+	}
+	return result, err
+}
+
+// 发送订阅请求，失败时按设置重试
+func fetchWithRetry(rawURL string, options models.FetchOptions) (*FetchResult, error) {
+	client, err := newFetchClient(options)
+	if err != nil {
+		return nil, err
+	}
+
+	retries := min(options.Retries, MaxFetchRetries)
+	var lastErr error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			log.Printf("请求订阅失败，准备进行第%d次重试: %v", attempt, lastErr)
+			time.Sleep(time.Duration(attempt) * RetryBackoff)
+		}
+
+		result, err := fetchOnce(client, rawURL, options)
+		if err == nil {
+			return result, nil
+		}
+
+		lastErr = err
+		var retryable *retryableError
+		if !errors.As(err, &retryable) {
+			break
+		}
+	}
+
+	return nil, lastErr
+}
+
+// 创建订阅请求使用的HTTP客户端
+func newFetchClient(options models.FetchOptions) (*http.Client, error) {
+	timeout := DefaultFetchTimeout
+	if options.Timeout > 0 {
+		timeout = time.Duration(options.Timeout) * time.Second
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if options.UseProxy {
+		port := config.GetMixedPort()
+		if port == 0 {
+			return nil, fmt.Errorf("当前内核配置中没有mixed-port或port，无法通过Clash代理请求")
+		}
+		proxyURL := &url.URL{Scheme: "http", Host: "127.0.0.1:" + strconv.Itoa(port)}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}, nil
+}
+
+// 发送一次订阅请求
+func fetchOnce(client *http.Client, rawURL string, options models.FetchOptions) (*FetchResult, error) {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+
+	userAgent := options.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+	req.Header.Set("User-Agent", userAgent)
+	for key, value := range options.Headers {
+		req.Header.Set(key, value)
+	}
+
+	// 发送HTTP请求获取配置
+	resp, err := client.Do(req)
+	if err != nil {
+		// 证书验证失败时重试没有意义
+		var certErr *tls.CertificateVerificationError
+		if errors.As(err, &certErr) {
+			return nil, fmt.Errorf("请求URL失败，证书无效: %v", err)
+		}
+		if options.UseProxy {
+			return nil, &retryableError{fmt.Errorf("通过Clash代理请求URL失败，请确认Clash正在运行: %v", err)}
+		}
+		return nil, &retryableError{fmt.Errorf("请求URL失败: %v", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("请求URL返回错误状态码: %d", resp.StatusCode)
+		if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+			return nil, &retryableError{err}
+		}
+		return nil, err
+	}
+
+	maxSize := DefaultMaxFetchSize
+	if options.MaxSize > 0 {
+		maxSize = options.MaxSize
+	}
+
+	// 多读取一个字节用于判断是否超出大小上限
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, &retryableError{fmt.Errorf("读取响应内容失败: %v", err)}
+	}
+	if int64(len(body)) > maxSize {
+		return nil, fmt.Errorf("响应内容超过大小上限 %d 字节", maxSize)
+	}
+
+	return &FetchResult{Body: body, Header: resp.Header}, nil
+}
@@ -0,0 +1,191 @@
+package converter
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"clash-center/internal/config"
+	"clash-center/internal/models"
+)
+
+func init() {
+	RetryBackoff = time.Millisecond
+}
+
+func TestFetchHeaders(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.Header().Set("Subscription-Userinfo", "upload=1; download=2")
+		io.WriteString(w, "proxies: []")
+	}))
+	defer server.Close()
+
+	result, err := FetchURL(server.URL, models.FetchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ua := got.Get("User-Agent"); ua != DefaultUserAgent {
+		t.Errorf("默认User-Agent为 %q", ua)
+	}
+	if string(result.Body) != "proxies: []" || result.Header.Get("Subscription-Userinfo") == "" {
+		t.Errorf("响应为 %q %v", result.Body, result.Header)
+	}
+
+	_, err = FetchURL(server.URL, models.FetchOptions{
+		UserAgent: "mihomo/1.18",
+		Headers:   map[string]string{"X-Token": "abc"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ua := got.Get("User-Agent"); ua != "mihomo/1.18" {
+		t.Errorf("User-Agent为 %q", ua)
+	}
+	if token := got.Get("X-Token"); token != "abc" {
+		t.Errorf("X-Token为 %q", token)
+	}
+}
+
+func TestFetchMaxSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, strings.Repeat("a", 100))
+	}))
+	defer server.Close()
+
+	if _, err := FetchURL(server.URL, models.FetchOptions{MaxSize: 100}); err != nil {
+		t.Fatalf("恰好等于上限时不应失败: %v", err)
+	}
+	if _, err := FetchURL(server.URL, models.FetchOptions{MaxSize: 99}); err == nil {
+		t.Fatal("超过大小上限时应该失败")
+	}
+}
+
+func TestFetchTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	start := time.Now()
+	_, err := FetchURL(server.URL, models.FetchOptions{Timeout: 1})
+	if err == nil {
+		t.Fatal("请求超时时应该失败")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("超时设置无效，耗时 %s", elapsed)
+	}
+}
+
+func TestFetchRetries(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= 2 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	result, err := FetchURL(server.URL, models.FetchOptions{Retries: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(result.Body) != "ok" || requests.Load() != 3 {
+		t.Fatalf("响应为 %q，请求次数 %d", result.Body, requests.Load())
+	}
+
+	// 重试次数不够时返回最后一次的错误
+	requests.Store(0)
+	if _, err := FetchURL(server.URL, models.FetchOptions{Retries: 1}); err == nil {
+		t.Fatal("重试次数不够时应该失败")
+	}
+}
+
+func TestFetchRetryLimitAndClientErrors(t *testing.T) {
+	var requests atomic.Int32
+	status := http.StatusInternalServerError
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	FetchURL(server.URL, models.FetchOptions{Retries: 1000})
+	if n := requests.Load(); n != int32(MaxFetchRetries+1) {
+		t.Errorf("重试次数应限制为 %d，实际请求 %d 次", MaxFetchRetries, n)
+	}
+
+	// 客户端错误不重试
+	requests.Store(0)
+	status = http.StatusForbidden
+	if _, err := FetchURL(server.URL, models.FetchOptions{Retries: 3}); err == nil {
+		t.Fatal("403时应该失败")
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("403时请求了 %d 次", n)
+	}
+}
+
+func TestFetchTLS(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	// 不信任的证书直接失败，不重试
+	_, err := FetchURL(server.URL, models.FetchOptions{Retries: 3})
+	if err == nil || !strings.Contains(err.Error(), "证书") {
+		t.Fatalf("不信任的证书应该失败: %v", err)
+	}
+	if n := requests.Load(); n != 0 {
+		t.Errorf("服务器收到了 %d 个请求", n)
+	}
+}
+
+func TestFetchThroughProxy(t *testing.T) {
+	// 作为HTTP代理的服务器，收到的是完整的目标URL
+	var target string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		target = r.URL.String()
+		io.WriteString(w, "via proxy")
+	}))
+	defer proxy.Close()
+
+	saved := config.MergedConfigPath
+	t.Cleanup(func() { config.MergedConfigPath = saved })
+	config.MergedConfigPath = filepath.Join(t.TempDir(), "config.yaml")
+	port := proxy.Listener.Addr().(*net.TCPAddr).Port
+	os.WriteFile(config.MergedConfigPath, []byte(fmt.Sprintf("mixed-port: %d\n", port)), 0644)
+
+	result, err := FetchURL("http://sub.example.test/path?token=1", models.FetchOptions{UseProxy: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(result.Body) != "via proxy" || target != "http://sub.example.test/path?token=1" {
+		t.Fatalf("响应为 %q，代理收到的地址为 %s", result.Body, target)
+	}
+
+	// 内核配置中没有代理端口时返回错误
+	os.WriteFile(config.MergedConfigPath, []byte("mode: rule\n"), 0644)
+	if _, err := FetchURL("http://sub.example.test/", models.FetchOptions{UseProxy: true}); err == nil {
+		t.Fatal("没有代理端口时应该失败")
+	}
+}
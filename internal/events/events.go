@@ -0,0 +1,97 @@
+// Package events 是进程内的事件总线，用于通知内核和配置的状态变化
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// 事件类型
+const (
+	CoreStarted  = "core.started"
+	CoreStopped  = "core.stopped"
+	CoreCrashed  = "core.crashed"
+	CoreReplaced = "core.replaced" // 内核可执行文件被替换或回滚
+
+	ConfigSwitched = "config.switched"
+	ConfigAdded    = "config.added"
+	ConfigUpdated  = "config.updated"
+	ConfigDeleted  = "config.deleted"
+
+	SubscriptionUpdated = "subscription.updated"
+	SubscriptionFailed  = "subscription.failed"
+)
+
+// 保留的最近事件数量，用于断线重连后补发
+const historySize = 100
+
+// Event 事件
+type Event struct {
+	ID   uint64         `json:"id"`
+	Type string         `json:"type"`
+	Time time.Time      `json:"time"`
+	Data map[string]any `json:"data,omitempty"`
+}
+
+var (
+	mu          sync.Mutex
+	nextID      uint64 = 1
+	history     []Event
+	subscribers = make(map[chan Event]struct{})
+)
+
+// Publish 发布事件，订阅者处理不及时时会丢弃该订阅者的事件
+func Publish(eventType string, data map[string]any) Event {
+	mu.Lock()
+	event := Event{
+		ID:   nextID,
+		Type: eventType,
+		Time: time.Now(),
+		Data: data,
+	}
+	nextID++
+
+	history = append(history, event)
+	if len(history) > historySize {
+		history = history[len(history)-historySize:]
+	}
+
+	for ch := range subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+	mu.Unlock()
+
+	return event
+}
+
+// Subscribe 订阅事件，返回的函数用于取消订阅
+// lastID大于0时会先补发该ID之后仍保留在历史中的事件
+func Subscribe(buffer int, lastID uint64) (<-chan Event, func()) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var missed []Event
+	for _, event := range history {
+		if lastID > 0 && event.ID > lastID {
+			missed = append(missed, event)
+		}
+	}
+
+	ch := make(chan Event, buffer+len(missed))
+	for _, event := range missed {
+		ch <- event
+	}
+	subscribers[ch] = struct{}{}
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			mu.Lock()
+			delete(subscribers, ch)
+			mu.Unlock()
+		})
+	}
+}
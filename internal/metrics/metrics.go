@@ -0,0 +1,122 @@
+// Package metrics 以Prometheus文本格式输出运行指标
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Metric 可以输出的指标
+type Metric interface {
+	write(e *Encoder)
+}
+
+// Collector 在每次抓取时动态生成指标
+type Collector func(e *Encoder)
+
+var (
+	registryMu sync.Mutex
+	registry   []Metric
+	collectors []Collector
+)
+
+// Register 注册固定的指标
+func Register(metrics ...Metric) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = append(registry, metrics...)
+}
+
+// RegisterCollector 注册动态生成指标的函数
+func RegisterCollector(collector Collector) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	collectors = append(collectors, collector)
+}
+
+// Handler 返回输出全部指标的HTTP处理函数
+func Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		registryMu.Lock()
+		metrics := slices.Clone(registry)
+		dynamic := slices.Clone(collectors)
+		registryMu.Unlock()
+
+		var buf bytes.Buffer
+		e := &Encoder{w: &buf}
+		for _, metric := range metrics {
+			metric.write(e)
+		}
+		for _, collector := range dynamic {
+			collector(e)
+		}
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write(buf.Bytes())
+	}
+}
+
+// Encoder 写入Prometheus文本格式
+type Encoder struct {
+	w io.Writer
+}
+
+// Header 写入指标的说明和类型，同名的样本需要紧跟在后面
+func (e *Encoder) Header(name, help, metricType string) {
+	fmt.Fprintf(e.w, "# HELP %s %s\n", name, escapeHelp(help))
+	fmt.Fprintf(e.w, "# TYPE %s %s\n", name, metricType)
+}
+
+// Sample 写入一个样本，labels为 名称, 值 交替排列
+func (e *Encoder) Sample(name string, value float64, labels ...string) {
+	io.WriteString(e.w, name)
+	if len(labels) > 0 {
+		io.WriteString(e.w, "{")
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				io.WriteString(e.w, ",")
+			}
+			fmt.Fprintf(e.w, "%s=\"%s\"", labels[i], escapeLabel(labels[i+1]))
+		}
+		io.WriteString(e.w, "}")
+	}
+	fmt.Fprintf(e.w, " %s\n", formatValue(value))
+}
+
+// Gauge 写入只有一个样本的gauge指标
+func (e *Encoder) Gauge(name, help string, value float64) {
+	e.Header(name, help, "gauge")
+	e.Sample(name, value)
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var (
+	helpReplacer  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelReplacer.Replace(s)
+}
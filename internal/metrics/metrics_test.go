@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"math"
+	"net/http/httptest"
+	"testing"
+)
+
+// 使用空的注册表，测试结束后恢复
+func useEmptyRegistry(t *testing.T) {
+	t.Helper()
+	registryMu.Lock()
+	savedRegistry, savedCollectors := registry, collectors
+	registry, collectors = nil, nil
+	registryMu.Unlock()
+	t.Cleanup(func() {
+		registryMu.Lock()
+		registry, collectors = savedRegistry, savedCollectors
+		registryMu.Unlock()
+	})
+}
+
+const handlerGolden = `# HELP test_restarts_total 重启次数
+# TYPE test_restarts_total counter
+test_restarts_total 0
+# HELP test_fetches_total 订阅获取次数\\说明
+# TYPE test_fetches_total counter
+test_fetches_total{config="b.yaml",result="ok"} 2
+test_fetches_total{config="a \"x\"\n.yaml",result="error"} 1
+# HELP test_duration_seconds 耗时
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{le="0.5"} 1
+test_duration_seconds_bucket{le="2"} 2
+test_duration_seconds_bucket{le="+Inf"} 3
+test_duration_seconds_sum 11.5
+test_duration_seconds_count 3
+# HELP test_up 是否可用
+# TYPE test_up gauge
+test_up 1
+# HELP test_values 特殊值
+# TYPE test_values gauge
+test_values{kind="inf"} +Inf
+test_values{kind="nan"} NaN
+test_values{kind="big"} 1e+21
+`
+
+func TestHandlerGolden(t *testing.T) {
+	useEmptyRegistry(t)
+
+	NewCounter("test_restarts_total", "重启次数")
+	fetches := NewCounter("test_fetches_total", `订阅获取次数\说明`, "config", "result")
+	fetches.Inc("b.yaml", "ok")
+	fetches.Inc("a \"x\"\n.yaml", "error")
+	fetches.Inc("b.yaml", "ok")
+	// 区间上界按从小到大输出
+	duration := NewHistogram("test_duration_seconds", "耗时", []float64{2, 0.5})
+	duration.Observe(0.2)
+	duration.Observe(1.3)
+	duration.Observe(10)
+	RegisterCollector(func(e *Encoder) {
+		e.Gauge("test_up", "是否可用", 1)
+		e.Header("test_values", "特殊值", "gauge")
+		e.Sample("test_values", math.Inf(1), "kind", "inf")
+		e.Sample("test_values", math.NaN(), "kind", "nan")
+		e.Sample("test_values", 1e21, "kind", "big")
+	})
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if got := rec.Header().Get("Content-Type"); got != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if got := rec.Body.String(); got != handlerGolden {
+		t.Errorf("输出 =\n%s\n应为\n%s", got, handlerGolden)
+	}
+}
@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Counter 只增不减的计数器，可以带标签
+type Counter struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]float64
+	order  []string
+}
+
+// NewCounter 创建并注册计数器
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{name: name, help: help, labels: labels, values: make(map[string]float64)}
+	Register(c)
+	return c
+}
+
+// Inc 计数加一，labelValues与创建时的标签一一对应
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add 增加计数
+func (c *Counter) Add(value float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\x00")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.values[key]; !ok {
+		c.order = append(c.order, key)
+	}
+	c.values[key] += value
+}
+
+func (c *Counter) write(e *Encoder) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e.Header(c.name, c.help, "counter")
+	// 没有标签的计数器始终输出，便于告警规则使用
+	if len(c.labels) == 0 && len(c.values) == 0 {
+		e.Sample(c.name, 0)
+		return
+	}
+	for _, key := range c.order {
+		e.Sample(c.name, c.values[key], pairLabels(c.labels, key)...)
+	}
+}
+
+// 将标签名和以\x00连接的标签值组合为 名称, 值 交替的列表
+func pairLabels(names []string, key string) []string {
+	if len(names) == 0 {
+		return nil
+	}
+	values := strings.Split(key, "\x00")
+	pairs := make([]string, 0, len(names)*2)
+	for i, name := range names {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		pairs = append(pairs, name, value)
+	}
+	return pairs
+}
+
+// Histogram 直方图
+type Histogram struct {
+	name    string
+	help    string
+	buckets []float64
+
+	mu     sync.Mutex
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram 创建并注册直方图，buckets为各区间的上界
+func NewHistogram(name, help string, buckets []float64) *Histogram {
+	buckets = slices.Clone(buckets)
+	slices.Sort(buckets)
+	h := &Histogram{name: name, help: help, buckets: buckets, counts: make([]uint64, len(buckets))}
+	Register(h)
+	return h
+}
+
+// Observe 记录一个观测值
+func (h *Histogram) Observe(value float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, bound := range h.buckets {
+		if value <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += value
+	h.count++
+}
+
+func (h *Histogram) write(e *Encoder) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	e.Header(h.name, h.help, "histogram")
+	for i, bound := range h.buckets {
+		e.Sample(h.name+"_bucket", float64(h.counts[i]), "le", strconv.FormatFloat(bound, 'g', -1, 64))
+	}
+	e.Sample(h.name+"_bucket", float64(h.count), "le", "+Inf")
+	e.Sample(h.name+"_sum", h.sum)
+	e.Sample(h.name+"_count", float64(h.count))
+}
@@ -0,0 +1,135 @@
+// Package mihomo 是Mihomo(Clash.Meta)控制器REST API的客户端
+package mihomo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// 默认请求超时
+const defaultTimeout = 10 * time.Second
+
+// Client 控制器客户端
+type Client struct {
+	BaseURL    string
+	Secret     string
+	HTTPClient *http.Client
+}
+
+// Error 控制器返回的错误
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("控制器返回错误状态码: %d", e.StatusCode)
+	}
+	return fmt.Sprintf("控制器返回错误: %s (%d)", e.Message, e.StatusCode)
+}
+
+// NewClient 创建控制器客户端，address为 host:port 或完整URL
+func NewClient(address, secret string) *Client {
+	baseURL := address
+	if u, err := url.Parse(address); err != nil || u.Scheme == "" || u.Host == "" {
+		baseURL = "http://" + address
+	}
+
+	return &Client{
+		BaseURL:    baseURL,
+		Secret:     secret,
+		HTTPClient: &http.Client{Timeout: defaultTimeout},
+	}
+}
+
+// Version 控制器版本信息
+type Version struct {
+	Version string `json:"version"`
+	Meta    bool   `json:"meta"`
+}
+
+// Version 获取内核版本，也可用于检查控制器是否可用
+func (c *Client) Version(ctx context.Context) (Version, error) {
+	var version Version
+	err := c.do(ctx, http.MethodGet, "/version", nil, nil, &version)
+	return version, err
+}
+
+// 发送请求并解析JSON响应，out为nil时忽略响应内容
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body any, out any) error {
+	resp, err := c.request(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("解析控制器响应失败: %v", err)
+	}
+	return nil
+}
+
+// 发送请求，检查状态码后返回响应，调用方负责关闭响应体
+func (c *Client) request(ctx context.Context, method, path string, query url.Values, body any) (*http.Response, error) {
+	u := c.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		content, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("编码请求内容失败: %v", err)
+		}
+		reader = bytes.NewReader(content)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Secret != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Secret)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求控制器失败: %v", err)
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer resp.Body.Close()
+		var errBody struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&errBody)
+		return nil, &Error{StatusCode: resp.StatusCode, Message: errBody.Message}
+	}
+
+	return resp, nil
+}
+
+// 生成延迟测试的查询参数
+func delayQuery(testURL string, timeout time.Duration) url.Values {
+	query := url.Values{}
+	query.Set("url", testURL)
+	query.Set("timeout", strconv.FormatInt(timeout.Milliseconds(), 10))
+	return query
+}
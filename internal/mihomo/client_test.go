@@ -0,0 +1,218 @@
+package mihomo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// 模拟控制器，检查密钥并按路径返回响应
+func newFakeController(t *testing.T, handlers map[string]http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"message": "Unauthorized"})
+			return
+		}
+		handler, ok := handlers[r.Method+" "+r.URL.EscapedPath()]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"message": "resource not found"})
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return NewClient(server.Listener.Addr().String(), "secret")
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func TestNewClientAddress(t *testing.T) {
+	if c := NewClient("127.0.0.1:9090", ""); c.BaseURL != "http://127.0.0.1:9090" {
+		t.Errorf("BaseURL为 %s", c.BaseURL)
+	}
+	if c := NewClient("https://example.test:9090", ""); c.BaseURL != "https://example.test:9090" {
+		t.Errorf("BaseURL为 %s", c.BaseURL)
+	}
+}
+
+func TestVersionAndErrors(t *testing.T) {
+	client := newFakeController(t, map[string]http.HandlerFunc{
+		"GET /version": func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, map[string]any{"version": "v1.18.0", "meta": true})
+		},
+	})
+
+	version, err := client.Version(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version.Version != "v1.18.0" || !version.Meta {
+		t.Errorf("版本为 %+v", version)
+	}
+
+	// 密钥错误时返回控制器的错误信息
+	client.Secret = "wrong"
+	_, err = client.Version(context.Background())
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized || apiErr.Message != "Unauthorized" {
+		t.Fatalf("错误为 %v", err)
+	}
+}
+
+func TestProxies(t *testing.T) {
+	client := newFakeController(t, map[string]http.HandlerFunc{
+		"GET /proxies": func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, map[string]any{"proxies": map[string]any{
+				"GLOBAL": map[string]any{"name": "GLOBAL", "type": "Selector", "now": "Proxy", "all": []string{"Proxy"}},
+				"Proxy":  map[string]any{"name": "Proxy", "type": "Selector", "now": "HK", "all": []string{"HK"}},
+				"HK": map[string]any{"name": "HK", "type": "Shadowsocks", "alive": true,
+					"history": []map[string]any{{"time": "2024-01-01T00:00:00Z", "delay": 120}}},
+			}})
+		},
+		"GET /proxies/%E9%A6%99%E6%B8%AF%201": func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, map[string]any{"name": "香港 1", "type": "Trojan", "history": []any{}})
+		},
+	})
+
+	proxies, err := client.Proxies(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proxies["Proxy"].IsGroup() || proxies["HK"].IsGroup() {
+		t.Errorf("代理组判断错误: %+v", proxies)
+	}
+	if delay := proxies["HK"].LastDelay(); delay != 120 {
+		t.Errorf("延迟为 %d", delay)
+	}
+	if delay := proxies["Proxy"].LastDelay(); delay != -1 {
+		t.Errorf("没有记录时延迟为 %d", delay)
+	}
+
+	// 名称中的特殊字符需要转义
+	proxy, err := client.Proxy(context.Background(), "香港 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proxy.Name != "香港 1" {
+		t.Errorf("节点为 %+v", proxy)
+	}
+}
+
+func TestSelectProxy(t *testing.T) {
+	var selected string
+	client := newFakeController(t, map[string]http.HandlerFunc{
+		"PUT /proxies/Proxy": func(w http.ResponseWriter, r *http.Request) {
+			var body struct {
+				Name string `json:"name"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			if body.Name != "HK" {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"message": "Selector update error: proxy not exist"})
+				return
+			}
+			selected = body.Name
+			w.WriteHeader(http.StatusNoContent)
+		},
+	})
+
+	if err := client.SelectProxy(context.Background(), "Proxy", "HK"); err != nil {
+		t.Fatal(err)
+	}
+	if selected != "HK" {
+		t.Errorf("选择的节点为 %q", selected)
+	}
+
+	err := client.SelectProxy(context.Background(), "Proxy", "US")
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("错误为 %v", err)
+	}
+}
+
+func TestDelay(t *testing.T) {
+	client := newFakeController(t, map[string]http.HandlerFunc{
+		"GET /proxies/HK/delay": func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("url") != "http://test.example/204" || r.URL.Query().Get("timeout") != "3000" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			writeJSON(w, map[string]int{"delay": 88})
+		},
+		"GET /group/Proxy/delay": func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, map[string]int{"HK": 88, "JP": 120})
+		},
+		"GET /proxies/US/delay": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusGatewayTimeout)
+			json.NewEncoder(w).Encode(map[string]string{"message": "Timeout"})
+		},
+	})
+
+	delay, err := client.ProxyDelay(context.Background(), "HK", "http://test.example/204", 3*time.Second)
+	if err != nil || delay != 88 {
+		t.Fatalf("延迟为 %d，错误 %v", delay, err)
+	}
+
+	delays, err := client.GroupDelay(context.Background(), "Proxy", "http://test.example/204", 3*time.Second)
+	if err != nil || len(delays) != 2 || delays["JP"] != 120 {
+		t.Fatalf("延迟为 %v，错误 %v", delays, err)
+	}
+
+	_, err = client.ProxyDelay(context.Background(), "US", "http://test.example/204", 3*time.Second)
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusGatewayTimeout {
+		t.Fatalf("超时的错误为 %v", err)
+	}
+}
+
+func TestProviders(t *testing.T) {
+	updated := false
+	client := newFakeController(t, map[string]http.HandlerFunc{
+		"GET /providers/proxies": func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, map[string]any{"providers": map[string]any{
+				"sub": map[string]any{
+					"name": "sub", "type": "Proxy", "vehicleType": "HTTP",
+					"proxies":          []map[string]any{{"name": "HK", "type": "Trojan"}},
+					"subscriptionInfo": map[string]int64{"Upload": 1, "Download": 2, "Total": 10, "Expire": 0},
+				},
+			}})
+		},
+		"PUT /providers/proxies/sub": func(w http.ResponseWriter, r *http.Request) {
+			updated = true
+			w.WriteHeader(http.StatusNoContent)
+		},
+	})
+
+	providers, err := client.Providers(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := providers["sub"]
+	if len(sub.Proxies) != 1 || sub.SubscriptionInfo == nil || sub.SubscriptionInfo.Total != 10 {
+		t.Errorf("节点提供者为 %+v", sub)
+	}
+
+	if err := client.UpdateProvider(context.Background(), "sub"); err != nil || !updated {
+		t.Fatalf("更新失败: %v", err)
+	}
+}
+
+func TestUnreachableController(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	address := server.Listener.Addr().String()
+	server.Close()
+
+	if _, err := NewClient(address, "").Version(context.Background()); err == nil {
+		t.Fatal("控制器不可用时应该返回错误")
+	}
+}
@@ -0,0 +1,60 @@
+package mihomo
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Connections 当前连接和累计流量
+type Connections struct {
+	DownloadTotal int64        `json:"downloadTotal"`
+	UploadTotal   int64        `json:"uploadTotal"`
+	Connections   []Connection `json:"connections"`
+	Memory        int64        `json:"memory,omitempty"`
+}
+
+// Connection 单个连接
+type Connection struct {
+	ID          string             `json:"id"`
+	Metadata    ConnectionMetadata `json:"metadata"`
+	Upload      int64              `json:"upload"`
+	Download    int64              `json:"download"`
+	Start       time.Time          `json:"start"`
+	Chains      []string           `json:"chains"`
+	Rule        string             `json:"rule"`
+	RulePayload string             `json:"rulePayload"`
+}
+
+// ConnectionMetadata 连接的来源和目标信息
+type ConnectionMetadata struct {
+	Network         string `json:"network"`
+	Type            string `json:"type"`
+	SourceIP        string `json:"sourceIP"`
+	DestinationIP   string `json:"destinationIP"`
+	SourcePort      string `json:"sourcePort"`
+	DestinationPort string `json:"destinationPort"`
+	Host            string `json:"host"`
+	SniffHost       string `json:"sniffHost,omitempty"`
+	Process         string `json:"process,omitempty"`
+	ProcessPath     string `json:"processPath,omitempty"`
+	InboundName     string `json:"inboundName,omitempty"`
+}
+
+// Connections 获取当前连接快照
+func (c *Client) Connections(ctx context.Context) (Connections, error) {
+	var connections Connections
+	err := c.do(ctx, http.MethodGet, "/connections", nil, nil, &connections)
+	return connections, err
+}
+
+// CloseConnection 关闭单个连接
+func (c *Client) CloseConnection(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/connections/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// CloseAllConnections 关闭全部连接
+func (c *Client) CloseAllConnections(ctx context.Context) error {
+	return c.do(ctx, http.MethodDelete, "/connections", nil, nil, nil)
+}
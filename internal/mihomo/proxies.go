@@ -0,0 +1,110 @@
+package mihomo
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// DelayHistory 延迟测试记录，Delay为0表示超时
+type DelayHistory struct {
+	Time  time.Time `json:"time"`
+	Delay int       `json:"delay"`
+}
+
+// Proxy 节点或代理组
+type Proxy struct {
+	Name    string         `json:"name"`
+	Type    string         `json:"type"`
+	Now     string         `json:"now,omitempty"` // 代理组当前选择的节点
+	All     []string       `json:"all,omitempty"` // 代理组的全部成员
+	History []DelayHistory `json:"history"`
+	Alive   bool           `json:"alive"`
+	UDP     bool           `json:"udp"`
+	Hidden  bool           `json:"hidden,omitempty"`
+	TestURL string         `json:"testUrl,omitempty"`
+}
+
+// IsGroup 判断是否为代理组
+func (p Proxy) IsGroup() bool {
+	return p.All != nil
+}
+
+// LastDelay 最近一次延迟测试结果，没有记录时返回-1
+func (p Proxy) LastDelay() int {
+	if len(p.History) == 0 {
+		return -1
+	}
+	return p.History[len(p.History)-1].Delay
+}
+
+// Provider 节点提供者
+type Provider struct {
+	Name             string            `json:"name"`
+	Type             string            `json:"type"`
+	VehicleType      string            `json:"vehicleType"`
+	Proxies          []Proxy           `json:"proxies"`
+	UpdatedAt        time.Time         `json:"updatedAt"`
+	SubscriptionInfo *SubscriptionInfo `json:"subscriptionInfo,omitempty"`
+}
+
+// SubscriptionInfo 节点提供者的流量信息
+type SubscriptionInfo struct {
+	Upload   int64 `json:"Upload"`
+	Download int64 `json:"Download"`
+	Total    int64 `json:"Total"`
+	Expire   int64 `json:"Expire"`
+}
+
+// Proxies 获取全部节点和代理组
+func (c *Client) Proxies(ctx context.Context) (map[string]Proxy, error) {
+	var result struct {
+		Proxies map[string]Proxy `json:"proxies"`
+	}
+	err := c.do(ctx, http.MethodGet, "/proxies", nil, nil, &result)
+	return result.Proxies, err
+}
+
+// Proxy 获取单个节点或代理组
+func (c *Client) Proxy(ctx context.Context, name string) (Proxy, error) {
+	var proxy Proxy
+	err := c.do(ctx, http.MethodGet, "/proxies/"+url.PathEscape(name), nil, nil, &proxy)
+	return proxy, err
+}
+
+// SelectProxy 在select类型的代理组中选择节点
+func (c *Client) SelectProxy(ctx context.Context, group, name string) error {
+	body := map[string]string{"name": name}
+	return c.do(ctx, http.MethodPut, "/proxies/"+url.PathEscape(group), nil, body, nil)
+}
+
+// ProxyDelay 测试单个节点的延迟（毫秒）
+func (c *Client) ProxyDelay(ctx context.Context, name, testURL string, timeout time.Duration) (int, error) {
+	var result struct {
+		Delay int `json:"delay"`
+	}
+	err := c.do(ctx, http.MethodGet, "/proxies/"+url.PathEscape(name)+"/delay", delayQuery(testURL, timeout), nil, &result)
+	return result.Delay, err
+}
+
+// GroupDelay 测试代理组中所有节点的延迟，返回 节点名称 -> 延迟（毫秒），超时的节点不在结果中
+func (c *Client) GroupDelay(ctx context.Context, group, testURL string, timeout time.Duration) (map[string]int, error) {
+	result := make(map[string]int)
+	err := c.do(ctx, http.MethodGet, "/group/"+url.PathEscape(group)+"/delay", delayQuery(testURL, timeout), nil, &result)
+	return result, err
+}
+
+// Providers 获取全部节点提供者
+func (c *Client) Providers(ctx context.Context) (map[string]Provider, error) {
+	var result struct {
+		Providers map[string]Provider `json:"providers"`
+	}
+	err := c.do(ctx, http.MethodGet, "/providers/proxies", nil, nil, &result)
+	return result.Providers, err
+}
+
+// UpdateProvider 让内核立即重新拉取节点提供者
+func (c *Client) UpdateProvider(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodPut, "/providers/proxies/"+url.PathEscape(name), nil, nil, nil)
+}
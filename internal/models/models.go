@@ -1,16 +1,24 @@
 package models
 
+import "clash-center/pkg/converter"
+
 // ConfigFile 配置文件信息
 type ConfigFile struct {
 	Path        string `json:"path"`
 	DisplayName string `json:"display_name"`
 	ConfigSrc   string `json:"config_src"`
+	ConfigType  string `json:"config_type,omitempty"`
 }
 
 // AppConfig 应用程序配置
 type AppConfig struct {
 	LastConfig string `json:"last_config"`
 	AutoStart  bool   `json:"auto_start"`
+	SubToken   string `json:"sub_token,omitempty"` // 本地订阅接口的访问令牌
+	// 每个配置文件中select代理组选择的节点，配置文件 -> 代理组 -> 节点
+	Selections map[string]map[string]string `json:"selections,omitempty"`
+	// 通知设置
+	Notify NotifySettings `json:"notify"`
 }
 
 // APIResponse API响应通用结构
@@ -18,5 +26,286 @@ type APIResponse struct {
 	Success bool   `json:"success"`
 	Message string `json:"message,omitempty"`
 	Error   string `json:"error,omitempty"`
+	Code    string `json:"code,omitempty"` // 机器可读的错误码
 	Data    any    `json:"data,omitempty"`
 }
+
+// NodeFilter 订阅节点过滤与重命名规则，与公开的转换接口共用同一类型
+type NodeFilter = converter.NodeFilter
+
+// RenameRule 节点重命名规则
+type RenameRule = converter.RenameRule
+
+// AggregateSource 聚合配置的来源，File和URL二选一
+type AggregateSource struct {
+	Name   string       `json:"name,omitempty" yaml:"name,omitempty"`     // 来源名称，用作节点前缀和代理组名称
+	File   string       `json:"file,omitempty" yaml:"file,omitempty"`     // 配置目录中的配置文件
+	URL    string       `json:"url,omitempty" yaml:"url,omitempty"`       // 订阅URL
+	Filter NodeFilter   `json:"filter,omitempty" yaml:"filter,omitempty"` // 仅对URL来源生效的过滤规则
+	Fetch  FetchOptions `json:"fetch,omitempty" yaml:"fetch,omitempty"`   // 仅对URL来源生效的请求设置
+}
+
+// ProviderOptions 以proxy-providers方式提供订阅节点的设置
+type ProviderOptions struct {
+	Enable              bool   `json:"enable" yaml:"enable"`
+	Interval            int    `json:"interval,omitempty" yaml:"interval,omitempty"`                           // 内核拉取节点的间隔（秒）
+	HealthCheckURL      string `json:"health_check_url,omitempty" yaml:"health_check_url,omitempty"`           // 健康检查地址
+	HealthCheckInterval int    `json:"health_check_interval,omitempty" yaml:"health_check_interval,omitempty"` // 健康检查间隔（秒）
+}
+
+// FetchOptions 订阅请求设置，零值表示使用默认值
+type FetchOptions struct {
+	UserAgent string            `json:"user_agent,omitempty" yaml:"user_agent,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Timeout   int               `json:"timeout,omitempty" yaml:"timeout,omitempty"`     // 请求超时（秒）
+	MaxSize   int64             `json:"max_size,omitempty" yaml:"max_size,omitempty"`   // 响应内容大小上限（字节）
+	UseProxy  bool              `json:"use_proxy,omitempty" yaml:"use_proxy,omitempty"` // 通过Clash的mixed-port请求
+	Retries   int               `json:"retries,omitempty" yaml:"retries,omitempty"`     // 失败后的重试次数
+}
+
+// IsEmpty 判断请求设置是否为空
+func (o FetchOptions) IsEmpty() bool {
+	return o.UserAgent == "" && len(o.Headers) == 0 && o.Timeout == 0 && o.MaxSize == 0 && !o.UseProxy && o.Retries == 0
+}
+
+// ProxyGroup 代理组及其当前选择
+type ProxyGroup struct {
+	Name    string      `json:"name"`
+	Type    string      `json:"type"`
+	Now     string      `json:"now"`
+	Hidden  bool        `json:"hidden,omitempty"`
+	Members []ProxyNode `json:"members"`
+}
+
+// ProxyNode 代理组成员，可能是节点也可能是另一个代理组
+type ProxyNode struct {
+	Name     string         `json:"name"`
+	Type     string         `json:"type"`
+	Provider string         `json:"provider,omitempty"` // 节点所属的proxy-provider
+	Alive    bool           `json:"alive"`
+	Delay    int            `json:"delay"` // 最近一次测试的延迟（毫秒），0表示超时，-1表示未测试
+	History  []DelayHistory `json:"history"`
+}
+
+// DelayHistory 延迟测试记录
+type DelayHistory struct {
+	Time  string `json:"time"`
+	Delay int    `json:"delay"`
+}
+
+// ConnectionInfo 内核中的活动连接
+type ConnectionInfo struct {
+	ID          string   `json:"id"`
+	Network     string   `json:"network"`
+	Type        string   `json:"type"`
+	Source      string   `json:"source"`
+	Destination string   `json:"destination"`
+	Host        string   `json:"host"`
+	Process     string   `json:"process,omitempty"`
+	Rule        string   `json:"rule"`
+	RulePayload string   `json:"rule_payload,omitempty"`
+	Chains      []string `json:"chains"`
+	Upload      int64    `json:"upload"`
+	Download    int64    `json:"download"`
+	Start       string   `json:"start"`
+}
+
+// ConnectionStat 按规则或代理链汇总的连接统计
+type ConnectionStat struct {
+	Key         string `json:"key"`
+	Connections int    `json:"connections"`
+	Upload      int64  `json:"upload"`
+	Download    int64  `json:"download"`
+}
+
+// ConnectionFilter 连接过滤条件，均为不区分大小写的子串匹配，空值表示不限制
+type ConnectionFilter struct {
+	Host    string `json:"host,omitempty"`
+	Process string `json:"process,omitempty"`
+	Rule    string `json:"rule,omitempty"`
+	Chain   string `json:"chain,omitempty"`
+}
+
+// IsEmpty 判断过滤条件是否为空
+func (f ConnectionFilter) IsEmpty() bool {
+	return f.Host == "" && f.Process == "" && f.Rule == "" && f.Chain == ""
+}
+
+// NotifySettings 通知设置
+type NotifySettings struct {
+	// 启用的通知类型，未设置的类型使用默认值
+	Events map[string]bool `json:"events,omitempty"`
+	// 剩余流量低于总量的百分比时发送通知，0表示使用默认值
+	QuotaThreshold float64 `json:"quota_threshold,omitempty"`
+	// 在CrashLoopWindow秒内意外退出CrashLoopCount次视为反复崩溃
+	CrashLoopCount  int `json:"crash_loop_count,omitempty"`
+	CrashLoopWindow int `json:"crash_loop_window,omitempty"`
+
+	Webhook  *WebhookSettings  `json:"webhook,omitempty"`
+	Telegram *TelegramSettings `json:"telegram,omitempty"`
+	SMTP     *SMTPSettings     `json:"smtp,omitempty"`
+}
+
+// WebhookSettings 通用Webhook通知设置
+type WebhookSettings struct {
+	URL     string            `json:"url"`
+	Method  string            `json:"method,omitempty"`  // 默认为POST
+	Headers map[string]string `json:"headers,omitempty"` // 值可以使用模板
+	Body    string            `json:"body,omitempty"`    // text/template模板，为空时发送通知的JSON
+}
+
+// TelegramSettings Telegram机器人通知设置
+type TelegramSettings struct {
+	BotToken string `json:"bot_token"`
+	ChatID   string `json:"chat_id"`
+	APIURL   string `json:"api_url,omitempty"` // 默认为 https://api.telegram.org
+}
+
+// SMTPSettings 邮件通知设置，端口为465时使用TLS连接，其余端口在服务器支持时使用STARTTLS
+type SMTPSettings struct {
+	Host     string   `json:"host"`
+	Port     int      `json:"port"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+}
+
+// v2接口的错误码
+const (
+	ErrCodeInvalidRequest   = "invalid_request"    // 请求格式或参数错误
+	ErrCodeUnauthorized     = "unauthorized"       // 未认证
+	ErrCodeForbidden        = "forbidden"          // 未启用认证时只允许本机访问
+	ErrCodeNotFound         = "not_found"          // 接口不存在
+	ErrCodeMethodNotAllowed = "method_not_allowed" // 接口不支持该请求方法
+	ErrCodeConfigNotFound   = "config_not_found"   // 配置文件不存在
+	ErrCodeConfigExists     = "config_exists"      // 配置文件已存在
+	ErrCodeConfigInUse      = "config_in_use"      // 配置文件正在使用
+	ErrCodeInvalidConfig    = "invalid_config"     // 配置内容无法解析
+	ErrCodeNoSource         = "no_source"          // 配置文件没有订阅源
+	ErrCodeFetchFailed      = "fetch_failed"       // 获取订阅失败
+	ErrCodeCoreStartFailed  = "core_start_failed"  // 启动内核失败
+	ErrCodeCoreStopFailed   = "core_stop_failed"   // 停止内核失败
+	ErrCodeInvalidBinary    = "invalid_binary"     // 上传的内核无法在当前系统运行
+	ErrCodeConfigTestFailed = "config_test_failed" // 内核无法通过当前配置的检查
+	ErrCodeNoPreviousBinary = "no_previous_binary" // 没有可以回滚的内核
+	ErrCodeInternal         = "internal_error"     // 其他内部错误
+)
+
+// ConfigSummary 配置文件概要
+type ConfigSummary struct {
+	Name        string `json:"name"` // 配置文件名
+	DisplayName string `json:"display_name"`
+	Source      string `json:"source,omitempty"` // 订阅URL
+	Type        string `json:"type,omitempty"`   // 聚合配置为aggregate
+	Current     bool   `json:"current"`          // 是否为当前使用的配置
+}
+
+// ConfigList 配置文件列表
+type ConfigList struct {
+	Items   []ConfigSummary `json:"items"`
+	Current string          `json:"current"`
+}
+
+// ConfigDetail 配置文件详情
+type ConfigDetail struct {
+	ConfigSummary
+	Content  string            `json:"content"` // 去掉元数据后的配置内容
+	Filter   *NodeFilter       `json:"filter,omitempty"`
+	Fetch    *FetchOptions     `json:"fetch,omitempty"`
+	Provider *ProviderOptions  `json:"provider,omitempty"`
+	Sources  []AggregateSource `json:"sources,omitempty"`
+	UserInfo string            `json:"userinfo,omitempty"` // 订阅流量信息
+}
+
+// CreateConfigRequest 添加配置文件请求，Sources不为空时生成聚合配置
+type CreateConfigRequest struct {
+	Name        string            `json:"name,omitempty"` // 配置文件名，为空时自动生成
+	DisplayName string            `json:"display_name,omitempty"`
+	URL         string            `json:"url,omitempty"`
+	RawConfig   string            `json:"raw_config,omitempty"` // 由前端获取的订阅内容
+	Filter      NodeFilter        `json:"filter,omitempty"`
+	Fetch       FetchOptions      `json:"fetch,omitempty"`
+	Provider    *ProviderOptions  `json:"provider,omitempty"`
+	Sources     []AggregateSource `json:"sources,omitempty"`
+}
+
+// ReplaceConfigRequest 替换配置内容请求，元数据保持不变
+type ReplaceConfigRequest struct {
+	Content string `json:"content"`
+}
+
+// PatchConfigRequest 修改配置元数据请求，未提供的字段保持不变
+// Filter和Fetch在下次更新订阅时生效
+type PatchConfigRequest struct {
+	DisplayName *string          `json:"display_name,omitempty"`
+	Filter      *NodeFilter      `json:"filter,omitempty"`
+	Fetch       *FetchOptions    `json:"fetch,omitempty"`
+	Provider    *ProviderOptions `json:"provider,omitempty"`
+}
+
+// RefreshConfigRequest 从订阅源更新配置请求，Filter和Fetch为空时沿用保存的设置
+type RefreshConfigRequest struct {
+	RawConfig string        `json:"raw_config,omitempty"`
+	Filter    *NodeFilter   `json:"filter,omitempty"`
+	Fetch     *FetchOptions `json:"fetch,omitempty"`
+}
+
+// ConfigChangeResult 修改配置的结果
+type ConfigChangeResult struct {
+	Config      ConfigSummary `json:"config"`
+	Restarted   bool          `json:"restarted"`    // 已重启内核
+	NeedRestart bool          `json:"need_restart"` // 需要重启内核才能生效
+}
+
+// CoreStatus 内核运行状态
+type CoreStatus struct {
+	Running bool   `json:"running"`
+	Config  string `json:"config"` // 当前使用的配置文件
+	PID     int    `json:"pid,omitempty"`
+	Uptime  int64  `json:"uptime"`            // 运行时间（秒）
+	Version string `json:"version,omitempty"` // 内核版本
+	Adopted bool   `json:"adopted,omitempty"` // 内核是启动时接管的已在运行的进程
+	// 内核未运行而控制器地址被其他程序占用时的说明，此时无法启动内核
+	Conflict string `json:"conflict,omitempty"`
+}
+
+// CoreBinary 内核可执行文件信息
+type CoreBinary struct {
+	Installed bool   `json:"installed"`
+	Path      string `json:"path"`
+	Version   string `json:"version,omitempty"`
+	Build     string `json:"build,omitempty"` // -v 输出的完整版本信息
+	OS        string `json:"os,omitempty"`
+	Arch      string `json:"arch,omitempty"`
+	Size      int64  `json:"size,omitempty"`
+	Modified  int64  `json:"modified,omitempty"` // 修改时间（Unix时间戳）
+	Error     string `json:"error,omitempty"`    // 无法使用的原因，如架构不匹配
+}
+
+// CoreBinaries 当前内核和可以回滚的上一个版本
+type CoreBinaries struct {
+	Current  CoreBinary  `json:"current"`
+	Previous *CoreBinary `json:"previous,omitempty"`
+}
+
+// CoreBinaryChangeResult 替换或回滚内核的结果
+type CoreBinaryChangeResult struct {
+	CoreBinaries
+	Restarted bool `json:"restarted"` // 内核正在运行，已使用新内核重启
+}
+
+// CoreLogs 内核最近的输出
+type CoreLogs struct {
+	Lines []string `json:"lines"`
+}
+
+// Settings 应用设置
+type Settings struct {
+	AutoStart bool `json:"auto_start"`
+}
+
+// PatchSettingsRequest 修改应用设置请求，未提供的字段保持不变
+type PatchSettingsRequest struct {
+	AutoStart *bool `json:"auto_start,omitempty"`
+}
@@ -0,0 +1,165 @@
+// Package notify 在订阅更新失败、流量不足、内核反复崩溃等情况下发送通知
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"clash-center/internal/config"
+	"clash-center/internal/events"
+	"clash-center/internal/models"
+)
+
+// 通知类型，除了直接转发的事件外，还包括根据事件判断得出的类型
+const (
+	TypeSubscriptionFailed  = events.SubscriptionFailed
+	TypeSubscriptionUpdated = events.SubscriptionUpdated
+	TypeCoreCrashed         = events.CoreCrashed
+	TypeQuotaLow            = "quota.low"
+	TypeCrashLoop           = "core.crashloop"
+	TypeTest                = "test"
+)
+
+// 各通知类型的默认启用状态
+var defaultEvents = map[string]bool{
+	TypeSubscriptionFailed:  true,
+	TypeQuotaLow:            true,
+	TypeCrashLoop:           true,
+	TypeSubscriptionUpdated: false,
+	TypeCoreCrashed:         false,
+}
+
+const (
+	defaultQuotaThreshold  = 10
+	defaultCrashLoopCount  = 3
+	defaultCrashLoopWindow = 300
+	sendTimeout            = 15 * time.Second
+)
+
+// 发送通知使用的HTTP客户端
+var httpClient = &http.Client{Timeout: sendTimeout}
+
+// Notification 通知内容
+type Notification struct {
+	Type    string         `json:"type"`
+	Title   string         `json:"title"`
+	Message string         `json:"message"`
+	Time    time.Time      `json:"time"`
+	Data    map[string]any `json:"data,omitempty"`
+}
+
+// Notifier 通知渠道
+type Notifier interface {
+	Name() string
+	Send(ctx context.Context, n Notification) error
+}
+
+// Result 单个渠道的发送结果
+type Result struct {
+	Notifier string `json:"notifier"`
+	Error    string `json:"error,omitempty"`
+}
+
+var startOnce sync.Once
+
+// Start 开始监听事件并按设置发送通知
+func Start() {
+	startOnce.Do(func() {
+		ch, _ := events.Subscribe(64, 0)
+		w := newWatcher()
+		go func() {
+			for event := range ch {
+				settings := config.LoadAppConfig().Notify
+				for _, n := range w.handle(event, settings) {
+					if Enabled(settings, n.Type) {
+						go Send(settings, n)
+					}
+				}
+			}
+		}()
+	})
+}
+
+// Enabled 判断通知类型是否启用
+func Enabled(settings models.NotifySettings, notificationType string) bool {
+	if enabled, ok := settings.Events[notificationType]; ok {
+		return enabled
+	}
+	return defaultEvents[notificationType]
+}
+
+// Notifiers 根据设置创建通知渠道
+func Notifiers(settings models.NotifySettings) []Notifier {
+	var notifiers []Notifier
+	if settings.Webhook != nil && settings.Webhook.URL != "" {
+		notifiers = append(notifiers, &Webhook{Settings: *settings.Webhook})
+	}
+	if settings.Telegram != nil && settings.Telegram.BotToken != "" {
+		notifiers = append(notifiers, &Telegram{Settings: *settings.Telegram})
+	}
+	if settings.SMTP != nil && settings.SMTP.Host != "" {
+		notifiers = append(notifiers, &SMTP{Settings: *settings.SMTP})
+	}
+	return notifiers
+}
+
+// Send 通过所有已配置的渠道发送通知，返回每个渠道的结果
+func Send(settings models.NotifySettings, n Notification) []Result {
+	if n.Time.IsZero() {
+		n.Time = time.Now()
+	}
+
+	notifiers := Notifiers(settings)
+	results := make([]Result, len(notifiers))
+
+	var wg sync.WaitGroup
+	for i, notifier := range notifiers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+			defer cancel()
+
+			results[i] = Result{Notifier: notifier.Name()}
+			if err := notifier.Send(ctx, n); err != nil {
+				log.Printf("通过%s发送通知失败: %v", notifier.Name(), err)
+				results[i].Error = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+// ValidateSettings 检查通知设置，包括模板语法
+func ValidateSettings(settings models.NotifySettings) error {
+	if settings.QuotaThreshold < 0 || settings.QuotaThreshold > 100 {
+		return errors.New("流量阈值应在0到100之间")
+	}
+	if settings.CrashLoopCount < 0 || settings.CrashLoopWindow < 0 {
+		return errors.New("崩溃检测参数不能为负数")
+	}
+	if webhook := settings.Webhook; webhook != nil && webhook.URL != "" {
+		if _, err := parseTemplate("body", webhook.Body); err != nil {
+			return fmt.Errorf("Webhook模板错误: %v", err)
+		}
+		for key, value := range webhook.Headers {
+			if _, err := parseTemplate(key, value); err != nil {
+				return fmt.Errorf("Webhook请求头 %s 模板错误: %v", key, err)
+			}
+		}
+	}
+	if telegram := settings.Telegram; telegram != nil && telegram.BotToken != "" && telegram.ChatID == "" {
+		return errors.New("Telegram通知需要设置chat_id")
+	}
+	if smtp := settings.SMTP; smtp != nil && smtp.Host != "" && (smtp.From == "" || len(smtp.To) == 0) {
+		return errors.New("邮件通知需要设置发件人和收件人")
+	}
+	return nil
+}
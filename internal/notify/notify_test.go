@@ -0,0 +1,164 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"clash-center/internal/models"
+)
+
+// 记录收到的请求
+type capturedRequest struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   string
+}
+
+func newCaptureServer(t *testing.T, status int) (*httptest.Server, chan capturedRequest) {
+	t.Helper()
+	requests := make(chan capturedRequest, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- capturedRequest{Method: r.Method, Path: r.URL.Path, Header: r.Header.Clone(), Body: string(body)}
+		w.WriteHeader(status)
+		io.WriteString(w, "response body")
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+var testNotification = Notification{
+	Type:    TypeSubscriptionFailed,
+	Title:   "订阅更新失败",
+	Message: `配置 "x.yaml" 更新失败`,
+	Time:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	Data:    map[string]any{"config": "x.yaml"},
+}
+
+func TestWebhookDefaultBody(t *testing.T) {
+	server, requests := newCaptureServer(t, http.StatusOK)
+	webhook := &Webhook{Settings: models.WebhookSettings{URL: server.URL + "/hook"}}
+
+	if err := webhook.Send(context.Background(), testNotification); err != nil {
+		t.Fatal(err)
+	}
+	req := <-requests
+	if req.Method != http.MethodPost || req.Path != "/hook" || req.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("请求为 %+v", req)
+	}
+	var body Notification
+	if err := json.Unmarshal([]byte(req.Body), &body); err != nil || body.Type != TypeSubscriptionFailed || body.Data["config"] != "x.yaml" {
+		t.Errorf("请求内容为 %s: %v", req.Body, err)
+	}
+}
+
+func TestWebhookTemplate(t *testing.T) {
+	server, requests := newCaptureServer(t, http.StatusNoContent)
+	webhook := &Webhook{Settings: models.WebhookSettings{
+		URL:     server.URL,
+		Method:  "put",
+		Headers: map[string]string{"X-Event": "{{.Type}}", "Authorization": "Bearer abc"},
+		Body:    `{"text":{{json .Message}},"config":"{{.Data.config}}"}`,
+	}}
+
+	if err := webhook.Send(context.Background(), testNotification); err != nil {
+		t.Fatal(err)
+	}
+	req := <-requests
+	if req.Method != http.MethodPut {
+		t.Errorf("请求方法为 %s", req.Method)
+	}
+	if req.Header.Get("X-Event") != TypeSubscriptionFailed || req.Header.Get("Authorization") != "Bearer abc" {
+		t.Errorf("请求头为 %v", req.Header)
+	}
+	var body map[string]string
+	if err := json.Unmarshal([]byte(req.Body), &body); err != nil {
+		t.Fatalf("模板输出不是有效的JSON: %s", req.Body)
+	}
+	if body["text"] != testNotification.Message || body["config"] != "x.yaml" {
+		t.Errorf("请求内容为 %v", body)
+	}
+}
+
+func TestWebhookErrorStatus(t *testing.T) {
+	server, _ := newCaptureServer(t, http.StatusBadRequest)
+	webhook := &Webhook{Settings: models.WebhookSettings{URL: server.URL}}
+
+	err := webhook.Send(context.Background(), testNotification)
+	if err == nil || !strings.Contains(err.Error(), "400") || !strings.Contains(err.Error(), "response body") {
+		t.Fatalf("错误为 %v", err)
+	}
+}
+
+func TestTelegram(t *testing.T) {
+	server, requests := newCaptureServer(t, http.StatusOK)
+	telegram := &Telegram{Settings: models.TelegramSettings{BotToken: "123:abc", ChatID: "42", APIURL: server.URL + "/"}}
+
+	if err := telegram.Send(context.Background(), testNotification); err != nil {
+		t.Fatal(err)
+	}
+	req := <-requests
+	if req.Path != "/bot123:abc/sendMessage" {
+		t.Errorf("请求路径为 %s", req.Path)
+	}
+	var body map[string]any
+	json.Unmarshal([]byte(req.Body), &body)
+	if body["chat_id"] != "42" || !strings.HasPrefix(body["text"].(string), testNotification.Title+"\n") {
+		t.Errorf("请求内容为 %v", body)
+	}
+}
+
+func TestTelegramErrorHidesToken(t *testing.T) {
+	// 关闭的服务器，错误信息中会包含请求地址
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	telegram := &Telegram{Settings: models.TelegramSettings{BotToken: "123:secret", ChatID: "42", APIURL: server.URL}}
+
+	err := telegram.Send(context.Background(), testNotification)
+	if err == nil {
+		t.Fatal("服务器不可用时应该失败")
+	}
+	if strings.Contains(err.Error(), "123:secret") {
+		t.Fatalf("错误信息中包含机器人令牌: %v", err)
+	}
+}
+
+func TestSendResults(t *testing.T) {
+	ok, _ := newCaptureServer(t, http.StatusOK)
+	failing, _ := newCaptureServer(t, http.StatusInternalServerError)
+	settings := models.NotifySettings{
+		Webhook:  &models.WebhookSettings{URL: ok.URL},
+		Telegram: &models.TelegramSettings{BotToken: "1:a", ChatID: "1", APIURL: failing.URL},
+	}
+
+	results := Send(settings, testNotification)
+	if len(results) != 2 || results[0].Notifier != "webhook" || results[0].Error != "" ||
+		results[1].Notifier != "telegram" || results[1].Error == "" {
+		t.Fatalf("结果为 %+v", results)
+	}
+}
+
+func TestValidateSettings(t *testing.T) {
+	tests := []struct {
+		settings models.NotifySettings
+		valid    bool
+	}{
+		{models.NotifySettings{}, true},
+		{models.NotifySettings{QuotaThreshold: 120}, false},
+		{models.NotifySettings{Webhook: &models.WebhookSettings{URL: "http://x", Body: "{{.Type"}}, false},
+		{models.NotifySettings{Telegram: &models.TelegramSettings{BotToken: "1:a"}}, false},
+		{models.NotifySettings{SMTP: &models.SMTPSettings{Host: "smtp.example.test"}}, false},
+	}
+	for i, tt := range tests {
+		if err := ValidateSettings(tt.settings); (err == nil) != tt.valid {
+			t.Errorf("第%d个设置的检查结果为 %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"clash-center/internal/models"
+)
+
+// SMTP 邮件通知
+type SMTP struct {
+	Settings models.SMTPSettings
+}
+
+func (s *SMTP) Name() string {
+	return "smtp"
+}
+
+func (s *SMTP) Send(ctx context.Context, n Notification) error {
+	port := s.Settings.Port
+	if port == 0 {
+		port = 587
+	}
+	addr := net.JoinHostPort(s.Settings.Host, strconv.Itoa(port))
+
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("连接邮件服务器失败: %v", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	// 465端口使用隐式TLS
+	if port == 465 {
+		conn = tls.Client(conn, &tls.Config{ServerName: s.Settings.Host})
+	}
+
+	client, err := smtp.NewClient(conn, s.Settings.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("连接邮件服务器失败: %v", err)
+	}
+	defer client.Close()
+
+	if port != 465 {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(&tls.Config{ServerName: s.Settings.Host}); err != nil {
+				return fmt.Errorf("STARTTLS失败: %v", err)
+			}
+		}
+	}
+
+	if s.Settings.Username != "" {
+		auth := smtp.PlainAuth("", s.Settings.Username, s.Settings.Password, s.Settings.Host)
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("邮件服务器认证失败: %v", err)
+		}
+	}
+
+	if err := client.Mail(s.Settings.From); err != nil {
+		return fmt.Errorf("设置发件人失败: %v", err)
+	}
+	for _, to := range s.Settings.To {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("设置收件人 %s 失败: %v", to, err)
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("发送邮件失败: %v", err)
+	}
+	if _, err := writer.Write(s.buildMessage(n)); err != nil {
+		writer.Close()
+		return fmt.Errorf("发送邮件失败: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("发送邮件失败: %v", err)
+	}
+
+	return client.Quit()
+}
+
+// 生成邮件内容
+func (s *SMTP) buildMessage(n Notification) []byte {
+	var builder strings.Builder
+	builder.WriteString("From: " + s.Settings.From + "\r\n")
+	builder.WriteString("To: " + strings.Join(s.Settings.To, ", ") + "\r\n")
+	builder.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", "[clash-center] "+n.Title) + "\r\n")
+	builder.WriteString("Date: " + n.Time.Format(time.RFC1123Z) + "\r\n")
+	builder.WriteString("MIME-Version: 1.0\r\n")
+	builder.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	builder.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	builder.WriteString("\r\n")
+	builder.WriteString(strings.ReplaceAll(n.Message, "\n", "\r\n"))
+	builder.WriteString("\r\n")
+	return []byte(builder.String())
+}
@@ -0,0 +1,50 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"clash-center/internal/models"
+)
+
+const defaultTelegramAPI = "https://api.telegram.org"
+
+// Telegram Telegram机器人通知
+type Telegram struct {
+	Settings models.TelegramSettings
+}
+
+func (t *Telegram) Name() string {
+	return "telegram"
+}
+
+func (t *Telegram) Send(ctx context.Context, n Notification) error {
+	apiURL := strings.TrimSuffix(t.Settings.APIURL, "/")
+	if apiURL == "" {
+		apiURL = defaultTelegramAPI
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"chat_id": t.Settings.ChatID,
+		"text":    n.Title + "\n" + n.Message,
+	})
+	if err != nil {
+		return fmt.Errorf("编码消息失败: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL+"/bot"+t.Settings.BotToken+"/sendMessage", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// 错误信息中不能包含机器人令牌
+	if err := doRequest(req); err != nil {
+		return fmt.Errorf("发送Telegram消息失败: %s", strings.ReplaceAll(err.Error(), t.Settings.BotToken, "***"))
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package notify
+
+import (
+	"fmt"
+	"time"
+
+	"clash-center/internal/events"
+	"clash-center/internal/models"
+	"clash-center/pkg/converter"
+)
+
+// 根据事件判断需要发送的通知
+type watcher struct {
+	// 已经提醒过流量不足的配置，流量恢复后才会再次提醒
+	quotaNotified map[string]bool
+	// 最近的意外退出时间
+	crashes []time.Time
+	// 上次发送反复崩溃通知的时间
+	lastCrashLoop time.Time
+}
+
+func newWatcher() *watcher {
+	return &watcher{quotaNotified: make(map[string]bool)}
+}
+
+// 处理一个事件，返回需要发送的通知
+func (w *watcher) handle(event events.Event, settings models.NotifySettings) []Notification {
+	configName := eventConfigName(event)
+
+	switch event.Type {
+	case events.SubscriptionFailed:
+		return []Notification{{
+			Type:    TypeSubscriptionFailed,
+			Title:   "订阅更新失败",
+			Message: fmt.Sprintf("配置 %s 更新订阅失败: %v", configName, event.Data["error"]),
+			Time:    event.Time,
+			Data:    event.Data,
+		}}
+
+	case events.SubscriptionUpdated:
+		notifications := []Notification{{
+			Type:    TypeSubscriptionUpdated,
+			Title:   "订阅已更新",
+			Message: fmt.Sprintf("配置 %s 已更新，共 %v 个节点", configName, event.Data["nodes"]),
+			Time:    event.Time,
+			Data:    event.Data,
+		}}
+		if n, ok := w.checkQuota(event, configName, settings); ok {
+			notifications = append(notifications, n)
+		}
+		return notifications
+
+	case events.CoreCrashed:
+		notifications := []Notification{{
+			Type:    TypeCoreCrashed,
+			Title:   "Clash内核意外退出",
+			Message: fmt.Sprintf("Clash内核意外退出: %v", event.Data["error"]),
+			Time:    event.Time,
+			Data:    event.Data,
+		}}
+		if n, ok := w.checkCrashLoop(event, settings); ok {
+			notifications = append(notifications, n)
+		}
+		return notifications
+	}
+
+	return nil
+}
+
+// 检查订阅剩余流量是否低于阈值
+func (w *watcher) checkQuota(event events.Event, configName string, settings models.NotifySettings) (Notification, bool) {
+	value, _ := event.Data["userinfo"].(string)
+	info, ok := converter.ParseUserInfo(value)
+	if !ok || info.Total <= 0 {
+		return Notification{}, false
+	}
+
+	threshold := settings.QuotaThreshold
+	if threshold <= 0 {
+		threshold = defaultQuotaThreshold
+	}
+
+	file, _ := event.Data["config"].(string)
+	remaining := info.Remaining()
+	percent := float64(remaining) / float64(info.Total) * 100
+	if percent >= threshold {
+		delete(w.quotaNotified, file)
+		return Notification{}, false
+	}
+	if w.quotaNotified[file] {
+		return Notification{}, false
+	}
+	w.quotaNotified[file] = true
+
+	return Notification{
+		Type:    TypeQuotaLow,
+		Title:   "订阅流量不足",
+		Message: fmt.Sprintf("配置 %s 剩余流量 %s（%.1f%%），低于 %.0f%%", configName, formatBytes(remaining), percent, threshold),
+		Time:    event.Time,
+		Data: map[string]any{
+			"config":    file,
+			"name":      configName,
+			"remaining": remaining,
+			"total":     info.Total,
+			"percent":   percent,
+		},
+	}, true
+}
+
+// 检查内核是否在短时间内反复崩溃
+func (w *watcher) checkCrashLoop(event events.Event, settings models.NotifySettings) (Notification, bool) {
+	count := settings.CrashLoopCount
+	if count <= 0 {
+		count = defaultCrashLoopCount
+	}
+	window := time.Duration(settings.CrashLoopWindow) * time.Second
+	if window <= 0 {
+		window = defaultCrashLoopWindow * time.Second
+	}
+
+	// 只保留时间窗口内的记录
+	cutoff := event.Time.Add(-window)
+	crashes := w.crashes[:0]
+	for _, t := range w.crashes {
+		if t.After(cutoff) {
+			crashes = append(crashes, t)
+		}
+	}
+	w.crashes = append(crashes, event.Time)
+
+	// 同一个时间窗口内只提醒一次
+	if len(w.crashes) < count || w.lastCrashLoop.After(cutoff) {
+		return Notification{}, false
+	}
+	w.lastCrashLoop = event.Time
+
+	return Notification{
+		Type:    TypeCrashLoop,
+		Title:   "Clash内核反复崩溃",
+		Message: fmt.Sprintf("Clash内核在 %s 内意外退出了 %d 次，最近一次: %v", window, len(w.crashes), event.Data["error"]),
+		Time:    event.Time,
+		Data: map[string]any{
+			"config":  event.Data["config"],
+			"crashes": len(w.crashes),
+			"window":  window.Seconds(),
+		},
+	}, true
+}
+
+// 事件对应配置的显示名称
+func eventConfigName(event events.Event) string {
+	if name, ok := event.Data["name"].(string); ok && name != "" {
+		return name
+	}
+	name, _ := event.Data["config"].(string)
+	return name
+}
+
+// 格式化字节数
+func formatBytes(size int64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	value := float64(size)
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	return fmt.Sprintf("%.2f %s", value, units[unit])
+}
@@ -0,0 +1,106 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"clash-center/internal/models"
+)
+
+// Webhook 通用Webhook通知
+type Webhook struct {
+	Settings models.WebhookSettings
+}
+
+func (w *Webhook) Name() string {
+	return "webhook"
+}
+
+// 模板中可以使用 {{json .Message}} 输出转义后的JSON字符串
+var templateFuncs = template.FuncMap{
+	"json": func(value any) (string, error) {
+		content, err := json.Marshal(value)
+		return string(content), err
+	},
+}
+
+// 解析模板，内容为空时返回nil
+func parseTemplate(name, text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	return template.New(name).Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+}
+
+// 使用通知内容渲染模板
+func renderTemplate(name, text string, n Notification) (string, error) {
+	tmpl, err := parseTemplate(name, text)
+	if err != nil || tmpl == nil {
+		return text, err
+	}
+
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, n); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func (w *Webhook) Send(ctx context.Context, n Notification) error {
+	var body []byte
+	if w.Settings.Body == "" {
+		content, err := json.Marshal(n)
+		if err != nil {
+			return fmt.Errorf("编码通知失败: %v", err)
+		}
+		body = content
+	} else {
+		content, err := renderTemplate("body", w.Settings.Body, n)
+		if err != nil {
+			return fmt.Errorf("渲染模板失败: %v", err)
+		}
+		body = []byte(content)
+	}
+
+	method := strings.ToUpper(w.Settings.Method)
+	if method == "" {
+		method = http.MethodPost
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, w.Settings.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range w.Settings.Headers {
+		rendered, err := renderTemplate(key, value, n)
+		if err != nil {
+			return fmt.Errorf("渲染请求头 %s 失败: %v", key, err)
+		}
+		req.Header.Set(key, rendered)
+	}
+
+	return doRequest(req)
+}
+
+// 发送请求并检查状态码
+func doRequest(req *http.Request) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		content, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("返回错误状态码 %d: %s", resp.StatusCode, strings.TrimSpace(string(content)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
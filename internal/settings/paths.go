@@ -0,0 +1,181 @@
+package settings
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"clash-center/frontend"
+	"clash-center/internal/audit"
+	"clash-center/internal/clash"
+	"clash-center/internal/config"
+)
+
+// ResolvePaths 将数据目录转换为绝对路径，并为未单独设置的路径填入数据目录下的默认位置
+// 单独设置的相对路径以数据目录为准，不受工作目录影响
+// 内核和合并后的配置默认位于Clash主目录中，修改Clash主目录时随之变化
+func (s *Settings) ResolvePaths() error {
+	if s.DataDir == "" {
+		s.DataDir = "."
+	}
+	dataDir, err := filepath.Abs(s.DataDir)
+	if err != nil {
+		return fmt.Errorf("无法确定数据目录 %s 的绝对路径: %v", s.DataDir, err)
+	}
+	s.DataDir = dataDir
+
+	resolve := func(path *string) {
+		if *path != "" && !filepath.IsAbs(*path) {
+			*path = filepath.Join(dataDir, *path)
+		}
+	}
+	for _, path := range []*string{
+		&s.Paths.ConfigDir, &s.Paths.ClashHome, &s.Paths.MergedConfig, &s.Paths.DefaultConfig,
+		&s.Paths.AppConfig, &s.Paths.RuleSetDir, &s.Paths.AuditLog, &s.Paths.Frontend,
+		&s.TLS.Cert, &s.TLS.Key, &s.Listen.Socket,
+	} {
+		resolve(path)
+	}
+	// 不含目录的内核名称在PATH中查找
+	if strings.ContainsRune(s.Core.Path, '/') || strings.ContainsRune(s.Core.Path, filepath.Separator) {
+		resolve(&s.Core.Path)
+	}
+
+	setDefault := func(path *string, elem ...string) {
+		if strings.TrimSpace(*path) == "" {
+			*path = filepath.Join(elem...)
+		}
+	}
+	setDefault(&s.Paths.ConfigDir, dataDir, "configs")
+	setDefault(&s.Paths.ClashHome, dataDir, "clash")
+	setDefault(&s.Paths.MergedConfig, s.Paths.ClashHome, "config.yaml")
+	setDefault(&s.Core.Path, s.Paths.ClashHome, "clash.meta")
+	setDefault(&s.Paths.DefaultConfig, dataDir, "default.yaml")
+	setDefault(&s.Paths.AppConfig, dataDir, "app_config.json")
+	setDefault(&s.Paths.RuleSetDir, dataDir, "rulesets")
+	setDefault(&s.Paths.AuditLog, dataDir, "audit.jsonl")
+	// 自签名证书默认保存在数据目录中，重启后继续使用
+	if s.TLS.SelfSigned && s.TLS.Cert == "" && s.TLS.Key == "" {
+		s.TLS.Cert = filepath.Join(dataDir, "tls", "cert.pem")
+		s.TLS.Key = filepath.Join(dataDir, "tls", "key.pem")
+	}
+
+	// 嵌入了前端时默认使用嵌入的文件
+	if !frontend.Embedded {
+		setDefault(&s.Paths.Frontend, dataDir, "frontend", "dist")
+	}
+	return nil
+}
+
+// ApplyPaths 将路径设置应用到各个包
+func (s Settings) ApplyPaths() {
+	config.ConfigDir = s.Paths.ConfigDir
+	config.MergedConfigPath = s.Paths.MergedConfig
+	config.DefaultConfigPath = s.Paths.DefaultConfig
+	config.AppConfigPath = s.Paths.AppConfig
+	config.RuleSetDir = s.Paths.RuleSetDir
+	audit.LogPath = s.Paths.AuditLog
+	clash.ClashHome = s.Paths.ClashHome
+	clash.ClashPath = s.Core.Path
+}
+
+// PreparePaths 创建缺少的目录并检查各个路径的读写权限
+// 无法使用的路径作为错误返回，不影响启动的问题（如前端或内核尚未安装）作为警告返回
+func (s Settings) PreparePaths() ([]string, error) {
+	var problems, warnings []string
+
+	// 需要读写的目录，不存在时创建
+	dirs := []struct{ name, path string }{
+		{"data_dir", s.DataDir},
+		{"paths.config_dir", s.Paths.ConfigDir},
+		{"paths.clash_home", s.Paths.ClashHome},
+		{"paths.ruleset_dir", s.Paths.RuleSetDir},
+		{"paths.merged_config 所在目录", filepath.Dir(s.Paths.MergedConfig)},
+		{"paths.app_config 所在目录", filepath.Dir(s.Paths.AppConfig)},
+		{"paths.audit_log 所在目录", filepath.Dir(s.Paths.AuditLog)},
+	}
+	if s.Listen.Socket != "" {
+		dirs = append(dirs, struct{ name, path string }{"listen.socket 所在目录", filepath.Dir(s.Listen.Socket)})
+	}
+	checked := make(map[string]bool)
+	for _, dir := range dirs {
+		if checked[dir.path] {
+			continue
+		}
+		checked[dir.path] = true
+		if err := prepareDir(dir.path); err != nil {
+			problems = append(problems, fmt.Sprintf("%s %s: %v", dir.name, dir.path, err))
+		}
+	}
+
+	// 需要读写的文件，不存在时由程序创建
+	for _, file := range []struct{ name, path string }{
+		{"paths.app_config", s.Paths.AppConfig},
+		{"paths.audit_log", s.Paths.AuditLog},
+	} {
+		if err := checkFile(file.path, os.O_RDWR); err != nil {
+			problems = append(problems, fmt.Sprintf("%s %s: %v", file.name, file.path, err))
+		}
+	}
+
+	// 只读的文件，不存在时跳过
+	if err := checkFile(s.Paths.DefaultConfig, os.O_RDONLY); err != nil {
+		problems = append(problems, fmt.Sprintf("paths.default_config %s: %v", s.Paths.DefaultConfig, err))
+	}
+
+	// 为空时使用嵌入的前端
+	if s.Paths.Frontend != "" {
+		if info, err := os.Stat(s.Paths.Frontend); err != nil || !info.IsDir() {
+			warnings = append(warnings, fmt.Sprintf("前端目录 %s 不存在，网页界面不可用", s.Paths.Frontend))
+		}
+	}
+
+	// 内核可以稍后安装，不含路径的名称在PATH中查找
+	info, err := os.Stat(s.Core.Path)
+	switch {
+	case err == nil && info.IsDir():
+		problems = append(problems, fmt.Sprintf("core.path %s 是目录而不是可执行文件", s.Core.Path))
+	case err == nil && runtime.GOOS != "windows" && info.Mode()&0111 == 0:
+		warnings = append(warnings, fmt.Sprintf("内核 %s 没有执行权限", s.Core.Path))
+	case err != nil && filepath.Base(s.Core.Path) != s.Core.Path:
+		warnings = append(warnings, fmt.Sprintf("内核 %s 不存在，需要安装后才能启动", s.Core.Path))
+	}
+
+	if len(problems) > 0 {
+		return warnings, fmt.Errorf("以下路径无法使用:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+	return warnings, nil
+}
+
+// 创建目录并检查是否可以读取和写入
+func prepareDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("创建目录失败: %v", err)
+	}
+	if _, err := os.ReadDir(dir); err != nil {
+		return fmt.Errorf("无法读取: %v", err)
+	}
+
+	file, err := os.CreateTemp(dir, ".clash-center-check-*")
+	if err != nil {
+		return fmt.Errorf("无法写入: %v", err)
+	}
+	file.Close()
+	os.Remove(file.Name())
+	return nil
+}
+
+// 检查已存在的文件能否以指定方式打开
+func checkFile(path string, flag int) error {
+	file, err := os.OpenFile(path, flag, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
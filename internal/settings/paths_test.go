@@ -0,0 +1,62 @@
+package settings
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolvePathsRelativeToDataDir(t *testing.T) {
+	dataDir := t.TempDir()
+	s := Default()
+	s.DataDir = dataDir
+	s.Paths.ConfigDir = "subs"
+	s.Paths.ClashHome = "core"
+	s.Paths.AuditLog = "/var/log/audit.jsonl"
+	s.Core.Path = "mihomo"
+	if err := s.ResolvePaths(); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, got := range map[string][2]string{
+		"config_dir":    {s.Paths.ConfigDir, filepath.Join(dataDir, "subs")},
+		"clash_home":    {s.Paths.ClashHome, filepath.Join(dataDir, "core")},
+		"merged_config": {s.Paths.MergedConfig, filepath.Join(dataDir, "core", "config.yaml")},
+		"audit_log":     {s.Paths.AuditLog, "/var/log/audit.jsonl"},
+		"core.path":     {s.Core.Path, "mihomo"},
+	} {
+		if got[0] != got[1] {
+			t.Errorf("%s 为 %s，应为 %s", name, got[0], got[1])
+		}
+	}
+
+	s = Default()
+	s.DataDir = dataDir
+	s.Core.Path = filepath.Join("bin", "mihomo")
+	if err := s.ResolvePaths(); err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dataDir, "bin", "mihomo"); s.Core.Path != want {
+		t.Errorf("core.path 为 %s，应为 %s", s.Core.Path, want)
+	}
+}
+
+func TestLoadFromDataDir(t *testing.T) {
+	dataDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dataDir, DefaultFileName), []byte("listen:\n  port: 9000\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := Load("", dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Listen.Port != 9000 {
+		t.Errorf("没有读取数据目录中的设置文件，端口为 %d", s.Listen.Port)
+	}
+
+	t.Setenv(EnvPrefix+"DATA_DIR", dataDir)
+	if got := DefaultPath(""); got != filepath.Join(dataDir, DefaultFileName) {
+		t.Errorf("默认设置文件为 %s", got)
+	}
+}
@@ -0,0 +1,412 @@
+// Package settings 读取clash-center自身的设置，优先级为 命令行参数 > 环境变量 > 设置文件 > 默认值
+package settings
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"clash-center/internal/clash"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 默认的设置文件名，位于数据目录中，文件不存在时使用默认设置
+const DefaultFileName = "clash-center.yaml"
+
+// 环境变量前缀
+const EnvPrefix = "CLASH_CENTER_"
+
+// Settings clash-center的设置
+type Settings struct {
+	Listen    Listen    `yaml:"listen"`
+	DataDir   string    `yaml:"data_dir"` // 数据目录，未单独设置的路径都位于其中
+	Paths     Paths     `yaml:"paths"`
+	Core      Core      `yaml:"core"`
+	Auth      Auth      `yaml:"auth"`
+	Scheduler Scheduler `yaml:"scheduler"`
+	CORS      CORS      `yaml:"cors"`
+	TLS       TLS       `yaml:"tls"`
+	Shutdown  Shutdown  `yaml:"shutdown"`
+	Verbose   bool      `yaml:"verbose"`
+}
+
+// Listen 服务器监听地址
+type Listen struct {
+	Host       string `yaml:"host"`
+	Port       int    `yaml:"port"`        // 0表示不监听TCP端口，只使用Unix套接字
+	Socket     string `yaml:"socket"`      // Unix套接字路径，为空时不监听
+	SocketMode string `yaml:"socket_mode"` // Unix套接字的文件权限，八进制，如 0660
+}
+
+// TCPEnabled 是否监听TCP端口
+func (l Listen) TCPEnabled() bool {
+	return l.Port != 0
+}
+
+// FileMode 解析套接字的文件权限
+func (l Listen) FileMode() (os.FileMode, error) {
+	mode, err := strconv.ParseUint(l.SocketMode, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("%q 不是有效的八进制文件权限，例如 0660", l.SocketMode)
+	}
+	return os.FileMode(mode), nil
+}
+
+// LocalAddress 本机访问服务器使用的地址，监听所有地址时使用回环地址
+func (l Listen) LocalAddress() string {
+	host := l.Host
+	switch host {
+	case "", "0.0.0.0":
+		host = "127.0.0.1"
+	case "::", "[::]":
+		host = "::1"
+	}
+	return net.JoinHostPort(host, strconv.Itoa(l.Port))
+}
+
+// Paths 文件和目录路径，为空时根据数据目录确定
+type Paths struct {
+	ConfigDir     string `yaml:"config_dir"`     // 配置文件目录
+	ClashHome     string `yaml:"clash_home"`     // Clash主目录
+	MergedConfig  string `yaml:"merged_config"`  // 合并后的配置文件
+	DefaultConfig string `yaml:"default_config"` // 默认配置文件
+	AppConfig     string `yaml:"app_config"`     // 应用程序配置文件
+	RuleSetDir    string `yaml:"ruleset_dir"`    // 共享规则集目录
+	AuditLog      string `yaml:"audit_log"`      // 审计日志文件
+	Frontend      string `yaml:"frontend_dir"`   // 前端文件目录
+}
+
+// Core 内核设置
+type Core struct {
+	Path         string   `yaml:"path"`         // 内核可执行文件，为空时位于Clash主目录中
+	User         string   `yaml:"user"`         // 运行内核的用户，为空时与clash-center相同，仅支持Linux
+	Group        string   `yaml:"group"`        // 运行内核的用户组，为空时使用该用户的主组
+	Capabilities []string `yaml:"capabilities"` // 设置user时授予内核的capabilities
+	NoFile       uint64   `yaml:"nofile"`       // 内核的打开文件数限制，0表示不修改，仅支持Linux
+}
+
+// Auth 访问认证设置
+type Auth struct {
+	Users map[string]string `yaml:"users"` // 用户名 -> 密码
+	Token string            `yaml:"token"` // API访问令牌
+}
+
+// Scheduler 定时任务设置
+type Scheduler struct {
+	UpdateInterval time.Duration `yaml:"update_interval"` // 自动更新订阅的间隔，0表示不自动更新
+}
+
+// TLS HTTPS设置
+type TLS struct {
+	Cert       string `yaml:"cert"`        // 证书文件
+	Key        string `yaml:"key"`         // 私钥文件
+	SelfSigned bool   `yaml:"self_signed"` // 证书不存在时生成自签名证书并保存
+	Redirect   string `yaml:"redirect"`    // 监听该地址并将HTTP请求重定向到HTTPS，如 :80
+	CoreListen string `yaml:"core_listen"` // 供内核获取provider和规则集的本机HTTP地址，默认为 127.0.0.1:<端口+1>
+}
+
+// Enabled 是否启用HTTPS
+func (t TLS) Enabled() bool {
+	return t.Cert != "" || t.SelfSigned
+}
+
+// CoreListenAddress 启用HTTPS时供内核访问的本机HTTP地址，内核不信任自签名证书，也无法验证签发给域名的证书
+// 未启用HTTPS或不监听TCP端口时返回空字符串
+func (s Settings) CoreListenAddress() string {
+	if !s.TLS.Enabled() || !s.Listen.TCPEnabled() {
+		return ""
+	}
+	if s.TLS.CoreListen != "" {
+		return s.TLS.CoreListen
+	}
+	return net.JoinHostPort("127.0.0.1", strconv.Itoa(s.Listen.Port+1))
+}
+
+// Shutdown 退出时的行为
+type Shutdown struct {
+	StopCore bool          `yaml:"stop_core"` // 退出时停止内核，为false时内核继续运行
+	Timeout  time.Duration `yaml:"timeout"`   // 等待正在处理的请求完成的最长时间
+}
+
+// CORS 跨域设置
+type CORS struct {
+	AllowedOrigins   []string `yaml:"allowed_origins"`
+	AllowCredentials bool     `yaml:"allow_credentials"`
+	MaxAge           int      `yaml:"max_age"` // 预检请求的缓存时间（秒）
+}
+
+// 自动更新订阅的最小间隔
+const minUpdateInterval = 5 * time.Minute
+
+// Default 返回默认设置
+func Default() Settings {
+	return Settings{
+		Listen:  Listen{Host: "0.0.0.0", Port: 7788, SocketMode: "0660"},
+		DataDir: ".",
+		Auth:    Auth{Users: map[string]string{}},
+		CORS:    CORS{MaxAge: 300},
+		Core: Core{
+			Capabilities: []string{"CAP_NET_ADMIN", "CAP_NET_BIND_SERVICE"},
+		},
+		Shutdown: Shutdown{StopCore: true, Timeout: 10 * time.Second},
+	}
+}
+
+// Load 在默认设置的基础上依次应用设置文件和环境变量
+// path为空时使用数据目录中的默认文件，默认文件不存在时忽略，明确指定的文件不存在时返回错误
+func Load(path, dataDir string) (Settings, error) {
+	s := Default()
+
+	explicit := path != ""
+	if !explicit {
+		path = DefaultPath(dataDir)
+	}
+	if err := s.loadFile(path, explicit); err != nil {
+		return s, err
+	}
+	if err := s.applyEnv(); err != nil {
+		return s, err
+	}
+	return s, nil
+}
+
+// DefaultPath 默认的设置文件路径，dataDir为空时依次使用 CLASH_CENTER_DATA_DIR 和默认数据目录
+func DefaultPath(dataDir string) string {
+	if dataDir == "" {
+		dataDir = os.Getenv(EnvPrefix + "DATA_DIR")
+	}
+	if dataDir == "" {
+		dataDir = Default().DataDir
+	}
+	return filepath.Join(dataDir, DefaultFileName)
+}
+
+// 读取YAML设置文件，文件中未出现的字段保持原值
+func (s *Settings) loadFile(path string, required bool) error {
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) && !required {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取设置文件失败: %v", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(s); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("解析设置文件 %s 失败: %v", path, err)
+	}
+	return nil
+}
+
+// 环境变量与设置项的对应关系
+var envSettings = []struct {
+	name  string
+	apply func(s *Settings, value string) error
+}{
+	{"HOST", func(s *Settings, v string) error { s.Listen.Host = v; return nil }},
+	{"PORT", func(s *Settings, v string) error { return parseInt(v, &s.Listen.Port) }},
+	{"SOCKET", func(s *Settings, v string) error { s.Listen.Socket = v; return nil }},
+	{"SOCKET_MODE", func(s *Settings, v string) error { s.Listen.SocketMode = v; return nil }},
+	{"DATA_DIR", func(s *Settings, v string) error { s.DataDir = v; return nil }},
+	{"CONFIG_DIR", func(s *Settings, v string) error { s.Paths.ConfigDir = v; return nil }},
+	{"CLASH_HOME", func(s *Settings, v string) error { s.Paths.ClashHome = v; return nil }},
+	{"MERGED_CONFIG", func(s *Settings, v string) error { s.Paths.MergedConfig = v; return nil }},
+	{"DEFAULT_CONFIG", func(s *Settings, v string) error { s.Paths.DefaultConfig = v; return nil }},
+	{"APP_CONFIG", func(s *Settings, v string) error { s.Paths.AppConfig = v; return nil }},
+	{"RULESET_DIR", func(s *Settings, v string) error { s.Paths.RuleSetDir = v; return nil }},
+	{"AUDIT_LOG", func(s *Settings, v string) error { s.Paths.AuditLog = v; return nil }},
+	{"FRONTEND_DIR", func(s *Settings, v string) error { s.Paths.Frontend = v; return nil }},
+	{"CORE_PATH", func(s *Settings, v string) error { s.Core.Path = v; return nil }},
+	{"CORE_USER", func(s *Settings, v string) error { s.Core.User = v; return nil }},
+	{"CORE_GROUP", func(s *Settings, v string) error { s.Core.Group = v; return nil }},
+	{"CORE_CAPABILITIES", func(s *Settings, v string) error { s.Core.Capabilities = splitList(v); return nil }},
+	{"CORE_NOFILE", func(s *Settings, v string) error { return parseUint(v, &s.Core.NoFile) }},
+	{"AUTH_USERS", func(s *Settings, v string) error { return s.Auth.SetUsers(splitList(v)) }},
+	{"AUTH_TOKEN", func(s *Settings, v string) error { s.Auth.Token = v; return nil }},
+	{"UPDATE_INTERVAL", func(s *Settings, v string) error { return parseDuration(v, &s.Scheduler.UpdateInterval) }},
+	{"CORS_ORIGINS", func(s *Settings, v string) error { s.CORS.AllowedOrigins = splitList(v); return nil }},
+	{"CORS_CREDENTIALS", func(s *Settings, v string) error { return parseBool(v, &s.CORS.AllowCredentials) }},
+	{"CORS_MAX_AGE", func(s *Settings, v string) error { return parseInt(v, &s.CORS.MaxAge) }},
+	{"TLS_CERT", func(s *Settings, v string) error { s.TLS.Cert = v; return nil }},
+	{"TLS_KEY", func(s *Settings, v string) error { s.TLS.Key = v; return nil }},
+	{"TLS_SELF_SIGNED", func(s *Settings, v string) error { return parseBool(v, &s.TLS.SelfSigned) }},
+	{"TLS_REDIRECT", func(s *Settings, v string) error { s.TLS.Redirect = v; return nil }},
+	{"TLS_CORE_LISTEN", func(s *Settings, v string) error { s.TLS.CoreListen = v; return nil }},
+	{"SHUTDOWN_STOP_CORE", func(s *Settings, v string) error { return parseBool(v, &s.Shutdown.StopCore) }},
+	{"SHUTDOWN_TIMEOUT", func(s *Settings, v string) error { return parseDuration(v, &s.Shutdown.Timeout) }},
+	{"VERBOSE", func(s *Settings, v string) error { return parseBool(v, &s.Verbose) }},
+}
+
+// 应用 CLASH_CENTER_* 环境变量
+func (s *Settings) applyEnv() error {
+	for _, setting := range envSettings {
+		value, ok := os.LookupEnv(EnvPrefix + setting.name)
+		if !ok {
+			continue
+		}
+		if err := setting.apply(s, strings.TrimSpace(value)); err != nil {
+			return fmt.Errorf("环境变量 %s%s 无效: %v", EnvPrefix, setting.name, err)
+		}
+	}
+	return nil
+}
+
+// SetUsers 以 用户名:密码 格式设置允许访问的用户，替换原有用户
+func (a *Auth) SetUsers(users []string) error {
+	a.Users = make(map[string]string, len(users))
+	for _, user := range users {
+		name, password, ok := strings.Cut(user, ":")
+		if !ok || name == "" {
+			return fmt.Errorf("无效的用户设置: %s，格式应为 用户名:密码", user)
+		}
+		a.Users[name] = password
+	}
+	return nil
+}
+
+// Validate 检查设置是否有效，返回所有发现的问题，路径的检查见PreparePaths
+func (s Settings) Validate() error {
+	var problems []string
+	addf := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if s.Listen.Port < 0 || s.Listen.Port > 65535 {
+		addf("listen.port 必须在0到65535之间（0表示只使用Unix套接字），当前为 %d", s.Listen.Port)
+	} else if !s.Listen.TCPEnabled() && s.Listen.Socket == "" {
+		addf("listen.port 为0时需要设置 listen.socket，否则没有可用的监听地址")
+	}
+	if s.Listen.Socket != "" {
+		if _, err := s.Listen.FileMode(); err != nil {
+			addf("listen.socket_mode %v", err)
+		}
+	}
+	if strings.ContainsAny(s.Listen.Host, "/ ") {
+		addf("listen.host 不是有效的主机名或IP地址: %s", s.Listen.Host)
+	}
+
+	if s.Core.Group != "" && s.Core.User == "" {
+		addf("core.group 需要同时设置 core.user")
+	}
+	for _, name := range s.Core.Capabilities {
+		if _, err := clash.ParseCapability(name); err != nil {
+			addf("core.capabilities %v", err)
+		}
+	}
+	if runtime.GOOS != "linux" && (s.Core.User != "" || s.Core.NoFile != 0) {
+		addf("core.user 和 core.nofile 只支持Linux")
+	}
+
+	for name := range s.Auth.Users {
+		if name == "" || strings.Contains(name, ":") {
+			addf("auth.users 中的用户名无效: %q", name)
+		}
+	}
+
+	if s.Scheduler.UpdateInterval < 0 {
+		addf("scheduler.update_interval 不能为负数")
+	} else if s.Scheduler.UpdateInterval > 0 && s.Scheduler.UpdateInterval < minUpdateInterval {
+		addf("scheduler.update_interval 不能小于 %s，当前为 %s", minUpdateInterval, s.Scheduler.UpdateInterval)
+	}
+
+	if slices.Contains(s.CORS.AllowedOrigins, "*") {
+		if len(s.CORS.AllowedOrigins) > 1 {
+			addf("cors.allowed_origins 中的 * 不能与其他来源同时使用")
+		}
+		// 允许任意来源携带凭据时，任何网站都能使用浏览器保存的认证信息读取API
+		if s.CORS.AllowCredentials {
+			addf("cors.allowed_origins 为 * 时不能启用 cors.allow_credentials")
+		}
+	}
+	if s.CORS.MaxAge < 0 {
+		addf("cors.max_age 不能为负数")
+	}
+
+	if (s.TLS.Cert == "") != (s.TLS.Key == "") {
+		addf("tls.cert 和 tls.key 需要同时设置")
+	}
+	if s.TLS.Redirect != "" {
+		if !s.TLS.Enabled() {
+			addf("tls.redirect 需要同时启用HTTPS")
+		} else if _, _, err := net.SplitHostPort(s.TLS.Redirect); err != nil {
+			addf("tls.redirect 不是有效的监听地址: %s", s.TLS.Redirect)
+		}
+	}
+	if s.TLS.CoreListen != "" {
+		// 该地址不使用HTTPS，只能监听回环地址
+		host, _, err := net.SplitHostPort(s.TLS.CoreListen)
+		if ip := net.ParseIP(host); err != nil || ip == nil || !ip.IsLoopback() {
+			addf("tls.core_listen 必须是回环地址，如 127.0.0.1:7789，当前为 %s", s.TLS.CoreListen)
+		}
+	}
+	if s.Listen.Port == 65535 && s.TLS.Enabled() && s.TLS.CoreListen == "" {
+		addf("listen.port 为65535时需要设置 tls.core_listen")
+	}
+
+	if s.Shutdown.Timeout <= 0 {
+		addf("shutdown.timeout 必须大于0")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("设置无效:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
+
+// 按逗号分隔，忽略空白项
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func parseInt(value string, out *int) error {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("%q 不是整数", value)
+	}
+	*out = n
+	return nil
+}
+
+func parseUint(value string, out *uint64) error {
+	n, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%q 不是非负整数", value)
+	}
+	*out = n
+	return nil
+}
+
+func parseBool(value string, out *bool) error {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("%q 不是布尔值", value)
+	}
+	*out = b
+	return nil
+}
+
+func parseDuration(value string, out *time.Duration) error {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("%q 不是有效的时间间隔，例如 6h、30m", value)
+	}
+	*out = d
+	return nil
+}
@@ -0,0 +1,205 @@
+package settings
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 清除测试环境中的 CLASH_CENTER_* 环境变量，测试结束后恢复
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, setting := range envSettings {
+		name := EnvPrefix + setting.name
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+}
+
+func writeSettings(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "settings.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	clearEnv(t)
+	path := writeSettings(t, `
+listen:
+  host: 127.0.0.1
+  port: 9000
+auth:
+  token: from-file
+scheduler:
+  update_interval: 1h
+`)
+
+	// 设置文件覆盖默认值，文件中没有的字段保持默认值
+	s, err := Load(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults := Default()
+	if s.Listen.Host != "127.0.0.1" || s.Listen.Port != 9000 || s.Auth.Token != "from-file" || s.Scheduler.UpdateInterval != time.Hour {
+		t.Errorf("没有应用设置文件: %+v", s)
+	}
+	if s.Listen.SocketMode != defaults.Listen.SocketMode || s.Shutdown != defaults.Shutdown {
+		t.Errorf("文件中没有的字段应保持默认值: %+v", s)
+	}
+
+	// 环境变量覆盖设置文件
+	t.Setenv(EnvPrefix+"PORT", "9100")
+	t.Setenv(EnvPrefix+"AUTH_TOKEN", "from-env")
+	s, err = Load(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Listen.Port != 9100 || s.Auth.Token != "from-env" {
+		t.Errorf("环境变量没有覆盖设置文件: port=%d token=%s", s.Listen.Port, s.Auth.Token)
+	}
+	if s.Listen.Host != "127.0.0.1" {
+		t.Errorf("没有对应环境变量的字段应保持文件中的值: %s", s.Listen.Host)
+	}
+}
+
+func TestLoadFileErrors(t *testing.T) {
+	clearEnv(t)
+
+	// 明确指定的文件不存在时报错，默认文件不存在时使用默认设置
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml"), ""); err == nil {
+		t.Error("指定的设置文件不存在时应返回错误")
+	}
+	if s, err := Load("", t.TempDir()); err != nil || s.Listen.Port != Default().Listen.Port {
+		t.Errorf("默认设置文件不存在时应使用默认设置: %v", err)
+	}
+
+	// 拼错的字段名不会被忽略
+	path := writeSettings(t, "listen:\n  prot: 9000\n")
+	if _, err := Load(path, ""); err == nil || !strings.Contains(err.Error(), "prot") {
+		t.Errorf("未知字段应返回错误: %v", err)
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	clearEnv(t)
+	t.Setenv(EnvPrefix+"HOST", " ::1 ")
+	t.Setenv(EnvPrefix+"CORE_NOFILE", "65535")
+	t.Setenv(EnvPrefix+"CORE_CAPABILITIES", "CAP_NET_ADMIN, ,net_raw")
+	t.Setenv(EnvPrefix+"AUTH_USERS", "alice:a:1,bob:b")
+	t.Setenv(EnvPrefix+"UPDATE_INTERVAL", "6h")
+	t.Setenv(EnvPrefix+"CORS_ORIGINS", "https://a.example,https://b.example")
+	t.Setenv(EnvPrefix+"CORS_CREDENTIALS", "true")
+	t.Setenv(EnvPrefix+"SHUTDOWN_STOP_CORE", "false")
+	t.Setenv(EnvPrefix+"SHUTDOWN_TIMEOUT", "30s")
+
+	s := Default()
+	if err := s.applyEnv(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Listen.Host != "::1" {
+		t.Errorf("HOST 没有去掉空白: %q", s.Listen.Host)
+	}
+	if s.Core.NoFile != 65535 {
+		t.Errorf("CORE_NOFILE = %d", s.Core.NoFile)
+	}
+	if !slices.Equal(s.Core.Capabilities, []string{"CAP_NET_ADMIN", "net_raw"}) {
+		t.Errorf("CORE_CAPABILITIES = %v", s.Core.Capabilities)
+	}
+	// 密码中可以包含冒号
+	if len(s.Auth.Users) != 2 || s.Auth.Users["alice"] != "a:1" || s.Auth.Users["bob"] != "b" {
+		t.Errorf("AUTH_USERS = %v", s.Auth.Users)
+	}
+	if s.Scheduler.UpdateInterval != 6*time.Hour || s.Shutdown.Timeout != 30*time.Second {
+		t.Errorf("时间间隔解析错误: %v %v", s.Scheduler.UpdateInterval, s.Shutdown.Timeout)
+	}
+	if len(s.CORS.AllowedOrigins) != 2 || !s.CORS.AllowCredentials || s.Shutdown.StopCore {
+		t.Errorf("CORS和退出设置解析错误: %+v %+v", s.CORS, s.Shutdown)
+	}
+}
+
+func TestApplyEnvInvalid(t *testing.T) {
+	tests := []struct {
+		name, value string
+	}{
+		{"PORT", "abc"},
+		{"CORE_NOFILE", "-1"},
+		{"VERBOSE", "maybe"},
+		{"UPDATE_INTERVAL", "6"},
+		{"AUTH_USERS", "alice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			t.Setenv(EnvPrefix+tt.name, tt.value)
+			_, err := Load("", t.TempDir())
+			if err == nil {
+				t.Fatalf("%s=%s 应返回错误", tt.name, tt.value)
+			}
+			// 错误中说明是哪个环境变量
+			if !strings.Contains(err.Error(), EnvPrefix+tt.name) {
+				t.Errorf("错误中没有环境变量名: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Fatalf("默认设置无效: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(s *Settings)
+		want   string
+	}{
+		{"端口超出范围", func(s *Settings) { s.Listen.Port = 70000 }, "listen.port"},
+		{"没有监听地址", func(s *Settings) { s.Listen.Port = 0 }, "listen.socket"},
+		{"套接字权限", func(s *Settings) { s.Listen.Socket = "/tmp/cc.sock"; s.Listen.SocketMode = "999" }, "listen.socket_mode"},
+		{"主机名", func(s *Settings) { s.Listen.Host = "a b" }, "listen.host"},
+		{"只设置用户组", func(s *Settings) { s.Core.Group = "proxy" }, "core.group"},
+		{"未知的capability", func(s *Settings) { s.Core.Capabilities = []string{"CAP_SYS_ADMIN"} }, "core.capabilities"},
+		{"更新间隔太短", func(s *Settings) { s.Scheduler.UpdateInterval = time.Minute }, "scheduler.update_interval"},
+		{"更新间隔为负数", func(s *Settings) { s.Scheduler.UpdateInterval = -time.Hour }, "scheduler.update_interval"},
+		{"任意来源携带凭据", func(s *Settings) { s.CORS.AllowedOrigins = []string{"*"}; s.CORS.AllowCredentials = true }, "cors.allow_credentials"},
+		{"任意来源与其他来源", func(s *Settings) { s.CORS.AllowedOrigins = []string{"*", "https://a.example"} }, "cors.allowed_origins"},
+		{"只有证书", func(s *Settings) { s.TLS.Cert = "cert.pem" }, "tls.key"},
+		{"重定向未启用HTTPS", func(s *Settings) { s.TLS.Redirect = ":80" }, "tls.redirect"},
+		{"内核地址不是回环地址", func(s *Settings) { s.TLS.SelfSigned = true; s.TLS.CoreListen = "0.0.0.0:7789" }, "tls.core_listen"},
+		{"退出等待时间", func(s *Settings) { s.Shutdown.Timeout = 0 }, "shutdown.timeout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Default()
+			tt.modify(&s)
+			err := s.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("错误应包含 %s: %v", tt.want, err)
+			}
+		})
+	}
+
+	// 一次返回所有问题
+	s := Default()
+	s.Listen.Port = -1
+	s.Shutdown.Timeout = 0
+	err := s.Validate()
+	if err == nil || !strings.Contains(err.Error(), "listen.port") || !strings.Contains(err.Error(), "shutdown.timeout") {
+		t.Errorf("没有返回所有问题: %v", err)
+	}
+
+	if runtime.GOOS != "linux" {
+		s := Default()
+		s.Core.User = "nobody"
+		if err := s.Validate(); err == nil || !strings.Contains(err.Error(), "core.user") {
+			t.Errorf("非Linux系统设置core.user应返回错误: %v", err)
+		}
+	}
+}
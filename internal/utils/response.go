@@ -46,6 +46,23 @@ func SendErrorResponse(w http.ResponseWriter, statusCode int, errMsg string) {
 	SendJSONResponse(w, statusCode, response)
 }
 
+// 发送带数据的成功响应，用于v2接口
+func SendDataResponse(w http.ResponseWriter, statusCode int, data any) {
+	SendJSONResponse(w, statusCode, models.APIResponse{
+		Success: true,
+		Data:    data,
+	})
+}
+
+// 发送带错误码的错误响应，用于v2接口
+func SendCodedErrorResponse(w http.ResponseWriter, statusCode int, code, errMsg string) {
+	SendJSONResponse(w, statusCode, models.APIResponse{
+		Success: false,
+		Error:   errMsg,
+		Code:    code,
+	})
+}
+
 // GetTimestamp 获取当前的Unix时间戳
 func GetTimestamp() int64 {
 	return time.Now().Unix()
@@ -1,13 +1,29 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"clash-center/internal/api"
+	"clash-center/internal/certs"
 	"clash-center/internal/clash"
+	"clash-center/internal/cli"
 	"clash-center/internal/config"
+	"clash-center/internal/notify"
+	"clash-center/internal/settings"
 
 	"github.com/spf13/pflag"
 )
@@ -17,29 +33,90 @@ func init() {
 }
 
 func main() {
-	// 定义命令行参数
-	host := pflag.StringP("host", "H", "0.0.0.0", "服务器监听地址")
-	port := pflag.IntP("port", "p", 7788, "服务器监听端口")
-	clashHome := pflag.StringP("clash-home", "h", clash.ClashHome, "Clash主目录路径")
-	configDir := pflag.StringP("config-dir", "c", config.ConfigDir, "配置文件目录路径")
-	verbose := pflag.BoolP("verbose", "v", false, "启用详细日志输出")
+	// 子命令，如 clash-center configs list
+	if len(os.Args) > 1 && cli.IsCommand(os.Args[1]) {
+		os.Exit(cli.Run(os.Args[1:]))
+	}
 
-	// 解析命令行参数
-	pflag.Parse()
+	// 读取设置，优先级为 命令行参数 > 环境变量 > 设置文件 > 默认值
+	s, err := loadSettings(os.Args[1:])
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+	if err := s.ResolvePaths(); err != nil {
+		log.Fatalf("%v\n", err)
+	}
+	if err := s.Validate(); err != nil {
+		log.Fatalf("%v\n", err)
+	}
+
+	// 创建缺少的目录并检查读写权限
+	warnings, err := s.PreparePaths()
+	for _, warning := range warnings {
+		log.Printf("警告: %s", warning)
+	}
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+	applySettings(s)
+
+	// 检查是否有权限以设置的用户运行内核
+	if err := clash.CheckProcessSettings(); err != nil {
+		log.Fatalf("%v\n", err)
+	}
+
+	// 准备HTTPS证书，放在启动内核之前以便尽早发现错误
+	var tlsConfig *tls.Config
+	if s.TLS.Enabled() {
+		tlsConfig, err = loadTLSConfig(s)
+		if err != nil {
+			log.Fatalf("%v\n", err)
+		}
+	}
+
+	// 在启动内核之前监听，地址被占用时直接退出
+	listeners, err := listen(s, tlsConfig)
+	if err != nil {
+		log.Fatalf("启动服务器失败: %v\n", err)
+	}
+	var coreListener net.Listener
+	if addr := s.CoreListenAddress(); addr != "" {
+		coreListener, err = net.Listen("tcp", addr)
+		if err != nil {
+			log.Fatalf("监听供内核访问的地址失败: %v，可以通过 tls.core_listen 修改\n", err)
+		}
+		log.Printf("内核通过 http://%s 获取proxy-providers和规则集", addr)
+	}
 
-	// 应用命令行参数
-	clash.ClashHome = *clashHome
-	config.ConfigDir = *configDir
-	isVerbose := *verbose
+	if api.AuthEnabled() {
+		log.Printf("已启用访问认证")
+	}
 
+	log.Printf("数据目录: %s\n", s.DataDir)
 	log.Printf("Clash主目录: %s\n", clash.ClashHome)
 	log.Printf("配置文件目录: %s\n", config.ConfigDir)
 
+	// 内核通过本地地址访问clash-center提供的proxy-providers，需要在合并配置之前设置
+	// 启用HTTPS时使用单独的本机HTTP地址，内核不信任自签名证书
+	config.ServerURL = "http://" + s.Listen.LocalAddress()
+	if coreListener != nil {
+		config.ServerURL = "http://" + coreListener.Addr().String()
+	}
+	if !s.Listen.TCPEnabled() {
+		log.Printf("警告: 未监听TCP端口，内核无法从clash-center获取proxy-providers和规则集")
+	}
+
+	// 在自动启动内核之前开始监听事件，以便发送通知
+	notify.Start()
+
 	// 加载应用程序配置
 	appConfig := config.LoadAppConfig()
 
+	// 接管上次退出时保留运行的内核，或者报告占用控制器地址的其他程序
+	clash.DetectRunning()
+
 	// 如果配置了自动启动并且有上次使用的配置文件，则启动Clash
-	if appConfig.AutoStart && appConfig.LastConfig != "" {
+	if appConfig.AutoStart && appConfig.LastConfig != "" && !clash.IsRunning() {
 		// 记录原始配置文件路径
 		config.OriginalConfigName = appConfig.LastConfig
 
@@ -52,14 +129,341 @@ func main() {
 		}
 	}
 
+	// 定时更新订阅
+	api.StartScheduler()
+
 	// 设置路由
-	router := api.SetupRoutes(isVerbose)
+	router := api.SetupRoutes(s.Verbose)
+
+	// 启动服务器，收到退出信号或服务器出错时关闭
+	server := &http.Server{Handler: router, TLSConfig: tlsConfig}
+	server.RegisterOnShutdown(api.BeginShutdown)
+	servers := []*http.Server{server}
+	serveErr := make(chan error, len(listeners))
+	for _, l := range listeners {
+		go func() {
+			var err error
+			if l.tls {
+				err = server.ServeTLS(l.Listener, "", "")
+			} else {
+				err = server.Serve(l.Listener)
+			}
+			if !errors.Is(err, http.ErrServerClosed) {
+				serveErr <- err
+			}
+		}()
+	}
+	if tlsConfig != nil && s.TLS.Redirect != "" {
+		servers = append(servers, serveHTTPSRedirect(s.TLS.Redirect, s.Listen.Port))
+	}
+	if coreListener != nil {
+		servers = append(servers, serveCoreRoutes(coreListener))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	exitCode := 0
+	select {
+	case <-ctx.Done():
+		log.Printf("收到退出信号，正在关闭...")
+	case err := <-serveErr:
+		log.Printf("服务器运行失败: %v", err)
+		exitCode = 1
+	}
+	// 关闭期间再次收到信号时直接退出
+	stop()
+
+	shutdown(s.Shutdown, servers)
+	os.Exit(exitCode)
+}
+
+// 解析命令行参数并读取设置，优先级为 命令行参数 > 环境变量 > 设置文件 > 默认值
+// 只有明确指定的参数才会覆盖环境变量和设置文件
+func loadSettings(args []string) (settings.Settings, error) {
+	flags := pflag.NewFlagSet(os.Args[0], pflag.ExitOnError)
+	defaults := settings.Default()
+	dataDir := flags.StringP("data-dir", "d", defaults.DataDir, "数据目录，未单独设置的文件和目录都位于其中")
+	settingsPath := flags.StringP("settings", "s", "", "设置文件路径，默认为 <数据目录>/"+settings.DefaultFileName+"，也可以通过 CLASH_CENTER_SETTINGS 设置")
+	host := flags.StringP("host", "H", defaults.Listen.Host, "服务器监听地址")
+	port := flags.IntP("port", "p", defaults.Listen.Port, "服务器监听端口，0表示只监听Unix套接字")
+	socket := flags.String("socket", "", "同时监听的Unix套接字路径")
+	socketMode := flags.String("socket-mode", defaults.Listen.SocketMode, "Unix套接字的文件权限")
+	clashHome := flags.StringP("clash-home", "h", "", "Clash主目录路径，默认为 <数据目录>/clash")
+	configDir := flags.StringP("config-dir", "c", "", "配置文件目录路径，默认为 <数据目录>/configs")
+	frontendDir := flags.String("frontend-dir", "", "前端文件目录，用于开发或替换嵌入的前端")
+	corePath := flags.String("core-path", "", "Clash内核可执行文件路径，默认为 <Clash主目录>/clash.meta")
+	coreUser := flags.String("core-user", "", "运行Clash内核的用户，需要以root运行，仅支持Linux")
+	coreGroup := flags.String("core-group", "", "运行Clash内核的用户组，默认为该用户的主组")
+	verbose := flags.BoolP("verbose", "v", false, "启用详细日志输出")
+	authUsers := flags.StringArray("auth-user", nil, "允许访问的用户，格式为 用户名:密码，可重复指定")
+	authToken := flags.String("auth-token", "", "API访问令牌，通过 Authorization: Bearer 传递")
+	tlsCert := flags.String("tls-cert", "", "HTTPS证书文件")
+	tlsKey := flags.String("tls-key", "", "HTTPS私钥文件")
+	tlsSelfSigned := flags.Bool("tls-self-signed", false, "使用自签名证书启用HTTPS，证书不存在时自动生成并保存")
+	tlsRedirect := flags.String("tls-redirect", "", "监听该地址并将HTTP请求重定向到HTTPS，如 :80")
+	updateInterval := flags.Duration("update-interval", 0, "自动更新订阅的间隔，如 6h，0表示不自动更新")
+	keepCore := flags.Bool("keep-core", false, "退出时保留Clash内核继续运行")
+	shutdownTimeout := flags.Duration("shutdown-timeout", defaults.Shutdown.Timeout, "退出时等待正在处理的请求完成的最长时间")
 
-	// 启动服务器
-	serverAddr := *host + ":" + strconv.Itoa(*port)
-	log.Printf("启动服务器，监听地址 %s...\n", serverAddr)
-	err := http.ListenAndServe(serverAddr, router)
+	// 解析命令行参数
+	flags.Parse(args)
+
+	if *settingsPath == "" {
+		*settingsPath = os.Getenv("CLASH_CENTER_SETTINGS")
+	}
+	lookupDir := ""
+	if flags.Changed("data-dir") {
+		lookupDir = *dataDir
+	}
+	s, err := settings.Load(*settingsPath, lookupDir)
 	if err != nil {
-		log.Fatalf("启动服务器失败: %v\n", err)
+		return s, err
+	}
+	var flagErr error
+	flags.Visit(func(f *pflag.Flag) {
+		switch f.Name {
+		case "data-dir":
+			s.DataDir = *dataDir
+		case "host":
+			s.Listen.Host = *host
+		case "port":
+			s.Listen.Port = *port
+		case "socket":
+			s.Listen.Socket = absFlagPath(*socket)
+		case "socket-mode":
+			s.Listen.SocketMode = *socketMode
+		case "clash-home":
+			s.Paths.ClashHome = absFlagPath(*clashHome)
+		case "config-dir":
+			s.Paths.ConfigDir = absFlagPath(*configDir)
+		case "frontend-dir":
+			s.Paths.Frontend = absFlagPath(*frontendDir)
+		case "core-path":
+			s.Core.Path = *corePath
+			if strings.ContainsRune(*corePath, filepath.Separator) || strings.ContainsRune(*corePath, '/') {
+				s.Core.Path = absFlagPath(*corePath)
+			}
+		case "core-user":
+			s.Core.User = *coreUser
+		case "core-group":
+			s.Core.Group = *coreGroup
+		case "verbose":
+			s.Verbose = *verbose
+		case "auth-user":
+			flagErr = s.Auth.SetUsers(*authUsers)
+		case "auth-token":
+			s.Auth.Token = *authToken
+		case "tls-cert":
+			s.TLS.Cert = absFlagPath(*tlsCert)
+		case "tls-key":
+			s.TLS.Key = absFlagPath(*tlsKey)
+		case "tls-self-signed":
+			s.TLS.SelfSigned = *tlsSelfSigned
+		case "tls-redirect":
+			s.TLS.Redirect = *tlsRedirect
+		case "update-interval":
+			s.Scheduler.UpdateInterval = *updateInterval
+		case "keep-core":
+			s.Shutdown.StopCore = !*keepCore
+		case "shutdown-timeout":
+			s.Shutdown.Timeout = *shutdownTimeout
+		}
+	})
+	return s, flagErr
+}
+
+// 服务器监听的地址
+type serverListener struct {
+	net.Listener
+	tls bool
+}
+
+// 监听TCP端口和Unix套接字，TCP端口启用HTTPS时使用TLS，Unix套接字只能在本机访问，总是使用HTTP
+func listen(s settings.Settings, tlsConfig *tls.Config) ([]serverListener, error) {
+	var listeners []serverListener
+	closeAll := func() {
+		for _, l := range listeners {
+			l.Close()
+		}
+	}
+
+	if s.Listen.TCPEnabled() {
+		addr := net.JoinHostPort(s.Listen.Host, strconv.Itoa(s.Listen.Port))
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		if tlsConfig != nil {
+			log.Printf("启动HTTPS服务器，监听地址 %s...\n", addr)
+		} else {
+			log.Printf("启动服务器，监听地址 %s...\n", addr)
+		}
+		listeners = append(listeners, serverListener{Listener: ln, tls: tlsConfig != nil})
+	}
+
+	if s.Listen.Socket != "" {
+		mode, err := s.Listen.FileMode()
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		ln, err := listenUnix(s.Listen.Socket, mode)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		log.Printf("启动服务器，监听Unix套接字 %s (%04o)...\n", s.Listen.Socket, mode)
+		listeners = append(listeners, serverListener{Listener: ln})
+	}
+	return listeners, nil
+}
+
+// 命令行中的相对路径以当前目录为准，设置文件和环境变量中的相对路径以数据目录为准
+func absFlagPath(path string) string {
+	if path == "" {
+		return path
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// 监听Unix套接字并设置文件权限，上次未正常退出时遗留的套接字文件会被删除
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s 已存在且不是套接字", path)
+		}
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("套接字 %s 正在被其他进程使用", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("删除遗留的套接字 %s 失败: %v", path, err)
+		}
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("设置套接字 %s 的权限失败: %v", path, err)
+	}
+	return ln, nil
+}
+
+// 关闭服务器并等待正在处理的请求完成，然后根据设置停止内核或保留内核运行
+func shutdown(opts settings.Shutdown, servers []*http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+	defer cancel()
+	for _, server := range servers {
+		if err := server.Shutdown(ctx); err != nil {
+			log.Printf("等待请求完成超时，强制关闭连接: %v", err)
+			server.Close()
+		}
+	}
+
+	if opts.StopCore {
+		if err := clash.StopClash(); err != nil {
+			log.Printf("停止Clash失败: %v", err)
+		}
+	} else if clash.IsRunning() {
+		// 内核继续运行，只保存代理组选择
+		clash.SaveSelections()
+		log.Printf("Clash内核继续运行，PID %d，下次启动时接管", clash.PID())
+	}
+	log.Printf("clash-center 已退出")
+}
+
+// 加载HTTPS证书，启用自签名时证书不存在则生成并保存
+func loadTLSConfig(s settings.Settings) (*tls.Config, error) {
+	if s.TLS.SelfSigned {
+		hosts := []string{s.Listen.Host}
+		if hostname, err := os.Hostname(); err == nil {
+			hosts = append(hosts, hostname)
+		}
+		generated, err := certs.EnsureSelfSigned(s.TLS.Cert, s.TLS.Key, hosts)
+		if err != nil {
+			return nil, err
+		}
+		if generated {
+			log.Printf("已生成自签名证书: %s", s.TLS.Cert)
+		}
+	}
+
+	cert, err := certs.Load(s.TLS.Cert, s.TLS.Key)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("HTTPS证书: %s，有效期至 %s", s.TLS.Cert, cert.Leaf.NotAfter.Format(time.DateOnly))
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// 监听HTTP地址，将请求重定向到相同主机的HTTPS端口
+func serveHTTPSRedirect(addr string, httpsPort int) *http.Server {
+	server := &http.Server{Addr: addr, Handler: httpsRedirectHandler(httpsPort)}
+	log.Printf("将 %s 的HTTP请求重定向到HTTPS", addr)
+	go func() {
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("HTTP重定向服务失败: %v", err)
+		}
+	}()
+	return server
+}
+
+// 将请求重定向到相同主机、指定端口的HTTPS地址
+func httpsRedirectHandler(httpsPort int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+		host = strings.Trim(host, "[]")
+		if httpsPort != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(httpsPort))
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		target := url.URL{Scheme: "https", Host: host, Path: r.URL.Path, RawQuery: r.URL.RawQuery}
+		http.Redirect(w, r, target.String(), http.StatusPermanentRedirect)
+	})
+}
+
+// 在本机HTTP地址上提供内核需要的provider和规则集
+func serveCoreRoutes(ln net.Listener) *http.Server {
+	server := &http.Server{Handler: api.SetupCoreRoutes()}
+	go func() {
+		if err := server.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("供内核访问的HTTP服务失败: %v", err)
+		}
+	}()
+	return server
+}
+
+// 将设置应用到各个包
+func applySettings(s settings.Settings) {
+	s.ApplyPaths()
+	api.FrontendDir = s.Paths.Frontend
+	api.AuthUsers = s.Auth.Users
+	api.AuthToken = s.Auth.Token
+	api.UpdateInterval = s.Scheduler.UpdateInterval
+	api.CORSAllowedOrigins = s.CORS.AllowedOrigins
+	api.CORSAllowCredentials = s.CORS.AllowCredentials
+	api.CORSMaxAge = s.CORS.MaxAge
+
+	clash.CoreUser = s.Core.User
+	clash.CoreGroup = s.Core.Group
+	clash.CoreNoFile = s.Core.NoFile
+	// capabilities只在切换用户时授予，与clash-center相同的用户直接继承
+	if s.Core.User != "" {
+		clash.CoreCapabilities = s.Core.Capabilities
 	}
+	// 退出时保留内核的情况下，内核不能随clash-center一起终止
+	clash.KillWithParent = s.Shutdown.StopCore
 }
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"clash-center/internal/certs"
+	"clash-center/internal/settings"
+)
+
+// 使用给定的TLS配置启动本地HTTPS服务器，客户端只信任certPath中的证书
+func startTLSServer(t *testing.T, tlsConfig *tls.Config, certPath string) (*httptest.Server, *http.Client) {
+	t.Helper()
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	server.TLS = tlsConfig
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	pool, err := certs.CertPool(certPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	return server, client
+}
+
+func getBody(t *testing.T, client *http.Client, url string) string {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+func TestTLSUserProvidedCert(t *testing.T) {
+	dir := t.TempDir()
+	certPEM, keyPEM, err := certs.GenerateSelfSigned([]string{"example.test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := settings.Default()
+	s.TLS.Cert = filepath.Join(dir, "cert.pem")
+	s.TLS.Key = filepath.Join(dir, "key.pem")
+	os.WriteFile(s.TLS.Cert, certPEM, 0644)
+	os.WriteFile(s.TLS.Key, keyPEM, 0600)
+
+	tlsConfig, err := loadTLSConfig(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, client := startTLSServer(t, tlsConfig, s.TLS.Cert)
+	if body := getBody(t, client, server.URL); body != "ok" {
+		t.Fatalf("响应为 %q", body)
+	}
+
+	// 不信任该证书的客户端无法连接
+	if _, err := http.Get(server.URL); err == nil {
+		t.Fatal("未信任的证书应该连接失败")
+	}
+}
+
+func TestTLSUserProvidedCertMissing(t *testing.T) {
+	s := settings.Default()
+	s.TLS.Cert = filepath.Join(t.TempDir(), "cert.pem")
+	s.TLS.Key = filepath.Join(t.TempDir(), "key.pem")
+	if _, err := loadTLSConfig(s); err == nil {
+		t.Fatal("证书不存在时应该返回错误")
+	}
+}
+
+func TestTLSSelfSignedGenerateAndReuse(t *testing.T) {
+	dir := t.TempDir()
+	s := settings.Default()
+	s.TLS.SelfSigned = true
+	s.TLS.Cert = filepath.Join(dir, "tls", "cert.pem")
+	s.TLS.Key = filepath.Join(dir, "tls", "key.pem")
+
+	tlsConfig, err := loadTLSConfig(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := os.ReadFile(s.TLS.Cert)
+	if err != nil {
+		t.Fatalf("没有保存生成的证书: %v", err)
+	}
+	if info, err := os.Stat(s.TLS.Key); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("私钥权限应为0600: %v %v", info, err)
+	}
+
+	server, client := startTLSServer(t, tlsConfig, s.TLS.Cert)
+	if body := getBody(t, client, server.URL); body != "ok" {
+		t.Fatalf("响应为 %q", body)
+	}
+
+	// 再次启动时使用已保存的证书
+	again, err := loadTLSConfig(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := os.ReadFile(s.TLS.Cert)
+	if !bytes.Equal(first, second) {
+		t.Fatal("再次启动时重新生成了证书")
+	}
+	if !bytes.Equal(again.Certificates[0].Certificate[0], tlsConfig.Certificates[0].Certificate[0]) {
+		t.Fatal("再次启动时加载的证书不同")
+	}
+}
+
+func TestHTTPSRedirect(t *testing.T) {
+	server := httptest.NewServer(httpsRedirectHandler(8443))
+	defer server.Close()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"example.test:8080", "https://example.test:8443/api/status?a=1"},
+		{"example.test", "https://example.test:8443/api/status?a=1"},
+		{"[::1]:8080", "https://[::1]:8443/api/status?a=1"},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/status?a=1", nil)
+		req.Host = tt.host
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusPermanentRedirect {
+			t.Errorf("%s: 状态码为 %d", tt.host, resp.StatusCode)
+		}
+		if got := resp.Header.Get("Location"); got != tt.want {
+			t.Errorf("%s: 重定向到 %s，应为 %s", tt.host, got, tt.want)
+		}
+	}
+
+	// 使用443端口时地址中不带端口
+	standard := httptest.NewServer(httpsRedirectHandler(443))
+	defer standard.Close()
+	req, _ := http.NewRequest(http.MethodGet, standard.URL+"/", nil)
+	req.Host = "example.test:80"
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := resp.Header.Get("Location"); got != "https://example.test/" {
+		t.Errorf("重定向到 %s，应为 https://example.test/", got)
+	}
+}
+
+func TestListenUnix(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix套接字的文件权限只在类Unix系统上有效")
+	}
+	path := filepath.Join(t.TempDir(), "cc.sock")
+
+	ln, err := listenUnix(path, 0660)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&os.ModeSocket == 0 || info.Mode().Perm() != 0660 {
+		t.Errorf("套接字文件模式为 %v，应为0660的套接字", info.Mode())
+	}
+
+	// 其他进程仍在使用的套接字不会被删除
+	if _, err := listenUnix(path, 0660); err == nil {
+		t.Fatal("套接字正在使用时应该返回错误")
+	}
+	if conn, err := net.Dial("unix", path); err != nil {
+		t.Fatalf("正在使用的套接字被删除: %v", err)
+	} else {
+		conn.Close()
+	}
+
+	// 异常退出时遗留的套接字文件被删除后重新监听
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	ln.Close()
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("没有模拟出遗留的套接字: %v", err)
+	}
+	ln, err = listenUnix(path, 0600)
+	if err != nil {
+		t.Fatalf("遗留的套接字没有被删除: %v", err)
+	}
+	defer ln.Close()
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("重新监听后的套接字权限不正确: %v %v", info, err)
+	}
+
+	// 同名的普通文件不会被删除
+	file := filepath.Join(t.TempDir(), "file.sock")
+	os.WriteFile(file, []byte("data"), 0644)
+	if _, err := listenUnix(file, 0660); err == nil {
+		t.Fatal("路径是普通文件时应该返回错误")
+	}
+	if data, _ := os.ReadFile(file); string(data) != "data" {
+		t.Error("普通文件被修改")
+	}
+}
+
+// 启动一个处理请求时阻塞到release关闭的服务器
+func startBlockingServer(t *testing.T) (*http.Server, string, chan struct{}, chan struct{}) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+		io.WriteString(w, "done")
+	})}
+	go server.Serve(ln)
+	t.Cleanup(func() { server.Close() })
+	return server, "http://" + ln.Addr().String(), entered, release
+}
+
+func TestShutdownDrainsRequests(t *testing.T) {
+	server, url, entered, release := startBlockingServer(t)
+
+	type result struct {
+		body string
+		err  error
+	}
+	responses := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			responses <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		responses <- result{string(body), err}
+	}()
+	<-entered
+
+	done := make(chan struct{})
+	go func() {
+		shutdown(settings.Shutdown{Timeout: 5 * time.Second}, []*http.Server{server})
+		close(done)
+	}()
+
+	// 请求未完成时不退出，也不再接受新连接
+	select {
+	case <-done:
+		t.Fatal("请求未完成时就已退出")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if resp, err := http.Get(url); err == nil {
+		resp.Body.Close()
+		t.Error("关闭期间仍然接受新请求")
+	}
+
+	close(release)
+	select {
+	case r := <-responses:
+		if r.err != nil || r.body != "done" {
+			t.Errorf("正在处理的请求没有正常完成: %q %v", r.body, r.err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("请求没有完成")
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("请求完成后没有退出")
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	server, url, entered, release := startBlockingServer(t)
+	defer close(release)
+
+	go func() {
+		if resp, err := http.Get(url); err == nil {
+			resp.Body.Close()
+		}
+	}()
+	<-entered
+
+	// 超过等待时间后强制关闭连接
+	start := time.Now()
+	shutdown(settings.Shutdown{Timeout: 200 * time.Millisecond}, []*http.Server{server})
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond || elapsed > 3*time.Second {
+		t.Errorf("关闭用时 %v，应在超时后立即返回", elapsed)
+	}
+}
+
+func TestLoadSettingsFlagsOverride(t *testing.T) {
+	dataDir := t.TempDir()
+	content := "listen:\n  host: 127.0.0.1\n  port: 9000\nauth:\n  token: from-file\nshutdown:\n  timeout: 20s\n"
+	if err := os.WriteFile(filepath.Join(dataDir, settings.DefaultFileName), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CLASH_CENTER_SETTINGS", "")
+	t.Setenv(settings.EnvPrefix+"PORT", "9100")
+	t.Setenv(settings.EnvPrefix+"AUTH_TOKEN", "from-env")
+
+	// 命令行参数 > 环境变量 > 设置文件 > 默认值
+	s, err := loadSettings([]string{"--data-dir", dataDir, "--port", "9200", "--keep-core"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Listen.Port != 9200 {
+		t.Errorf("命令行参数没有覆盖环境变量，端口为 %d", s.Listen.Port)
+	}
+	if s.Auth.Token != "from-env" {
+		t.Errorf("环境变量没有覆盖设置文件，令牌为 %s", s.Auth.Token)
+	}
+	if s.Listen.Host != "127.0.0.1" || s.Shutdown.Timeout != 20*time.Second {
+		t.Errorf("没有应用设置文件: %+v", s)
+	}
+	if s.Shutdown.StopCore || s.Listen.SocketMode != settings.Default().Listen.SocketMode {
+		t.Errorf("默认值或布尔参数处理错误: %+v", s)
+	}
+
+	// 没有明确指定的参数不会用参数的默认值覆盖设置文件
+	s, err = loadSettings([]string{"--data-dir", dataDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Listen.Host != "127.0.0.1" || s.Listen.Port != 9100 {
+		t.Errorf("未指定的参数覆盖了其他设置: %s:%d", s.Listen.Host, s.Listen.Port)
+	}
+
+	// 命令行中的相对路径以当前目录为准
+	s, err = loadSettings([]string{"--data-dir", dataDir, "--config-dir", "subs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wd, _ := os.Getwd(); s.Paths.ConfigDir != filepath.Join(wd, "subs") {
+		t.Errorf("配置目录为 %s", s.Paths.ConfigDir)
+	}
+}
@@ -0,0 +1,117 @@
+// Package converter 将订阅内容转换为Clash配置、Base64分享链接或sing-box配置，不读写clash-center的配置目录，可供其他Go程序使用
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 输出格式
+const (
+	FormatClash   = "clash"   // Clash YAML配置
+	FormatBase64  = "base64"  // Base64编码的分享链接列表
+	FormatSingBox = "singbox" // sing-box JSON配置
+)
+
+// 输入格式
+const (
+	InputYAML  = "yaml"  // Clash YAML配置
+	InputLinks = "links" // 节点分享链接列表
+)
+
+// ErrUnsupportedFormat 不支持的输出格式
+var ErrUnsupportedFormat = errors.New("不支持的输出格式")
+
+// Options 转换参数
+type Options struct {
+	Format string     // 输出格式，为空时输出Clash配置
+	Filter NodeFilter // 节点过滤和重命名规则
+}
+
+// InputInfo 识别出的输入格式
+type InputInfo struct {
+	Format       string // yaml或links
+	Base64       bool   // 输入是否经过Base64编码
+	InvalidLinks int    // 无法识别的节点链接行数
+}
+
+// Output 按指定格式编码的配置
+type Output struct {
+	Content     []byte
+	ContentType string
+	Extension   string
+	Skipped     []string // 无法转换为该格式的节点名称
+}
+
+// Result 转换结果
+type Result struct {
+	Output
+	Input    InputInfo
+	Nodes    int // 过滤后的节点数
+	Filtered int // 被过滤规则剔除的节点数
+	Groups   int // 代理组数
+}
+
+// Convert 将订阅内容转换为指定格式，不读写配置目录，输出中不包含clash-center的元数据
+func Convert(input []byte, opts Options) (Result, error) {
+	var result Result
+
+	yamlConfig, info, err := parseContent(input)
+	result.Input = info
+	if err != nil {
+		return result, err
+	}
+
+	total := len(GetProxyList(yamlConfig))
+	if err := ApplyNodeFilter(yamlConfig, opts.Filter); err != nil {
+		return result, fmt.Errorf("应用节点过滤规则失败: %v", err)
+	}
+	stripMetadata(yamlConfig)
+
+	result.Nodes = len(GetProxyList(yamlConfig))
+	result.Filtered = total - result.Nodes
+	result.Groups = len(GetProxyGroupList(yamlConfig))
+
+	result.Output, err = EncodeConfig(yamlConfig, opts.Format)
+	return result, err
+}
+
+// EncodeConfig 将Clash配置编码为指定格式
+func EncodeConfig(yamlConfig map[string]any, format string) (Output, error) {
+	var output Output
+	var err error
+
+	switch format {
+	case "", FormatClash:
+		output.Content, err = yaml.Marshal(yamlConfig)
+		if err != nil {
+			err = fmt.Errorf("编码YAML失败: %v", err)
+		}
+		output.ContentType = "text/yaml; charset=utf-8"
+		output.Extension = ".yaml"
+	case FormatBase64:
+		output.Content, output.Skipped = EncodeSubscriptionLinks(GetProxyList(yamlConfig))
+		output.ContentType = "text/plain; charset=utf-8"
+		output.Extension = ".txt"
+	case FormatSingBox, "sing-box":
+		output.Content, output.Skipped, err = BuildSingBoxConfig(GetProxyList(yamlConfig))
+		output.ContentType = "application/json; charset=utf-8"
+		output.Extension = ".json"
+	default:
+		return output, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+
+	return output, err
+}
+
+// 删除clash-center保存在配置中的config_开头的元数据字段
+func stripMetadata(yamlConfig map[string]any) {
+	for key := range yamlConfig {
+		if strings.HasPrefix(key, "config_") {
+			delete(yamlConfig, key)
+		}
+	}
+}
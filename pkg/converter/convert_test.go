@@ -0,0 +1,66 @@
+package converter_test
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"clash-center/pkg/converter"
+)
+
+func TestConvertLinks(t *testing.T) {
+	links := "trojan://pass@hk.example.test:443#HK-01\ntrojan://pass@jp.example.test:443#JP-01\nnot-a-link\n"
+	input := []byte(base64.StdEncoding.EncodeToString([]byte(links)))
+
+	result, err := converter.Convert(input, converter.Options{
+		Format: converter.FormatBase64,
+		Filter: converter.NodeFilter{Exclude: "JP"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Input.Format != converter.InputLinks || !result.Input.Base64 || result.Input.InvalidLinks != 1 {
+		t.Errorf("识别的输入格式为 %+v", result.Input)
+	}
+	if result.Nodes != 1 || result.Filtered != 1 {
+		t.Errorf("节点数 %d，过滤 %d", result.Nodes, result.Filtered)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(string(result.Content))
+	if err != nil {
+		t.Fatalf("输出不是Base64: %v", err)
+	}
+	if !strings.Contains(string(decoded), "hk.example.test") || strings.Contains(string(decoded), "jp.example.test") {
+		t.Errorf("输出内容为 %s", decoded)
+	}
+}
+
+func TestConvertStripsMetadata(t *testing.T) {
+	input := []byte("config_src: https://example.test/sub\nproxies:\n  - {name: a, type: socks5, server: 1.1.1.1, port: 1080}\n")
+
+	result, err := converter.Convert(input, converter.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(result.Content), "config_src") {
+		t.Errorf("输出中包含元数据: %s", result.Content)
+	}
+}
+
+func TestConvertUnsupportedFormat(t *testing.T) {
+	input := []byte("proxies:\n  - {name: a, type: socks5, server: 1.1.1.1, port: 1080}\n")
+	if _, err := converter.Convert(input, converter.Options{Format: "surge"}); err == nil {
+		t.Fatal("不支持的格式没有返回错误")
+	}
+}
+
+func ExampleConvert() {
+	input := []byte("trojan://pass@hk.example.test:443#HK-01")
+	result, err := converter.Convert(input, converter.Options{Format: converter.FormatSingBox})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(result.Nodes, result.Extension)
+	// Output: 1 .json
+}
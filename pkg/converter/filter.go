@@ -0,0 +1,383 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// NodeFilter 订阅节点过滤与重命名规则
+type NodeFilter struct {
+	Include   string       `json:"include,omitempty" yaml:"include,omitempty"`       // 保留名称匹配该正则的节点
+	Exclude   string       `json:"exclude,omitempty" yaml:"exclude,omitempty"`       // 剔除名称匹配该正则的节点
+	Rename    []RenameRule `json:"rename,omitempty" yaml:"rename,omitempty"`         // 按顺序执行的重命名规则
+	Prefix    string       `json:"prefix,omitempty" yaml:"prefix,omitempty"`         // 节点名称前缀
+	EmojiFlag bool         `json:"emoji_flag,omitempty" yaml:"emoji_flag,omitempty"` // 根据地区添加国旗
+}
+
+// RenameRule 节点重命名规则
+type RenameRule struct {
+	Pattern string `json:"pattern" yaml:"pattern"`
+	Replace string `json:"replace" yaml:"replace"`
+}
+
+// IsEmpty 判断过滤规则是否为空
+func (f NodeFilter) IsEmpty() bool {
+	return f.Include == "" && f.Exclude == "" && len(f.Rename) == 0 && f.Prefix == "" && !f.EmojiFlag
+}
+
+// 地区关键字与国旗的对应关系，按顺序匹配
+var regionFlags = []struct {
+	pattern *regexp.Regexp
+	flag    string
+}{
+	{regexp.MustCompile(`(?i)香港|\bHK\b|Hong\s*Kong`), "🇭🇰"},
+	{regexp.MustCompile(`(?i)台湾|台灣|\bTW\b|Taiwan`), "🇹🇼"},
+	{regexp.MustCompile(`(?i)澳门|澳門|\bMO\b|Macao|Macau`), "🇲🇴"},
+	{regexp.MustCompile(`(?i)日本|东京|大阪|\bJP\b|Japan|Tokyo|Osaka`), "🇯🇵"},
+	{regexp.MustCompile(`(?i)新加坡|狮城|\bSG\b|Singapore`), "🇸🇬"},
+	{regexp.MustCompile(`(?i)韩国|韓國|首尔|\bKR\b|Korea|Seoul`), "🇰🇷"},
+	{regexp.MustCompile(`(?i)美国|美國|洛杉矶|硅谷|西雅图|\bUS\b|\bUSA\b|United\s*States|America`), "🇺🇸"},
+	{regexp.MustCompile(`(?i)英国|英國|伦敦|\bUK\b|\bGB\b|United\s*Kingdom|London`), "🇬🇧"},
+	{regexp.MustCompile(`(?i)德国|德國|法兰克福|\bDE\b|Germany|Frankfurt`), "🇩🇪"},
+	{regexp.MustCompile(`(?i)法国|法國|巴黎|\bFR\b|France|Paris`), "🇫🇷"},
+	{regexp.MustCompile(`(?i)荷兰|荷蘭|阿姆斯特丹|\bNL\b|Netherlands|Amsterdam`), "🇳🇱"},
+	{regexp.MustCompile(`(?i)俄罗斯|俄羅斯|莫斯科|\bRU\b|Russia|Moscow`), "🇷🇺"},
+	{regexp.MustCompile(`(?i)加拿大|\bCA\b|Canada`), "🇨🇦"},
+	{regexp.MustCompile(`(?i)澳大利亚|澳洲|悉尼|\bAU\b|Australia|Sydney`), "🇦🇺"},
+	{regexp.MustCompile(`(?i)印度|\bIN\b|India`), "🇮🇳"},
+	{regexp.MustCompile(`(?i)土耳其|\bTR\b|Turkey`), "🇹🇷"},
+	{regexp.MustCompile(`(?i)越南|\bVN\b|Vietnam`), "🇻🇳"},
+	{regexp.MustCompile(`(?i)泰国|泰國|\bTH\b|Thailand`), "🇹🇭"},
+	{regexp.MustCompile(`(?i)马来西亚|\bMY\b|Malaysia`), "🇲🇾"},
+	{regexp.MustCompile(`(?i)菲律宾|\bPH\b|Philippines`), "🇵🇭"},
+	{regexp.MustCompile(`(?i)阿根廷|\bAR\b|Argentina`), "🇦🇷"},
+	{regexp.MustCompile(`(?i)巴西|\bBR\b|Brazil`), "🇧🇷"},
+}
+
+// compiledFilter 编译后的过滤规则
+type compiledFilter struct {
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+	rename  []*regexp.Regexp
+	filter  NodeFilter
+}
+
+// ValidateNodeFilter 检查过滤规则中的正则表达式是否合法
+func ValidateNodeFilter(filter NodeFilter) error {
+	_, err := compileNodeFilter(filter)
+	return err
+}
+
+// 编译过滤规则中的正则表达式
+func compileNodeFilter(filter NodeFilter) (*compiledFilter, error) {
+	compiled := &compiledFilter{filter: filter}
+
+	var err error
+	if filter.Include != "" {
+		if compiled.include, err = regexp.Compile(filter.Include); err != nil {
+			return nil, fmt.Errorf("保留规则正则无效: %v", err)
+		}
+	}
+	if filter.Exclude != "" {
+		if compiled.exclude, err = regexp.Compile(filter.Exclude); err != nil {
+			return nil, fmt.Errorf("排除规则正则无效: %v", err)
+		}
+	}
+	for _, rule := range filter.Rename {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("重命名规则 %q 无效: %v", rule.Pattern, err)
+		}
+		compiled.rename = append(compiled.rename, re)
+	}
+
+	return compiled, nil
+}
+
+// ApplyNodeFilter 对配置中的节点执行过滤和重命名，并同步更新代理组成员和规则
+func ApplyNodeFilter(yamlConfig map[string]any, filter NodeFilter) error {
+	if filter.IsEmpty() {
+		return nil
+	}
+
+	compiled, err := compileNodeFilter(filter)
+	if err != nil {
+		return err
+	}
+
+	proxies := GetProxyList(yamlConfig)
+	if len(proxies) == 0 {
+		return nil
+	}
+
+	// 原名称 -> 新名称，被过滤掉的节点不在其中
+	renamed := make(map[string]string)
+	removed := make(map[string]bool)
+	names := make(map[string]bool)
+
+	var kept []map[string]any
+	for _, proxy := range proxies {
+		name, _ := proxy["name"].(string)
+		if !compiled.match(name) {
+			removed[name] = true
+			continue
+		}
+
+		newName := UniqueName(names, compiled.newName(name))
+		if newName != name {
+			renamed[name] = newName
+			proxy["name"] = newName
+		}
+		kept = append(kept, proxy)
+	}
+
+	if len(kept) == 0 {
+		return fmt.Errorf("过滤后没有剩余的代理节点")
+	}
+
+	yamlConfig["proxies"] = kept
+	updateProxyGroups(yamlConfig, renamed, removed)
+	updateRules(yamlConfig, renamed, removed)
+
+	return nil
+}
+
+// 判断节点名称是否满足保留/排除规则
+func (c *compiledFilter) match(name string) bool {
+	if c.include != nil && !c.include.MatchString(name) {
+		return false
+	}
+	if c.exclude != nil && c.exclude.MatchString(name) {
+		return false
+	}
+	return true
+}
+
+// 按规则生成新的节点名称
+func (c *compiledFilter) newName(name string) string {
+	for i, re := range c.rename {
+		name = re.ReplaceAllString(name, c.filter.Rename[i].Replace)
+	}
+	name = strings.TrimSpace(name)
+
+	if c.filter.EmojiFlag && !hasFlagPrefix(name) {
+		for _, region := range regionFlags {
+			if region.pattern.MatchString(name) {
+				name = region.flag + " " + name
+				break
+			}
+		}
+	}
+
+	return c.filter.Prefix + name
+}
+
+// 判断名称是否已经以国旗开头（国旗由两个区域指示符组成）
+func hasFlagPrefix(name string) bool {
+	for _, r := range name {
+		return r >= 0x1F1E6 && r <= 0x1F1FF
+	}
+	return false
+}
+
+// 更新代理组中的节点引用，删除被过滤的节点，替换被重命名的节点
+func updateProxyGroups(yamlConfig map[string]any, renamed map[string]string, removed map[string]bool) {
+	groups := GetProxyGroupList(yamlConfig)
+	if len(groups) == 0 {
+		return
+	}
+
+	for _, group := range groups {
+		members, ok := group["proxies"].([]any)
+		if !ok {
+			continue
+		}
+
+		var updated []any
+		for _, member := range members {
+			name, ok := member.(string)
+			if !ok {
+				updated = append(updated, member)
+				continue
+			}
+			if removed[name] {
+				continue
+			}
+			if newName, ok := renamed[name]; ok {
+				name = newName
+			}
+			updated = append(updated, name)
+		}
+
+		// 代理组不能为空，未使用proxy-providers时至少保留一个出口
+		if len(updated) == 0 {
+			if group["use"] != nil {
+				delete(group, "proxies")
+				continue
+			}
+			updated = []any{"DIRECT"}
+		}
+		group["proxies"] = updated
+	}
+
+	yamlConfig["proxy-groups"] = groups
+}
+
+// 更新规则中直接引用的节点，被过滤的节点改为第一个代理组，没有代理组时改为DIRECT
+func updateRules(yamlConfig map[string]any, renamed map[string]string, removed map[string]bool) {
+	groupNames := make(map[string]bool)
+	fallback := "DIRECT"
+	for i, group := range GetProxyGroupList(yamlConfig) {
+		name, _ := group["name"].(string)
+		groupNames[name] = true
+		if i == 0 && name != "" {
+			fallback = name
+		}
+	}
+
+	rewrite := func(rule string) string {
+		fields := splitRule(rule)
+		index := ruleTargetIndex(fields)
+		if index < 0 {
+			return rule
+		}
+		// 与代理组同名时规则指向的是代理组
+		target := strings.TrimSpace(fields[index])
+		if groupNames[target] {
+			return rule
+		}
+		if newName, ok := renamed[target]; ok {
+			fields[index] = newName
+		} else if removed[target] {
+			fields[index] = fallback
+		} else {
+			return rule
+		}
+		return strings.Join(fields, ",")
+	}
+
+	if rules, ok := toStringList(yamlConfig["rules"]); ok {
+		for i, rule := range rules {
+			rules[i] = rewrite(rule)
+		}
+		yamlConfig["rules"] = rules
+	}
+
+	// 子规则的写法与规则相同
+	if subRules, ok := yamlConfig["sub-rules"].(map[string]any); ok {
+		for name, value := range subRules {
+			if rules, ok := toStringList(value); ok {
+				for i, rule := range rules {
+					rules[i] = rewrite(rule)
+				}
+				subRules[name] = rules
+			}
+		}
+	}
+}
+
+// 按逗号拆分规则，逻辑规则括号中的逗号不拆分，如 AND,((DOMAIN,a.com),(NETWORK,UDP)),节点
+func splitRule(rule string) []string {
+	var fields []string
+	depth, start := 0, 0
+	for i, r := range rule {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				fields = append(fields, rule[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(fields, rule[start:])
+}
+
+// RuleTarget 返回规则的出口（节点、代理组或内置出口），子规则和格式不完整的规则返回空字符串
+func RuleTarget(rule string) string {
+	fields := splitRule(rule)
+	index := ruleTargetIndex(fields)
+	if index < 0 {
+		return ""
+	}
+	return strings.TrimSpace(fields[index])
+}
+
+// 规则中出口所在的位置，MATCH规则为第二项，其他规则为第三项，子规则引用的是子规则名称，返回-1
+func ruleTargetIndex(fields []string) int {
+	switch strings.ToUpper(strings.TrimSpace(fields[0])) {
+	case "SUB-RULE":
+		return -1
+	case "MATCH":
+		if len(fields) >= 2 {
+			return 1
+		}
+		return -1
+	}
+	if len(fields) >= 3 {
+		return 2
+	}
+	return -1
+}
+
+// 将 []any 或 []string 转换为 []string，包含非字符串项时返回false
+func toStringList(value any) ([]string, bool) {
+	switch list := value.(type) {
+	case []string:
+		return list, true
+	case []any:
+		result := make([]string, len(list))
+		for i, item := range list {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			result[i] = s
+		}
+		return result, true
+	}
+	return nil, false
+}
+
+// GetProxyList 获取配置中的节点列表，兼容解析结果和生成结果两种类型
+func GetProxyList(yamlConfig map[string]any) []map[string]any {
+	return toMapList(yamlConfig["proxies"])
+}
+
+// GetProxyGroupList 获取配置中的代理组列表
+func GetProxyGroupList(yamlConfig map[string]any) []map[string]any {
+	groups := toMapList(yamlConfig["proxy-groups"])
+	for _, group := range groups {
+		// 统一成员列表类型，便于后续修改
+		switch members := group["proxies"].(type) {
+		case []string:
+			list := make([]any, len(members))
+			for i, m := range members {
+				list[i] = m
+			}
+			group["proxies"] = list
+		}
+	}
+	return groups
+}
+
+// 将 []any 或 []map[string]any 转换为 []map[string]any
+func toMapList(value any) []map[string]any {
+	switch list := value.(type) {
+	case []map[string]any:
+		return list
+	case []any:
+		result := make([]map[string]any, 0, len(list))
+		for _, item := range list {
+			if m, ok := item.(map[string]any); ok {
+				result = append(result, m)
+			}
+		}
+		return result
+	}
+	return nil
+}
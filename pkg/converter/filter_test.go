@@ -0,0 +1,187 @@
+package converter
+
+import (
+	"slices"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+const filterTestConfig = `
+proxies:
+  - {name: 香港 01, type: ss, server: hk1.example.com, port: 443}
+  - {name: 香港 02, type: ss, server: hk2.example.com, port: 443}
+  - {name: Japan 01, type: ss, server: jp1.example.com, port: 443}
+  - {name: 剩余流量 10GB, type: ss, server: info.example.com, port: 443}
+proxy-groups:
+  - {name: 节点选择, type: select, proxies: [自动选择, 香港 01, 香港 02, Japan 01, 剩余流量 10GB]}
+  - {name: 自动选择, type: url-test, proxies: [香港 01, 香港 02, Japan 01]}
+  - {name: 日本, type: select, proxies: [Japan 01]}
+  - {name: 流量, type: select, use: [provider], proxies: [剩余流量 10GB]}
+rules:
+  - DOMAIN-SUFFIX,hk.example,香港 01
+  - IP-CIDR,10.0.0.0/8,Japan 01,no-resolve
+  - AND,((DOMAIN,a.example),(NETWORK,UDP)),香港 02
+  - DOMAIN,info.example,剩余流量 10GB
+  - SUB-RULE,(NETWORK,tcp),香港 01
+  - DOMAIN,group.example,日本
+  - MATCH,节点选择
+sub-rules:
+  sub:
+    - DOMAIN,sub.example,Japan 01
+`
+
+func loadFilterTestConfig(t *testing.T) map[string]any {
+	t.Helper()
+	var yamlConfig map[string]any
+	if err := yaml.Unmarshal([]byte(filterTestConfig), &yamlConfig); err != nil {
+		t.Fatal(err)
+	}
+	return yamlConfig
+}
+
+func proxyNames(yamlConfig map[string]any) []string {
+	var names []string
+	for _, proxy := range GetProxyList(yamlConfig) {
+		names = append(names, proxy["name"].(string))
+	}
+	return names
+}
+
+func groupMembers(yamlConfig map[string]any) map[string][]any {
+	members := make(map[string][]any)
+	for _, group := range GetProxyGroupList(yamlConfig) {
+		list, _ := group["proxies"].([]any)
+		members[group["name"].(string)] = list
+	}
+	return members
+}
+
+func TestApplyNodeFilterNames(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter NodeFilter
+		want   []string
+	}{
+		{"保留", NodeFilter{Include: "香港"}, []string{"香港 01", "香港 02"}},
+		{"排除", NodeFilter{Exclude: "剩余流量"}, []string{"香港 01", "香港 02", "Japan 01"}},
+		{"保留和排除", NodeFilter{Include: "01", Exclude: "Japan"}, []string{"香港 01"}},
+		{"重命名", NodeFilter{Rename: []RenameRule{{Pattern: `^香港`, Replace: "HK"}, {Pattern: `\s+`, Replace: "-"}}},
+			[]string{"HK-01", "HK-02", "Japan-01", "剩余流量-10GB"}},
+		{"前缀", NodeFilter{Include: "Japan", Prefix: "[A] "}, []string{"[A] Japan 01"}},
+		{"国旗", NodeFilter{Exclude: "流量", EmojiFlag: true}, []string{"🇭🇰 香港 01", "🇭🇰 香港 02", "🇯🇵 Japan 01"}},
+		{"国旗在前缀之后", NodeFilter{Include: "Japan", EmojiFlag: true, Prefix: "[A] "}, []string{"[A] 🇯🇵 Japan 01"}},
+		{"重命名后重名", NodeFilter{Rename: []RenameRule{{Pattern: `\s*\d+$`, Replace: ""}}, Exclude: "流量"},
+			[]string{"香港", "香港-1", "Japan"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			yamlConfig := loadFilterTestConfig(t)
+			if err := ApplyNodeFilter(yamlConfig, tt.filter); err != nil {
+				t.Fatal(err)
+			}
+			if got := proxyNames(yamlConfig); !slices.Equal(got, tt.want) {
+				t.Errorf("节点 = %v，应为 %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyNodeFilterGroups(t *testing.T) {
+	yamlConfig := loadFilterTestConfig(t)
+	filter := NodeFilter{Exclude: "Japan|流量", Prefix: "A-"}
+	if err := ApplyNodeFilter(yamlConfig, filter); err != nil {
+		t.Fatal(err)
+	}
+
+	members := groupMembers(yamlConfig)
+	// 代理组成员跟随重命名，被过滤的节点从代理组中删除，引用的其他代理组保持不变
+	if got := members["节点选择"]; !slices.Equal(got, []any{"自动选择", "A-香港 01", "A-香港 02"}) {
+		t.Errorf("节点选择 = %v", got)
+	}
+	if got := members["自动选择"]; !slices.Equal(got, []any{"A-香港 01", "A-香港 02"}) {
+		t.Errorf("自动选择 = %v", got)
+	}
+	// 成员全部被过滤的代理组改为DIRECT，使用provider的代理组只保留provider
+	if got := members["日本"]; !slices.Equal(got, []any{"DIRECT"}) {
+		t.Errorf("日本 = %v", got)
+	}
+	for _, group := range GetProxyGroupList(yamlConfig) {
+		if group["name"] == "流量" {
+			if _, ok := group["proxies"]; ok || group["use"] == nil {
+				t.Errorf("使用provider的代理组 = %v", group)
+			}
+		}
+	}
+
+	// 所有代理组成员都是存在的节点、代理组或内置出口
+	valid := map[any]bool{"DIRECT": true, "REJECT": true}
+	for _, name := range proxyNames(yamlConfig) {
+		valid[name] = true
+	}
+	for name := range members {
+		valid[name] = true
+	}
+	for group, list := range members {
+		for _, member := range list {
+			if !valid[member] {
+				t.Errorf("代理组 %s 引用了不存在的 %v", group, member)
+			}
+		}
+	}
+}
+
+func TestApplyNodeFilterRules(t *testing.T) {
+	yamlConfig := loadFilterTestConfig(t)
+	filter := NodeFilter{Exclude: "Japan|流量", Rename: []RenameRule{{Pattern: `^香港 `, Replace: "HK-"}}}
+	if err := ApplyNodeFilter(yamlConfig, filter); err != nil {
+		t.Fatal(err)
+	}
+
+	rules, _ := toStringList(yamlConfig["rules"])
+	want := []string{
+		// 重命名的节点使用新名称
+		"DOMAIN-SUFFIX,hk.example,HK-01",
+		// 被过滤的节点改为第一个代理组，其他参数保留
+		"IP-CIDR,10.0.0.0/8,节点选择,no-resolve",
+		// 逻辑规则括号中的逗号不影响出口的位置
+		"AND,((DOMAIN,a.example),(NETWORK,UDP)),HK-02",
+		"DOMAIN,info.example,节点选择",
+		// 子规则引用的是子规则名称，不修改
+		"SUB-RULE,(NETWORK,tcp),香港 01",
+		"DOMAIN,group.example,日本",
+		"MATCH,节点选择",
+	}
+	if !slices.Equal(rules, want) {
+		t.Errorf("规则 = %q\n应为 %q", rules, want)
+	}
+
+	subRules, _ := yamlConfig["sub-rules"].(map[string]any)
+	if got, _ := toStringList(subRules["sub"]); !slices.Equal(got, []string{"DOMAIN,sub.example,节点选择"}) {
+		t.Errorf("子规则 = %q", got)
+	}
+
+	// 没有代理组时改为DIRECT
+	yamlConfig = loadFilterTestConfig(t)
+	delete(yamlConfig, "proxy-groups")
+	if err := ApplyNodeFilter(yamlConfig, NodeFilter{Exclude: "Japan"}); err != nil {
+		t.Fatal(err)
+	}
+	rules, _ = toStringList(yamlConfig["rules"])
+	if rules[1] != "IP-CIDR,10.0.0.0/8,DIRECT,no-resolve" {
+		t.Errorf("没有代理组时的规则 = %q", rules[1])
+	}
+}
+
+func TestApplyNodeFilterErrors(t *testing.T) {
+	yamlConfig := loadFilterTestConfig(t)
+	if err := ApplyNodeFilter(yamlConfig, NodeFilter{Include: "("}); err == nil {
+		t.Error("无效的正则应返回错误")
+	}
+	if err := ApplyNodeFilter(yamlConfig, NodeFilter{Include: "不存在"}); err == nil {
+		t.Error("过滤后没有节点时应返回错误")
+	}
+	if got := proxyNames(yamlConfig); len(got) != 4 {
+		t.Errorf("失败时不应修改节点: %v", got)
+	}
+}
@@ -0,0 +1,322 @@
+package converter
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// EncodeSubscriptionLinks 将节点编码为Base64格式的分享链接列表，返回无法转换的节点名称
+func EncodeSubscriptionLinks(proxies []map[string]any) ([]byte, []string) {
+	var links []string
+	var skipped []string
+
+	for _, proxy := range proxies {
+		link, err := ProxyToURL(proxy)
+		if err != nil {
+			skipped = append(skipped, GetStringOrDefault(proxy["name"], ""))
+			continue
+		}
+		links = append(links, link)
+	}
+
+	encoded := base64.StdEncoding.EncodeToString([]byte(strings.Join(links, "\n")))
+	return []byte(encoded), skipped
+}
+
+// ProxyToURL 将Clash节点转换为分享链接
+func ProxyToURL(proxy map[string]any) (string, error) {
+	proxyType := GetStringOrDefault(proxy["type"], "")
+	server := valueString(proxy["server"])
+	port := valueString(proxy["port"])
+	name := valueString(proxy["name"])
+
+	if server == "" || port == "" {
+		return "", fmt.Errorf("节点 %s 缺少服务器或端口", name)
+	}
+	hostPort := net.JoinHostPort(server, port)
+
+	switch proxyType {
+	case "ss":
+		userInfo := base64.StdEncoding.EncodeToString([]byte(valueString(proxy["cipher"]) + ":" + valueString(proxy["password"])))
+		link := "ss://" + userInfo + "@" + hostPort
+		if plugin := valueString(proxy["plugin"]); plugin == "obfs" {
+			opts, _ := proxy["plugin-opts"].(map[string]any)
+			pluginStr := "obfs-local;obfs=" + valueString(opts["mode"]) + ";obfs-host=" + valueString(opts["host"])
+			link += "/?plugin=" + url.QueryEscape(pluginStr)
+		}
+		return link + "#" + url.PathEscape(name), nil
+
+	case "ssr":
+		params := url.Values{}
+		params.Set("remarks", base64.RawURLEncoding.EncodeToString([]byte(name)))
+		if obfsParam := valueString(proxy["obfs-param"]); obfsParam != "" {
+			params.Set("obfsparam", base64.RawURLEncoding.EncodeToString([]byte(obfsParam)))
+		}
+		if protocolParam := valueString(proxy["protocol-param"]); protocolParam != "" {
+			params.Set("protoparam", base64.RawURLEncoding.EncodeToString([]byte(protocolParam)))
+		}
+		content := strings.Join([]string{
+			server, port,
+			valueString(proxy["protocol"]),
+			valueString(proxy["cipher"]),
+			valueString(proxy["obfs"]),
+			base64.RawURLEncoding.EncodeToString([]byte(valueString(proxy["password"]))),
+		}, ":") + "/?" + params.Encode()
+		return "ssr://" + base64.RawURLEncoding.EncodeToString([]byte(content)), nil
+
+	case "vmess":
+		vmess := map[string]any{
+			"v":    "2",
+			"ps":   name,
+			"add":  server,
+			"port": port,
+			"id":   valueString(proxy["uuid"]),
+			"aid":  valueString(proxy["alterId"]),
+			"scy":  GetStringOrDefault(proxy["cipher"], "auto"),
+			"net":  GetStringOrDefault(proxy["network"], "tcp"),
+			"type": "none",
+		}
+		if valueBool(proxy["tls"]) {
+			vmess["tls"] = "tls"
+			vmess["sni"] = valueString(proxy["servername"])
+		}
+		path, host := transportPathHost(proxy)
+		vmess["path"] = path
+		vmess["host"] = host
+
+		content, err := json.Marshal(vmess)
+		if err != nil {
+			return "", err
+		}
+		return "vmess://" + base64.StdEncoding.EncodeToString(content), nil
+
+	case "trojan":
+		query := url.Values{}
+		if sni := valueString(proxy["sni"]); sni != "" {
+			query.Set("sni", sni)
+		}
+		if valueBool(proxy["skip-cert-verify"]) {
+			query.Set("allowInsecure", "1")
+		}
+		addTransportQuery(query, proxy)
+		return buildURL("trojan", url.User(valueString(proxy["password"])), hostPort, query, name), nil
+
+	case "vless":
+		query := url.Values{}
+		query.Set("encryption", "none")
+		query.Set("type", GetStringOrDefault(proxy["network"], "tcp"))
+		if reality, ok := proxy["reality-opts"].(map[string]any); ok {
+			query.Set("security", "reality")
+			query.Set("pbk", valueString(reality["public-key"]))
+			if sid := valueString(reality["short-id"]); sid != "" {
+				query.Set("sid", sid)
+			}
+		} else if valueBool(proxy["tls"]) {
+			query.Set("security", "tls")
+		}
+		if flow := valueString(proxy["flow"]); flow != "" {
+			query.Set("flow", flow)
+		}
+		if fp := valueString(proxy["client-fingerprint"]); fp != "" {
+			query.Set("fp", fp)
+		}
+		if sni := valueString(proxy["servername"]); sni != "" {
+			query.Set("sni", sni)
+		}
+		addTransportQuery(query, proxy)
+		return buildURL("vless", url.User(valueString(proxy["uuid"])), hostPort, query, name), nil
+
+	case "hysteria2":
+		query := url.Values{}
+		if sni := valueString(proxy["sni"]); sni != "" {
+			query.Set("sni", sni)
+		}
+		if valueBool(proxy["skip-cert-verify"]) {
+			query.Set("insecure", "1")
+		}
+		if obfs := valueString(proxy["obfs"]); obfs != "" {
+			query.Set("obfs", obfs)
+			query.Set("obfs-password", valueString(proxy["obfs-password"]))
+		}
+		return buildURL("hysteria2", url.User(valueString(proxy["password"])), hostPort, query, name), nil
+
+	case "hysteria":
+		query := url.Values{}
+		for key, field := range map[string]string{"peer": "sni", "obfs": "obfs", "protocol": "protocol", "upmbps": "up", "downmbps": "down"} {
+			if value := valueString(proxy[field]); value != "" {
+				query.Set(key, value)
+			}
+		}
+		if alpn := valueStrings(proxy["alpn"]); len(alpn) > 0 {
+			query.Set("alpn", strings.Join(alpn, ","))
+		}
+		if valueBool(proxy["skip-cert-verify"]) {
+			query.Set("insecure", "1")
+		}
+		var user *url.Userinfo
+		if auth := valueString(proxy["auth_str"]); auth != "" {
+			user = url.User(auth)
+		}
+		return buildURL("hysteria", user, hostPort, query, name), nil
+
+	case "tuic":
+		query := url.Values{}
+		for key, field := range map[string]string{"congestion_control": "congestion-control", "sni": "sni", "udp_relay_mode": "udp-relay-mode"} {
+			if value := valueString(proxy[field]); value != "" {
+				query.Set(key, value)
+			}
+		}
+		if alpn := valueStrings(proxy["alpn"]); len(alpn) > 0 {
+			query.Set("alpn", strings.Join(alpn, ","))
+		}
+		if valueBool(proxy["disable-sni"]) {
+			query.Set("disable_sni", "1")
+		}
+		var user *url.Userinfo
+		if uuid := valueString(proxy["uuid"]); uuid != "" {
+			user = url.UserPassword(uuid, valueString(proxy["password"]))
+		} else {
+			user = url.User(valueString(proxy["token"]))
+		}
+		return buildURL("tuic", user, hostPort, query, name), nil
+	}
+
+	return "", fmt.Errorf("不支持转换为分享链接的节点类型: %s", proxyType)
+}
+
+// 拼接分享链接
+func buildURL(scheme string, user *url.Userinfo, hostPort string, query url.Values, name string) string {
+	u := url.URL{
+		Scheme:   scheme,
+		User:     user,
+		Host:     hostPort,
+		RawQuery: query.Encode(),
+		Fragment: name,
+	}
+	return u.String()
+}
+
+// 将传输层设置写入查询参数
+func addTransportQuery(query url.Values, proxy map[string]any) {
+	network := valueString(proxy["network"])
+	if network == "" {
+		return
+	}
+	query.Set("type", network)
+
+	path, host := transportPathHost(proxy)
+	if network == "grpc" {
+		if path != "" {
+			query.Set("serviceName", path)
+		}
+		return
+	}
+	if path != "" {
+		query.Set("path", path)
+	}
+	if host != "" {
+		query.Set("host", host)
+	}
+}
+
+// 获取传输层的路径和主机头，grpc返回服务名称
+func transportPathHost(proxy map[string]any) (string, string) {
+	switch valueString(proxy["network"]) {
+	case "ws":
+		opts, _ := proxy["ws-opts"].(map[string]any)
+		headers, _ := opts["headers"].(map[string]any)
+		return valueString(opts["path"]), valueString(headers["Host"])
+	case "h2":
+		opts, _ := proxy["h2-opts"].(map[string]any)
+		hosts := valueStrings(opts["host"])
+		host := ""
+		if len(hosts) > 0 {
+			host = hosts[0]
+		}
+		return valueString(opts["path"]), host
+	case "http":
+		opts, _ := proxy["http-opts"].(map[string]any)
+		headers, _ := opts["headers"].(map[string]any)
+		paths := valueStrings(opts["path"])
+		path := valueString(opts["path"])
+		if len(paths) > 0 {
+			path = paths[0]
+		}
+		return path, valueString(headers["Host"])
+	case "grpc":
+		opts, _ := proxy["grpc-opts"].(map[string]any)
+		return valueString(opts["grpc-service-name"]), ""
+	}
+	return "", ""
+}
+
+// 将任意YAML值转换为字符串
+func valueString(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []any, []string:
+		list := valueStrings(v)
+		if len(list) > 0 {
+			return list[0]
+		}
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// 将YAML列表转换为字符串列表
+func valueStrings(value any) []string {
+	switch v := value.(type) {
+	case []string:
+		return v
+	case []any:
+		result := make([]string, 0, len(v))
+		for _, item := range v {
+			result = append(result, valueString(item))
+		}
+		return result
+	case string:
+		if v == "" {
+			return nil
+		}
+		return []string{v}
+	}
+	return nil
+}
+
+// 将YAML值转换为布尔值
+func valueBool(value any) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true" || v == "1"
+	}
+	return false
+}
+
+// 将YAML值转换为整数
+func valueInt(value any) int {
+	switch v := value.(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	case string:
+		i, _ := strconv.Atoi(v)
+		return i
+	}
+	return 0
+}
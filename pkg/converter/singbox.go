@@ -0,0 +1,215 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// BuildSingBoxConfig 将节点转换为sing-box配置，返回无法转换的节点名称
+func BuildSingBoxConfig(proxies []map[string]any) ([]byte, []string, error) {
+	var outbounds []map[string]any
+	var tags []string
+	var skipped []string
+
+	for _, proxy := range proxies {
+		outbound, err := ProxyToSingBox(proxy)
+		if err != nil {
+			skipped = append(skipped, GetStringOrDefault(proxy["name"], ""))
+			continue
+		}
+		outbounds = append(outbounds, outbound)
+		tags = append(tags, outbound["tag"].(string))
+	}
+
+	if len(outbounds) == 0 {
+		return nil, skipped, fmt.Errorf("没有可以转换为sing-box格式的节点")
+	}
+
+	// 与生成的Clash配置保持一致的选择和自动测速出站
+	selector := map[string]any{
+		"type":      "selector",
+		"tag":       "🚀 节点选择",
+		"outbounds": append([]string{"♻️ 自动选择", "direct"}, tags...),
+	}
+	urlTest := map[string]any{
+		"type":      "urltest",
+		"tag":       "♻️ 自动选择",
+		"outbounds": tags,
+		"url":       "http://www.gstatic.com/generate_204",
+		"interval":  "5m",
+	}
+
+	allOutbounds := append([]map[string]any{selector, urlTest}, outbounds...)
+	allOutbounds = append(allOutbounds, map[string]any{"type": "direct", "tag": "direct"})
+
+	config := map[string]any{
+		"log": map[string]any{"level": "info"},
+		"inbounds": []map[string]any{
+			{
+				"type":         "tun",
+				"tag":          "tun-in",
+				"address":      []string{"172.19.0.1/30"},
+				"auto_route":   true,
+				"strict_route": true,
+			},
+			{
+				"type":        "mixed",
+				"tag":         "mixed-in",
+				"listen":      "127.0.0.1",
+				"listen_port": 7890,
+			},
+		},
+		"outbounds": allOutbounds,
+		"route": map[string]any{
+			"auto_detect_interface": true,
+			"rules": []map[string]any{
+				{"ip_is_private": true, "outbound": "direct"},
+			},
+			"final": "🚀 节点选择",
+		},
+	}
+
+	content, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return nil, skipped, fmt.Errorf("编码sing-box配置失败: %v", err)
+	}
+
+	return content, skipped, nil
+}
+
+// ProxyToSingBox 将Clash节点转换为sing-box出站
+func ProxyToSingBox(proxy map[string]any) (map[string]any, error) {
+	proxyType := GetStringOrDefault(proxy["type"], "")
+	name := valueString(proxy["name"])
+
+	outbound := map[string]any{
+		"tag":         name,
+		"server":      valueString(proxy["server"]),
+		"server_port": valueInt(proxy["port"]),
+	}
+
+	switch proxyType {
+	case "ss":
+		outbound["type"] = "shadowsocks"
+		outbound["method"] = valueString(proxy["cipher"])
+		outbound["password"] = valueString(proxy["password"])
+		if valueString(proxy["plugin"]) == "obfs" {
+			opts, _ := proxy["plugin-opts"].(map[string]any)
+			outbound["plugin"] = "obfs-local"
+			outbound["plugin_opts"] = "obfs=" + valueString(opts["mode"]) + ";obfs-host=" + valueString(opts["host"])
+		}
+
+	case "vmess":
+		outbound["type"] = "vmess"
+		outbound["uuid"] = valueString(proxy["uuid"])
+		outbound["alter_id"] = valueInt(proxy["alterId"])
+		outbound["security"] = GetStringOrDefault(proxy["cipher"], "auto")
+		if valueBool(proxy["tls"]) {
+			outbound["tls"] = singBoxTLS(proxy, valueString(proxy["servername"]))
+		}
+		addSingBoxTransport(outbound, proxy)
+
+	case "trojan":
+		outbound["type"] = "trojan"
+		outbound["password"] = valueString(proxy["password"])
+		outbound["tls"] = singBoxTLS(proxy, valueString(proxy["sni"]))
+		addSingBoxTransport(outbound, proxy)
+
+	case "vless":
+		outbound["type"] = "vless"
+		outbound["uuid"] = valueString(proxy["uuid"])
+		if flow := valueString(proxy["flow"]); flow != "" {
+			outbound["flow"] = flow
+		}
+		if valueBool(proxy["tls"]) {
+			tls := singBoxTLS(proxy, valueString(proxy["servername"]))
+			if reality, ok := proxy["reality-opts"].(map[string]any); ok {
+				tls["reality"] = map[string]any{
+					"enabled":    true,
+					"public_key": valueString(reality["public-key"]),
+					"short_id":   valueString(reality["short-id"]),
+				}
+			}
+			outbound["tls"] = tls
+		}
+		addSingBoxTransport(outbound, proxy)
+
+	case "hysteria2":
+		outbound["type"] = "hysteria2"
+		outbound["password"] = valueString(proxy["password"])
+		outbound["tls"] = singBoxTLS(proxy, valueString(proxy["sni"]))
+		if obfs := valueString(proxy["obfs"]); obfs != "" {
+			outbound["obfs"] = map[string]any{
+				"type":     obfs,
+				"password": valueString(proxy["obfs-password"]),
+			}
+		}
+
+	case "hysteria":
+		outbound["type"] = "hysteria"
+		outbound["auth_str"] = valueString(proxy["auth_str"])
+		outbound["up_mbps"] = valueInt(proxy["up"])
+		outbound["down_mbps"] = valueInt(proxy["down"])
+		if obfs := valueString(proxy["obfs"]); obfs != "" {
+			outbound["obfs"] = obfs
+		}
+		outbound["tls"] = singBoxTLS(proxy, valueString(proxy["sni"]))
+
+	case "tuic":
+		outbound["type"] = "tuic"
+		outbound["uuid"] = valueString(proxy["uuid"])
+		outbound["password"] = valueString(proxy["password"])
+		if cc := valueString(proxy["congestion-control"]); cc != "" {
+			outbound["congestion_control"] = cc
+		}
+		if mode := valueString(proxy["udp-relay-mode"]); mode != "" {
+			outbound["udp_relay_mode"] = mode
+		}
+		outbound["tls"] = singBoxTLS(proxy, valueString(proxy["sni"]))
+
+	default:
+		return nil, fmt.Errorf("不支持转换为sing-box格式的节点类型: %s", proxyType)
+	}
+
+	return outbound, nil
+}
+
+// 生成sing-box的TLS设置
+func singBoxTLS(proxy map[string]any, serverName string) map[string]any {
+	tls := map[string]any{
+		"enabled":  true,
+		"insecure": valueBool(proxy["skip-cert-verify"]),
+	}
+	if serverName != "" {
+		tls["server_name"] = serverName
+	}
+	if alpn := valueStrings(proxy["alpn"]); len(alpn) > 0 {
+		tls["alpn"] = alpn
+	}
+	if fp := valueString(proxy["client-fingerprint"]); fp != "" {
+		tls["utls"] = map[string]any{"enabled": true, "fingerprint": fp}
+	}
+	return tls
+}
+
+// 生成sing-box的传输层设置
+func addSingBoxTransport(outbound map[string]any, proxy map[string]any) {
+	path, host := transportPathHost(proxy)
+
+	switch valueString(proxy["network"]) {
+	case "ws":
+		transport := map[string]any{"type": "ws", "path": path}
+		if host != "" {
+			transport["headers"] = map[string]any{"Host": host}
+		}
+		outbound["transport"] = transport
+	case "h2", "http":
+		transport := map[string]any{"type": "http", "path": path}
+		if host != "" {
+			transport["host"] = []string{host}
+		}
+		outbound["transport"] = transport
+	case "grpc":
+		outbound["transport"] = map[string]any{"type": "grpc", "service_name": path}
+	}
+}
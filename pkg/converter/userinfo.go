@@ -0,0 +1,78 @@
+package converter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// UserInfo 订阅的流量和到期信息，对应subscription-userinfo响应头
+type UserInfo struct {
+	Upload   int64
+	Download int64
+	Total    int64
+	Expire   int64
+}
+
+// ParseUserInfo 解析形如 upload=1; download=2; total=3; expire=4 的流量信息
+func ParseUserInfo(value string) (UserInfo, bool) {
+	var info UserInfo
+	found := false
+
+	for _, part := range strings.Split(value, ";") {
+		key, val, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+
+		// 部分机场返回浮点数，统一截断为整数
+		number, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+		if err != nil {
+			continue
+		}
+
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "upload":
+			info.Upload = int64(number)
+		case "download":
+			info.Download = int64(number)
+		case "total":
+			info.Total = int64(number)
+		case "expire":
+			info.Expire = int64(number)
+		default:
+			continue
+		}
+		found = true
+	}
+
+	return info, found
+}
+
+// Remaining 剩余流量，总量未知时返回-1
+func (info UserInfo) Remaining() int64 {
+	if info.Total <= 0 {
+		return -1
+	}
+	return info.Total - info.Upload - info.Download
+}
+
+// Add 累加另一个订阅的流量信息，到期时间取较早的一个
+func (info UserInfo) Add(other UserInfo) UserInfo {
+	info.Upload += other.Upload
+	info.Download += other.Download
+	info.Total += other.Total
+	if other.Expire > 0 && (info.Expire == 0 || other.Expire < info.Expire) {
+		info.Expire = other.Expire
+	}
+	return info
+}
+
+// String 编码为subscription-userinfo响应头格式
+func (info UserInfo) String() string {
+	result := fmt.Sprintf("upload=%d; download=%d; total=%d", info.Upload, info.Download, info.Total)
+	if info.Expire > 0 {
+		result += fmt.Sprintf("; expire=%d", info.Expire)
+	}
+	return result
+}
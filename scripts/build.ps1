@@ -15,6 +15,15 @@ $Targets = @(
     @{OS = "linux"; Arch = "arm"; Arm = "7"; File = "${ProjectName}-linux-armv7"}
 )
 
+# 前端已构建时嵌入到二进制文件中
+$Tags = ""
+if (Test-Path -Path "frontend/dist/index.html") {
+    $Tags = "embedfrontend"
+    Write-Host "检测到 frontend/dist，将前端嵌入二进制文件" -ForegroundColor Cyan
+} else {
+    Write-Host "未找到 frontend/dist，构建不包含前端的版本，请先在 frontend 目录执行 pnpm build" -ForegroundColor Yellow
+}
+
 # 显示开始编译消息
 Write-Host "开始为 $ProjectName 构建跨平台二进制文件..." -ForegroundColor Cyan
 
@@ -37,7 +46,7 @@ foreach ($Target in $Targets) {
     }
     
     # 执行Go构建命令
-    go build -trimpath -ldflags "-s -w -X main.Version=$Version" -o "$OutputFile" .
+    go build -trimpath -tags "$Tags" -ldflags "-s -w -X main.Version=$Version" -o "$OutputFile" .
     
     if ($LASTEXITCODE -eq 0) {
         Write-Host "  编译成功: $OutputFile" -ForegroundColor Green
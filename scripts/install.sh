@@ -97,9 +97,12 @@ After=network.target
 Type=simple
 User=root
 WorkingDirectory=${INSTALL_DIR}
-ExecStart=${BINARY} -H 0.0.0.0 -p 7788
+ExecStart=${BINARY} --data-dir ${INSTALL_DIR} -H 0.0.0.0 -p 7788
 Restart=on-failure
 RestartSec=5
+# 只向clash-center发送停止信号，由它保存状态并停止内核
+# 使用 --keep-core 时内核继续运行，下次启动时接管；clash-center崩溃时内核随之退出
+KillMode=process
 LimitNOFILE=65535
 
 [Install]